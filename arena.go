@@ -0,0 +1,68 @@
+package mini_groupcache
+
+// arena 把小的值复制到较大的 slab 中，大量小值只对应少量堆对象，减轻 GC 跟踪和扫描小对象的负担
+// 值在 slab 中顺序分配，不会单独回收；一个 slab 上的值全部被淘汰（或删除、覆盖）后，缓存不再引用这个 slab，
+// 等到调用方手中指向它的 ByteView 也都不再使用时由 GC 整体回收。slab 不会被复用，
+// 因为调用方可能仍然持有指向旧值的 ByteView，复用会改写这些值
+// arena 不是并发安全的，由所属 cache 的锁保护
+type arena struct {
+	threshold int // 长度小于它的值才放入 slab
+	slabSize  int // 每个 slab 的字节数
+
+	cur    *slab // 正在分配的 slab
+	nslabs int   // 缓存中的值还在引用的 slab 数（包括 cur）
+}
+
+// slab 是一块连续的内存，live 为还在缓存中的值的个数
+type slab struct {
+	buf  []byte
+	off  int
+	live int
+}
+
+func newArena(threshold, slabSize int) *arena {
+	if threshold <= 0 || slabSize < threshold {
+		panic("arena: slab size must be at least the threshold, and the threshold must be positive")
+	}
+	return &arena{threshold: threshold, slabSize: slabSize}
+}
+
+// alloc 把 v 复制到 slab 中并返回指向副本的 ByteView 和所在的 slab，v 不够小时原样返回，slab 为 nil
+func (a *arena) alloc(v ByteView) (ByteView, *slab) {
+	n := v.Len()
+	if n == 0 || n >= a.threshold {
+		return v, nil
+	}
+	if a.cur == nil || a.cur.off+n > len(a.cur.buf) {
+		if a.cur != nil && a.cur.live == 0 {
+			// 旧的 slab 已经没有缓存中的值了，换下来之后就不再被引用
+			a.nslabs--
+		}
+		a.cur = &slab{buf: make([]byte, a.slabSize)}
+		a.nslabs++
+	}
+
+	s := a.cur
+	copy(s.buf[s.off:], v.b)
+	// 限制容量，副本不能通过 append 写到相邻的值上
+	b := s.buf[s.off : s.off+n : s.off+n]
+	s.off += n
+	s.live++
+	return ByteView{b: b}, s
+}
+
+// bytes 返回 slab 实际占用的字节数，包括其中已经离开缓存、还没有随 slab 整体释放的空间
+func (a *arena) bytes() int64 {
+	return int64(a.nslabs) * int64(a.slabSize)
+}
+
+// free 在 s 中的一个值离开缓存时调用，s 为 nil 时什么也不做
+func (a *arena) free(s *slab) {
+	if s == nil {
+		return
+	}
+	s.live--
+	if s.live == 0 && s != a.cur {
+		a.nslabs--
+	}
+}
@@ -0,0 +1,86 @@
+package mini_groupcache
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestGroup_SmallValueArena(t *testing.T) {
+	// 每个值 4 个字节，一个 slab 放 4 个值；容量放得下 8 个条目
+	group := NewGroup("arena", 8*(4+4), GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithSmallValueArena(16, 16))
+	a := group.mainCache.arena
+
+	for i := 1000; i < 1008; i++ {
+		group.Get(strconv.Itoa(i))
+	}
+	if a.nslabs != 2 {
+		t.Fatalf("nslabs = %d, want 2", a.nslabs)
+	}
+	for i := 1000; i < 1008; i++ {
+		if v, err := group.Get(strconv.Itoa(i)); err != nil || v.String() != strconv.Itoa(i) {
+			t.Fatalf("Get(%d) = %q, %v", i, v, err)
+		}
+	}
+
+	// 第一个 slab 上的值全部被淘汰后释放
+	for i := 2000; i < 2004; i++ {
+		group.Get(strconv.Itoa(i))
+	}
+	if a.nslabs != 2 {
+		t.Fatalf("nslabs = %d after eviction, want 2", a.nslabs)
+	}
+
+	// 覆盖和删除同样会释放 slab
+	for i := 1004; i < 1008; i++ {
+		group.Remove(strconv.Itoa(i))
+	}
+	if a.nslabs != 1 {
+		t.Fatalf("nslabs = %d after Remove, want 1", a.nslabs)
+	}
+	// 统计信息报告 slab 的实际占用，包括其中已经离开缓存的空间
+	if s := group.Stats(); s.ArenaBytes != 16 {
+		t.Fatalf("ArenaBytes = %d, want one 16-byte slab", s.ArenaBytes)
+	}
+	for _, v := range group.mainCache.entries() {
+		if v.view.String() != v.key {
+			t.Fatalf("value of %s corrupted: %q", v.key, v.view)
+		}
+	}
+}
+
+// BenchmarkCache_SmallValues 对比开启 arena 前后缓存了大量小值时的堆对象数
+func BenchmarkCache_SmallValues(b *testing.B) {
+	for _, tc := range []struct {
+		name  string
+		arena *arena
+	}{
+		{"plain", nil},
+		{"arena", newArena(64, 64<<10)},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			// 值超过 16 字节，不会被运行时的 tiny 分配器合并成一个对象
+			value := []byte("a-small-cached-value-of-32-bytes")
+			var objects uint64
+			for n := 0; n < b.N; n++ {
+				c := &cache{cacheBytes: UnlimitedCacheBytes, arena: tc.arena}
+				before := heapObjects()
+				for i := 0; i < 100000; i++ {
+					c.add(strconv.Itoa(i), cacheEntry{view: ByteView{b: cloneBytes(value)}})
+				}
+				objects += heapObjects() - before
+				runtime.KeepAlive(c)
+			}
+			b.ReportMetric(float64(objects)/float64(b.N), "heap-objects/op")
+		})
+	}
+}
+
+func heapObjects() uint64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapObjects
+}
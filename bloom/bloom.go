@@ -0,0 +1,92 @@
+package bloom
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// Filter 是一个并发安全的布隆过滤器，用于快速判断 key 一定不存在
+// MayContain 返回 false 时 key 一定没有被加入过；返回 true 时 key 可能存在（有一定的误判率）
+type Filter struct {
+	mu   sync.RWMutex
+	bits []uint64 // 位数组
+	m    uint64   // 位数组的长度
+	k    int      // 每个 key 使用的哈希函数个数
+}
+
+// New 创建一个预计容纳 n 个 key、误判率约为 fpRate 的布隆过滤器
+// 实际加入的 key 超过 n 个时误判率会升高，但不会出现把存在的 key 判断为不存在的情况
+func New(n int, fpRate float64) *Filter {
+	if n <= 0 {
+		n = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		panic("bloom: fpRate must be in (0, 1)")
+	}
+
+	// m = -n*ln(p) / (ln2)^2，k = m/n * ln2
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := int(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &Filter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// Add 将 key 加入过滤器
+func (f *Filter) Add(key string) {
+	h1, h2 := hashes(key)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := 0; i < f.k; i++ {
+		idx := (h1 + uint64(i)*h2) % f.m
+		f.bits[idx/64] |= 1 << (idx % 64)
+	}
+}
+
+// MayContain 判断 key 是否可能在过滤器中，返回 false 时 key 一定不在
+func (f *Filter) MayContain(key string) bool {
+	h1, h2 := hashes(key)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for i := 0; i < f.k; i++ {
+		idx := (h1 + uint64(i)*h2) % f.m
+		if f.bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Reset 清空过滤器中的所有 key
+func (f *Filter) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.bits {
+		f.bits[i] = 0
+	}
+}
+
+// hashes 用一次 64 位 FNV 哈希得到两个哈希值，通过 h1 + i*h2 模拟 k 个哈希函数（double hashing）
+func hashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32
+	// h2 为偶数时可能与 m 有公因数导致探测位置重复，保证它是奇数
+	return h1, h2 | 1
+}
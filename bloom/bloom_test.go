@@ -0,0 +1,36 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	f := New(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add("key" + strconv.Itoa(i))
+	}
+
+	// 加入过的 key 一定能被找到
+	for i := 0; i < 1000; i++ {
+		if !f.MayContain("key" + strconv.Itoa(i)) {
+			t.Fatalf("false negative for key%d", i)
+		}
+	}
+
+	// 没加入过的 key 误判率应该接近设定值
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.MayContain("missing" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.03 {
+		t.Fatalf("false positive rate %.4f is too high", rate)
+	}
+
+	f.Reset()
+	if f.MayContain("key0") {
+		t.Fatal("key0 should be absent after Reset")
+	}
+}
@@ -1,50 +1,470 @@
 package mini_groupcache
 
 import (
+	"fmt"
+	"log"
 	"mini-groupcache/lru"
+	"strings"
 	"sync"
+	"time"
+	"unsafe"
 )
 
-// cache 封装 lru 的缓存，在其基础上提供互斥锁保证并发安全
+// cache 封装底层的 Store（默认为 lru 的缓存），在其基础上提供读写锁保证并发安全
+// 查询只持有读锁，可以并发进行；LRU 在命中时需要调整访问顺序，这一步记录在 reads 中，推迟到下一次持有写锁时批量完成，见 getStale
 type cache struct {
-	mu         sync.Mutex // 同步化，实现并发安全的缓存
-	lru        *lru.Cache // 使用 lru 缓存作为引擎
-	cacheBytes int64
+	mu         sync.RWMutex  // 同步化，实现并发安全的缓存
+	store      Store         // 缓存引擎，第一次写入时由 newStore 创建，默认为 lru.Cache
+	cacheBytes int64         // 缓存的最大字节数，0 表示关闭缓存，UnlimitedCacheBytes 表示不限制
+	staleGrace time.Duration // 条目过期后继续保留的时间，期间只会在背压时作为旧值返回
+
+	pinOverflow PinOverflowPolicy // 固定的条目占满容量时的处理方式
+	arena       *arena            // 存放小值的 slab 分配器，nil 表示不使用
+
+	onEvict func(key string, value ByteView, reason lru.EvictionReason) // 条目离开缓存时的通知，可选，见 WithOnEvictedReason
+
+	expiryCandidates int // 淘汰时参与比较过期时间的候选条目数，见 WithExpiryAwareEviction
+
+	policy lru.EvictionPolicy // 容量不足时的淘汰策略，见 WithEvictionPolicy
+
+	newStore StoreFunc // 创建缓存引擎，nil 时使用 lru.Cache，见 WithStore
+
+	reads readBuffer // 只持有读锁时命中、还没有提升访问顺序的 key
+
+	nevict       int64     // 累计淘汰的条目数
+	newKeyRate   rateMeter // 新 key 写入速率
+	evictionRate rateMeter // 淘汰速率
+}
+
+// PinOverflowPolicy 决定固定的条目（见 Group.Pin）占满缓存容量、淘汰已经无法释放空间时的处理方式
+type PinOverflowPolicy int
+
+const (
+	// PinOverflowReject 不允许超出容量：超出容量的 Pin 返回 ErrCacheFull；放不下的值不写入缓存（仍然返回给调用方），
+	// 如果是更新已有的条目，旧值会被删除，不会留下过时的数据
+	PinOverflowReject PinOverflowPolicy = iota
+	// PinOverflowAllow 允许缓存暂时超出容量并打印警告（如固定的条目更新成了更大的值），直到固定的条目被 Unpin 或删除
+	PinOverflowAllow
+)
+
+// EvictionDelivery 决定 WithOnEvicted 设置的回调如何执行
+type EvictionDelivery int
+
+const (
+	// EvictionSync 在条目离开缓存的那一刻同步调用回调，此时持有缓存（分片）的锁，回调慢会阻塞这个分片上的所有读写（默认）
+	EvictionSync EvictionDelivery = iota
+	// EvictionAsync 把回调提交到分组的后台任务池（见 WithWorkerPool）执行，淘汰不会等待回调
+	EvictionAsync
+)
+
+// Source 表示缓存值的来源
+type Source int
+
+const (
+	SourceGetter Source = iota // 由本节点的 getter 从数据源加载
+	SourcePeer                 // 从远程节点获取
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceGetter:
+		return "getter"
+	case SourcePeer:
+		return "peer"
+	}
+	return "unknown"
+}
+
+// cacheEntry 是实际存储在 lru 中的值：缓存值加上它的元数据
+type cacheEntry struct {
+	view      ByteView
+	loadedAt  time.Time // 加载进缓存的时间
+	expiresAt time.Time // 过期时间，零值表示永不过期
+	source    Source
+	slab      *slab // view 所在的 arena slab，不在 slab 中时为 nil
+
+	compressed bool // view 是否经过 WithCompression 压缩
+
+	ref *readRef // 在 readBuffer 中记录这个条目的读取，写入缓存时分配
+}
+
+// Len 实现 lru.Value 接口，只计算缓存值本身的大小
+func (e cacheEntry) Len() int {
+	return e.view.Len()
+}
+
+// cacheStats 是 cache 统计信息的快照
+type cacheStats struct {
+	bytes        int64
+	items        int64
+	overhead     int64
+	evictions    int64
+	newKeyRate   float64
+	evictionRate float64
+
+	arenaBytes int64 // arena 中 slab 实际占用的字节数
 }
 
-func (c *cache) add(key string, value ByteView) {
-	c.mu.Lock() // goroutine 到来时，加上互斥锁进入临界区
+// add 写入条目，PinOverflowReject 策略下固定的条目占满容量、放不下 e 时返回 ErrCacheFull
+func (c *cache) add(key string, e cacheEntry) error {
+	c.lock() // goroutine 到来时，加上互斥锁进入临界区
 	defer c.mu.Unlock()
 
-	if c.lru == nil { // 惰性载入缓存引擎
-		c.lru = lru.NewCache(c.cacheBytes, nil)
+	if c.cacheBytes == 0 { // 缓存已关闭
+		return nil
+	}
+	if e.loadedAt.IsZero() {
+		e.loadedAt = time.Now()
 	}
+	e.ref = &readRef{key: key}
 
-	c.lru.Add(key, value)
+	if c.store == nil { // 惰性载入缓存引擎
+		// Store 中 maxBytes 为 0 表示不限制容量
+		maxBytes := c.cacheBytes
+		if maxBytes < 0 {
+			maxBytes = 0
+		}
+		newStore := c.newStore
+		if newStore == nil {
+			newStore = c.newLRUStore
+		}
+		c.store = newStore(maxBytes, c.onEvicted)
+	}
+
+	// 通过写入前后的条目数和期间发生的淘汰数推算出这次写入是否是一个新 key，不需要额外的查找
+	// Store 中的过期时间是条目真正被删除的时间，比 expiresAt 多保留 staleGrace
+	expire := e.expiresAt
+	if !expire.IsZero() {
+		expire = expire.Add(c.staleGrace)
+	}
+	// 覆盖已有的值时旧值所在的 slab 在 onEvicted 中释放
+	if c.arena != nil {
+		e.view, e.slab = c.arena.alloc(e.view)
+	}
+
+	items, evicted := c.store.Len(), c.nevict
+	if ps, ok := c.store.(pinningStore); ok && c.pinOverflow == PinOverflowReject {
+		if err := ps.TryAddWithExpire(key, e, expire); err != nil {
+			c.freeSlab(e.slab)
+			ps.Remove(key)
+			return err
+		}
+	} else {
+		c.store.AddWithExpire(key, e, expire)
+		if c.cacheBytes > 0 && c.store.Bytes() > c.cacheBytes {
+			log.Printf("[Groupcache] cache is over budget (%d > %d bytes) because of pinned entries", c.store.Bytes(), c.cacheBytes)
+		}
+	}
+	if n := int64(c.store.Len()-items) + c.nevict - evicted; n > 0 {
+		c.newKeyRate.mark(time.Now(), n)
+	}
+	return nil
 }
 
-func (c *cache) get(key string) (value ByteView, ok bool) {
+// budget 返回缓存当前的容量，取值与 cacheBytes 相同
+func (c *cache) budget() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cacheBytes
+}
+
+// resize 把缓存的容量改为 cacheBytes（取值与 NewGroup 的 cacheBytes 相同），容量变小时在持有锁的情况下立即淘汰到新的容量以内
+// 改为 0（关闭缓存）时清空所有条目；固定的条目无法淘汰时缓存会暂时超出容量，与 PinOverflowAllow 一样打印警告
+func (c *cache) resize(cacheBytes int64) {
+	c.lock()
+	defer c.mu.Unlock()
+
+	c.cacheBytes = cacheBytes
+	if c.store == nil {
+		// 还没有写入过，之后惰性创建时直接使用新的容量
+		return
+	}
+	if cacheBytes == 0 {
+		c.store.Clear()
+		return
+	}
+	maxBytes := cacheBytes
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	c.store.Resize(maxBytes)
+	if cacheBytes > 0 && c.store.Bytes() > cacheBytes {
+		log.Printf("[Groupcache] cache is over budget (%d > %d bytes) after resize because of pinned entries", c.store.Bytes(), cacheBytes)
+	}
+}
+
+// lock 获取写锁，并把读锁期间记录的命中交给 Store 提升访问顺序
+func (c *cache) lock() {
 	c.mu.Lock()
+	c.drainReads()
+}
+
+// drainReads 排空 reads，此时已经持有写锁
+func (c *cache) drainReads() {
+	if c.store == nil {
+		return
+	}
+	c.reads.drain(func(key string) {
+		c.store.Get(key)
+	})
+}
+
+// onEvicted 在 Store 删除条目或者覆盖条目的值时调用，此时已经持有 c.mu，被覆盖的值不计入淘汰数
+func (c *cache) onEvicted(key string, value lru.Value, reason lru.EvictionReason) {
+	if reason != lru.Replaced {
+		c.nevict++
+		c.evictionRate.mark(time.Now(), 1)
+	}
+	e := value.(cacheEntry)
+	c.freeSlab(e.slab)
+	if c.onEvict != nil {
+		c.onEvict(key, e.view, reason)
+	}
+}
+
+// freeSlab 在 slab 中的一个值离开缓存时调用，此时已经持有 c.mu
+func (c *cache) freeSlab(s *slab) {
+	if c.arena != nil {
+		c.arena.free(s)
+	}
+}
+
+func (c *cache) get(key string) (value ByteView, ok bool) {
+	e, ok := c.getEntry(key)
+	return e.view, ok
+}
+
+// getEntry 在一次加锁内同时取出缓存值和它的元数据，已经过期的条目视为不存在
+func (c *cache) getEntry(key string) (e cacheEntry, ok bool) {
+	e, ok = c.getStale(key)
+	if ok && !e.expiresAt.IsZero() && !time.Now().Before(e.expiresAt) {
+		return cacheEntry{}, false
+	}
+	return e, ok
+}
+
+// getStale 与 getEntry 相同，但还在 staleGrace 内的过期条目也会返回
+// Store 支持 Peek 时只持有读锁，命中的 key 记录在 reads 中，由之后持有写锁的操作（写入、删除、统计等）批量调用 Store.Get 提升访问顺序，
+// 每记录 readBufferSize 次还会在写锁空闲时主动排空一次。代价是访问顺序和命中计数的更新有延迟，同一个条目在一次排空之前的多次命中只计一次，
+// 缓冲区满了之后的命中会被丢弃；读多写少时被频繁读取的条目仍然会被及时提升，但淘汰顺序只是近似的 LRU，AccessHistogram 的命中次数也偏少
+// Store 在 staleGrace 之后删除过期条目这一步同样推迟到排空时
+// lru.LFU 按访问次数淘汰，合并后的命中会让热点条目的频率偏低，所以 LFU 始终在写锁内查询，不使用读锁
+func (c *cache) getStale(key string) (e cacheEntry, ok bool) {
+	c.mu.RLock()
+	ps, peek := c.store.(peekingStore)
+	if !peek || c.policy == lru.LFU {
+		c.mu.RUnlock()
+		return c.getLocked(key)
+	}
+	v, ok := ps.Peek(key)
+	c.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	// 过期的 key 同样记录下来，排空时由 Store.Get 删除
+	e = v.(cacheEntry)
+	if c.reads.record(e.ref) && c.mu.TryLock() {
+		c.drainReads()
+		c.mu.Unlock()
+	}
+	if !e.expiresAt.IsZero() && !time.Now().Before(e.expiresAt.Add(c.staleGrace)) {
+		return cacheEntry{}, false
+	}
+	return e, true
+}
+
+// getLocked 在写锁内用 Store.Get 查找，用于不支持 Peek 的 Store 和 LFU
+func (c *cache) getLocked(key string) (e cacheEntry, ok bool) {
+	c.lock()
 	defer c.mu.Unlock()
 
-	if c.lru == nil {
+	if c.store == nil {
 		return
 	}
 
-	v, ok := c.lru.Get(key)
+	v, ok := c.store.Get(key)
 	if !ok {
 		return
 	}
 
-	// ByteView 实现了 Value 接口，返回的 v 是一个接口类型，这里可以直接对 v 使用断言
-	return v.(ByteView), true
+	// cacheEntry 实现了 Value 接口，返回的 v 是一个接口类型，这里可以直接对 v 使用断言
+	return v.(cacheEntry), true
+}
+
+// remove 删除 key 对应的条目，返回 key 是否在缓存中
+func (c *cache) remove(key string) bool {
+	c.lock()
+	defer c.mu.Unlock()
+
+	return c.store != nil && c.store.Remove(key)
+}
+
+// clear 清空缓存，每个条目都按淘汰处理（计入淘汰数、通知 WithOnEvicted 设置的回调）
+func (c *cache) clear() {
+	c.lock()
+	defer c.mu.Unlock()
+
+	if c.store != nil {
+		c.store.Clear()
+	}
+}
+
+// keysWithPrefix 返回所有以 prefix 开头的 key，从最近访问的开始，需要遍历整个缓存
+func (c *cache) keysWithPrefix(prefix string) []string {
+	c.lock()
+	defer c.mu.Unlock()
+
+	if c.store == nil {
+		return nil
+	}
+	return c.matchPrefix(prefix)
+}
+
+// removePrefix 在一次加锁内删除所有以 prefix 开头的 key，返回删除的 key
+func (c *cache) removePrefix(prefix string) []string {
+	c.lock()
+	defer c.mu.Unlock()
+
+	if c.store == nil {
+		return nil
+	}
+	keys := c.matchPrefix(prefix)
+	for _, key := range keys {
+		c.store.Remove(key)
+	}
+	return keys
+}
+
+// matchPrefix 遍历缓存找出以 prefix 开头的 key，此时已经持有 c.mu
+func (c *cache) matchPrefix(prefix string) []string {
+	var keys []string
+	c.store.Range(func(key string, _ lru.Value) bool {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
+// pin 固定 key 对应的条目，固定后的总容量超过缓存容量时返回错误，key 不在缓存中（或者 Store 不支持固定）时 ok 为 false
+func (c *cache) pin(key string) (ok bool, err error) {
+	c.lock()
+	defer c.mu.Unlock()
+
+	ps, ok := c.store.(pinningStore)
+	if !ok {
+		return false, nil
+	}
+	before := ps.PinnedBytes()
+	if !ps.Pin(key) {
+		return false, nil
+	}
+	// 固定的条目不能被淘汰，总量超过容量时缓存再也放不下其它条目，按策略撤销这次固定或者只打印警告
+	if c.cacheBytes > 0 && ps.PinnedBytes() > c.cacheBytes {
+		if c.pinOverflow == PinOverflowAllow {
+			log.Printf("[Groupcache] pinned entries (%d bytes) exceed the cache budget of %d bytes", ps.PinnedBytes(), c.cacheBytes)
+			return true, nil
+		}
+		if ps.PinnedBytes() != before {
+			ps.Unpin(key)
+		}
+		return true, fmt.Errorf("pinning %q would exceed the cache budget of %d bytes: %w", key, c.cacheBytes, ErrCacheFull)
+	}
+	return true, nil
+}
+
+func (c *cache) unpin(key string) {
+	c.lock()
+	defer c.mu.Unlock()
+
+	if ps, ok := c.store.(pinningStore); ok {
+		ps.Unpin(key)
+	}
 }
 
 func (c *cache) removeOldest() {
-	c.mu.Lock()
+	c.lock()
+	defer c.mu.Unlock()
+
+	if c.store != nil {
+		c.store.RemoveOldest()
+	}
+}
+
+// resetStats 将累计淘汰数清零
+func (c *cache) resetStats() {
+	c.lock()
+	defer c.mu.Unlock()
+	c.nevict = 0
+}
+
+func (c *cache) stats() cacheStats {
+	c.lock()
 	defer c.mu.Unlock()
-	
-	if c.lru != nil {
-		c.lru.RemoveOldest()
+
+	now := time.Now()
+	s := cacheStats{
+		evictions:    c.nevict,
+		newKeyRate:   c.newKeyRate.value(now),
+		evictionRate: c.evictionRate.value(now),
+	}
+	if c.store != nil {
+		s.bytes = c.store.Bytes()
+		s.items = int64(c.store.Len())
+		// 每个 cacheEntry 作为 lru.Value 存入时会单独分配一份，另外还有一个 readRef
+		s.overhead = s.items * int64(unsafe.Sizeof(cacheEntry{})+unsafe.Sizeof(readRef{}))
+		if is, ok := c.store.(inspectableStore); ok {
+			s.overhead += is.Overhead()
+		}
 	}
+	if c.arena != nil {
+		s.arenaBytes = c.arena.bytes()
+	}
+	return s
+}
+
+func (c *cache) accessHistogram() []int {
+	c.lock()
+	defer c.mu.Unlock()
+
+	if is, ok := c.store.(inspectableStore); ok {
+		return is.AccessHistogram()
+	}
+	return nil
+}
+
+func (c *cache) oldestN(n int) []string {
+	c.lock()
+	defer c.mu.Unlock()
+
+	if is, ok := c.store.(inspectableStore); ok {
+		return is.OldestN(n)
+	}
+	return nil
+}
+
+// keyedEntry 是带 key 的缓存条目，用于导出缓存内容
+type keyedEntry struct {
+	key string
+	cacheEntry
+}
+
+// entries 返回缓存中所有条目的快照，从最近访问的开始
+// 缓存值是只读的，快照只复制引用，加锁时间与条目数成正比，但不会在持有锁时做任何 IO
+func (c *cache) entries() []keyedEntry {
+	c.lock()
+	defer c.mu.Unlock()
+
+	if c.store == nil {
+		return nil
+	}
+
+	entries := make([]keyedEntry, 0, c.store.Len())
+	c.store.Range(func(key string, value lru.Value) bool {
+		entries = append(entries, keyedEntry{key: key, cacheEntry: value.(cacheEntry)})
+		return true
+	})
+	return entries
 }
@@ -0,0 +1,163 @@
+package mini_groupcache
+
+import (
+	"context"
+	"fmt"
+	"mini-groupcache/consistenthash"
+	"mini-groupcache/testpb"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// peerRing 是客户端路由需要的全部状态：哈希环加上每个节点对应的 httpGetter
+// 它由 HTTPPool（缓存节点）和 Client（不缓存数据的外部客户端）共用
+type peerRing struct {
+	peers *consistenthash.Map // 整个哈希环上的真实和虚拟节点，用来根据 key 选择相应的节点
+	// 映射远程节点与对应的 httpGetter，每一个远程节点对应一个 httpGetter，因为 httpGetter 与远程节点的地址 baseURL 有关
+	// 这里就是持有每个节点与之对应的 http 请求地址
+	// 如：http://localhost:8001 -> http://localhost:8001/_groupcache/  http://localhost:8002 -> http://localhost:8002/_groupcache/
+	httpGetters map[string]*httpGetter
+}
+
+// getterOptions 是创建哈希环时应用到每个 httpGetter 上的配置，零值表示都不开启
+type getterOptions struct {
+	sem            chan struct{} // 所有节点共享的并发请求信号量，nil 表示不限制
+	coalesceWindow time.Duration // 合并单个 key 请求的时间窗口，0 表示不合并
+}
+
+// newPeerRing 根据节点地址创建哈希环，peers 需要事先经过 validatePeers 的校验
+// maxVirtualNodes 是虚拟节点总数的上限（0 表示不限制），超过时返回 consistenthash.ErrTooManyVirtualNodes
+func newPeerRing(peers []string, basePath string, maxVirtualNodes int, opts getterOptions) (*peerRing, error) {
+	r := &peerRing{
+		// 创建哈希环，默认创建 50 倍的虚拟节点
+		peers:       consistenthash.New(defaultReplicas, nil),
+		httpGetters: make(map[string]*httpGetter, len(peers)),
+	}
+	r.peers.SetMaxVirtualNodes(maxVirtualNodes)
+	// 将真实节点加入哈希环
+	if err := r.peers.AddE(peers...); err != nil {
+		return nil, err
+	}
+
+	// 存储所有节点的服务请求地址
+	// 如 http://localhost:8001 -> http://localhost:8001/_groupcache/
+	for _, peer := range peers {
+		h := &httpGetter{baseURL: peer + basePath, sem: opts.sem}
+		if opts.coalesceWindow > 0 {
+			h.coalescer = newCoalescer(h, opts.coalesceWindow)
+		}
+		r.httpGetters[peer] = h
+	}
+
+	return r, nil
+}
+
+// pick 返回 key 所属的节点地址及对应的 httpGetter，哈希环为空时返回空字符串
+func (r *peerRing) pick(key string) (string, *httpGetter) {
+	peer := r.peers.Get(key)
+	if peer == "" {
+		return "", nil
+	}
+	return peer, r.httpGetters[peer]
+}
+
+// DuplicatePeerPolicy 决定节点列表中出现重复地址时的处理方式（合并多个配置来源时很容易出现）
+type DuplicatePeerPolicy int
+
+const (
+	// DuplicatePeersIgnore 忽略重复的地址，每个节点只加入哈希环一次，这是默认的行为
+	DuplicatePeersIgnore DuplicatePeerPolicy = iota
+	// DuplicatePeersError 出现重复的地址时返回错误，用于尽早发现配置问题
+	DuplicatePeersError
+)
+
+// validatePeers 校验并去重节点地址，在启动时就暴露配置错误，而不是等到请求时才发现拼出的地址无法访问
+// 只差一个结尾斜杠的地址视为同一个节点；maxPeers 为 0 时不限制节点数量
+func validatePeers(peers []string, maxPeers int, policy DuplicatePeerPolicy) ([]string, error) {
+	seen := make(map[string]bool, len(peers))
+	unique := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		u, err := url.Parse(peer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer address %q: %v", peer, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid peer address %q: scheme and host are required", peer)
+		}
+		// 地址会直接拼上 basePath，去掉结尾的斜杠，避免拼出 //_groupcache/ 或者同一个节点以两个名字出现在哈希环上
+		peer = strings.TrimSuffix(peer, "/")
+		if seen[peer] {
+			if policy == DuplicatePeersError {
+				return nil, fmt.Errorf("duplicate peer address %q", peer)
+			}
+			continue
+		}
+		seen[peer] = true
+		unique = append(unique, peer)
+	}
+
+	if maxPeers > 0 && len(unique) > maxPeers {
+		return nil, fmt.Errorf("too many peers: %d, max %d", len(unique), maxPeers)
+	}
+
+	return unique, nil
+}
+
+// Client 是一个轻量的集群客户端
+// 它与缓存节点使用相同的一致性哈希路由，直接把请求发给 key 所属的节点，
+// 适合只需要读取缓存、本身并不作为缓存节点运行的服务
+type Client struct {
+	mu   sync.Mutex
+	ring *peerRing
+}
+
+// NewClient 创建一个客户端，peers 必须与缓存节点 Set 的节点列表一致，否则路由结果会不同
+func NewClient(peers ...string) (*Client, error) {
+	c := &Client{}
+	if err := c.Set(peers...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Set 更新客户端的节点列表
+func (c *Client) Set(peers ...string) error {
+	peers, err := validatePeers(peers, 0, DuplicatePeersIgnore)
+	if err != nil {
+		return err
+	}
+
+	ring, err := newPeerRing(peers, defaultBasePath, consistenthash.DefaultMaxVirtualNodes, getterOptions{})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ring = ring
+
+	return nil
+}
+
+// Get 从 key 所属的节点获取分组 group 中的缓存值
+func (c *Client) Get(group, key string) ([]byte, error) {
+	if key == "" {
+		return nil, fmt.Errorf("key is required")
+	}
+
+	c.mu.Lock()
+	_, getter := c.ring.pick(key)
+	c.mu.Unlock()
+
+	if getter == nil {
+		return nil, fmt.Errorf("no peers available")
+	}
+
+	res := &testpb.Response{}
+	if err := getter.Get(context.Background(), &testpb.Request{Group: group, Key: key}, res); err != nil {
+		return nil, err
+	}
+	return res.Value, nil
+}
@@ -0,0 +1,35 @@
+package mini_groupcache
+
+import "testing"
+
+func TestClient_Get(t *testing.T) {
+	NewGroup("client", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value-" + key), nil
+	}))
+
+	a, srvA := newTestPool(t)
+	b, srvB := newTestPool(t)
+	a.Set(srvA.URL, srvB.URL)
+	b.Set(srvA.URL, srvB.URL)
+
+	client, err := NewClient(srvA.URL, srvB.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"Tom", "Jack", "Sam"} {
+		// 客户端与缓存节点的路由结果必须一致
+		if owner, _ := client.ring.pick(key); owner != a.snapshot().peers.Get(key) {
+			t.Fatalf("client routes %s to %s, want %s", key, owner, a.snapshot().peers.Get(key))
+		}
+
+		v, err := client.Get("client", key)
+		if err != nil || string(v) != "value-"+key {
+			t.Fatalf("Get(%s) = %q, %v", key, v, err)
+		}
+	}
+
+	if _, err := NewClient("localhost:8001"); err == nil {
+		t.Fatal("NewClient should reject malformed peers")
+	}
+}
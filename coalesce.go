@@ -0,0 +1,94 @@
+package mini_groupcache
+
+import (
+	"context"
+	"fmt"
+	"mini-groupcache/testpb"
+	"sync"
+	"time"
+)
+
+// maxCoalescedKeys 是一个合并批次最多包含的 key 数，达到后不再等待时间窗口，立即发送
+const maxCoalescedKeys = 64
+
+// coalescer 把短时间内发往同一个节点的单个 key 请求合并成批量请求，见 WithCoalesceWindow
+type coalescer struct {
+	getter *httpGetter
+	window time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*coalescedBatch // 每个分组正在收集的批次
+}
+
+// coalescedBatch 是一个正在收集或已经发出的批次
+type coalescedBatch struct {
+	group string
+	keys  []string
+
+	done chan struct{} // 批量请求完成后关闭，之后 res 和 err 不再变化
+	res  *testpb.BatchResponse
+	err  error
+}
+
+func newCoalescer(getter *httpGetter, window time.Duration) *coalescer {
+	return &coalescer{
+		getter:  getter,
+		window:  window,
+		pending: make(map[string]*coalescedBatch),
+	}
+}
+
+// get 把 in.Key 加入分组当前的批次，等待批量请求完成后取出自己的结果
+// 调用方的 ctx 结束时不再等待，但不会取消批次中其它 key 的请求
+func (c *coalescer) get(ctx context.Context, in *testpb.Request, out *testpb.Response) error {
+	c.mu.Lock()
+	b := c.pending[in.GetGroup()]
+	if b == nil {
+		b = &coalescedBatch{group: in.GetGroup(), done: make(chan struct{})}
+		c.pending[b.group] = b
+		time.AfterFunc(c.window, func() { c.flush(b) })
+	}
+	i := len(b.keys)
+	b.keys = append(b.keys, in.GetKey())
+	full := len(b.keys) >= maxCoalescedKeys
+	c.mu.Unlock()
+
+	if full {
+		go c.flush(b)
+	}
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if b.err != nil {
+		return b.err
+	}
+	res := b.res.Responses[i]
+	if err := responseError(res); err != nil {
+		return err
+	}
+	out.Value, out.TtlMs, out.Version, out.NoStore = res.Value, res.TtlMs, res.Version, res.NoStore
+	return nil
+}
+
+// flush 发送批次 b，b 已经被发送过时什么都不做（时间窗口到期和批次满了都会调用它）
+func (c *coalescer) flush(b *coalescedBatch) {
+	c.mu.Lock()
+	if c.pending[b.group] != b {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.pending, b.group)
+	c.mu.Unlock()
+
+	// 批次属于多个调用方，不使用其中任何一个的 ctx
+	res := &testpb.BatchResponse{}
+	err := c.getter.GetBatch(context.Background(), &testpb.BatchRequest{Group: b.group, Keys: batchKeys(b.keys)}, res)
+	if err == nil && len(res.GetResponses()) != len(b.keys) {
+		err = fmt.Errorf("peer returned %d responses for %d keys", len(res.GetResponses()), len(b.keys))
+	}
+	b.res, b.err = res, err
+	close(b.done)
+}
@@ -0,0 +1,106 @@
+package mini_groupcache
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io/ioutil"
+)
+
+// compressionRatioBounds 是压缩率分布各个区间的上界（压缩后大小占原始大小的百分比）
+var compressionRatioBounds = [...]int{10, 25, 50, 75, 100}
+
+// CompressionStats 是 WithCompression 开启时值压缩效果的统计，按写入缓存的次数累计，不随条目淘汰减少
+type CompressionStats struct {
+	Compressed     int64 // 压缩后存入缓存的值的个数
+	BelowThreshold int64 // 长度小于阈值、没有尝试压缩的值的个数
+	Incompressible int64 // 压缩之后没有变小、按原样存入的值的个数，占比高说明数据本身不适合压缩
+
+	UncompressedBytes int64 // 压缩存入的值压缩前的总字节数
+	StoredBytes       int64 // 这些值压缩后的总字节数，StoredBytes / UncompressedBytes 就是整体的压缩率
+
+	// RatioHistogram 是每个压缩存入的值压缩后大小占原始大小比例的分布，
+	// 区间依次为 [0,10%) [10%,25%) [25%,50%) [50%,75%) [75%,100%)
+	RatioHistogram [len(compressionRatioBounds)]int64
+}
+
+// compressor 在值写入缓存之前压缩长度不小于 threshold 的值，并统计压缩的效果
+type compressor struct {
+	threshold int
+
+	compressed        AtomicInt
+	belowThreshold    AtomicInt
+	incompressible    AtomicInt
+	uncompressedBytes AtomicInt
+	storedBytes       AtomicInt
+	ratios            [len(compressionRatioBounds)]AtomicInt
+}
+
+func newCompressor(threshold int) *compressor {
+	if threshold < 0 {
+		panic(fmt.Sprintf("invalid compression threshold %d", threshold))
+	}
+	return &compressor{threshold: threshold}
+}
+
+// compress 返回要存入缓存的值，ok 为 true 表示返回的是压缩后的值
+// 太短或压缩之后没有变小的值原样返回
+func (c *compressor) compress(v ByteView) (stored ByteView, ok bool) {
+	if v.Len() < c.threshold {
+		c.belowThreshold.Add(1)
+		return v, false
+	}
+
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(v.b)
+	w.Close()
+	if buf.Len() >= v.Len() {
+		c.incompressible.Add(1)
+		return v, false
+	}
+
+	c.compressed.Add(1)
+	c.uncompressedBytes.Add(int64(v.Len()))
+	c.storedBytes.Add(int64(buf.Len()))
+	ratio := buf.Len() * 100 / v.Len()
+	for i, bound := range compressionRatioBounds {
+		if ratio < bound {
+			c.ratios[i].Add(1)
+			break
+		}
+	}
+	return ByteView{b: buf.Bytes()}, true
+}
+
+// decompress 还原 compress 压缩过的值，缓存中的数据由 compress 产生，解压失败说明内存被破坏，直接 panic
+func (c *compressor) decompress(v ByteView) ByteView {
+	b, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(v.b)))
+	if err != nil {
+		panic(fmt.Sprintf("corrupt compressed cache value: %v", err))
+	}
+	return ByteView{b: b}
+}
+
+func (c *compressor) stats() *CompressionStats {
+	s := &CompressionStats{
+		Compressed:        c.compressed.Get(),
+		BelowThreshold:    c.belowThreshold.Get(),
+		Incompressible:    c.incompressible.Get(),
+		UncompressedBytes: c.uncompressedBytes.Get(),
+		StoredBytes:       c.storedBytes.Get(),
+	}
+	for i := range c.ratios {
+		s.RatioHistogram[i] = c.ratios[i].Get()
+	}
+	return s
+}
+
+func (c *compressor) resetStats() {
+	for _, n := range []*AtomicInt{&c.compressed, &c.belowThreshold, &c.incompressible, &c.uncompressedBytes, &c.storedBytes} {
+		n.reset()
+	}
+	for i := range c.ratios {
+		c.ratios[i].reset()
+	}
+}
@@ -0,0 +1,50 @@
+package mini_groupcache
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+)
+
+func TestGroup_Compression(t *testing.T) {
+	random := make([]byte, 256)
+	rand.Read(random)
+	values := map[string]string{
+		"small":  "tiny",
+		"text":   strings.Repeat("groupcache ", 100),
+		"random": string(random),
+	}
+	group := NewGroup("compression", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(values[key]), nil
+	}), WithCompression(64))
+
+	for i := 0; i < 2; i++ {
+		for k, want := range values {
+			if v, err := group.Get(k); err != nil || v.String() != want {
+				t.Fatalf("Get(%s) = %q, %v", k, v, err)
+			}
+		}
+	}
+
+	s := group.Stats()
+	c := s.Compression
+	if c == nil || c.Compressed != 1 || c.BelowThreshold != 1 || c.Incompressible != 1 {
+		t.Fatalf("unexpected compression stats: %+v", c)
+	}
+	if c.UncompressedBytes != int64(len(values["text"])) || c.StoredBytes >= c.UncompressedBytes/10 || c.RatioHistogram[0] != 1 {
+		t.Fatalf("unexpected compression stats: %+v", c)
+	}
+	// 缓存容量按压缩后的大小计算
+	if want := int64(len("small")+4+len("text")+len("random")+256) + c.StoredBytes; s.Bytes != want {
+		t.Fatalf("Bytes = %d, want %d", s.Bytes, want)
+	}
+
+	// 没有开启压缩时不统计
+	plain := NewGroup("no-compression", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	plain.Get("k")
+	if plain.Stats().Compression != nil {
+		t.Fatal("Compression should be nil when compression is disabled")
+	}
+}
@@ -1,20 +1,49 @@
 package consistenthash
 
 import (
+	"errors"
+	"fmt"
 	"hash/crc32"
+	"hash/fnv"
 	"sort"
 	"strconv"
 )
 
 type Hash func(data []byte) uint32
 
+// DefaultMaxVirtualNodes 是哈希环默认最多允许的虚拟节点总数，按 50 倍虚拟节点计算可以容纳两万个真实节点，
+// 远超实际的集群规模，只用来拦截节点列表或虚拟节点倍数配置错误导致的哈希环爆炸
+const DefaultMaxVirtualNodes = 1 << 20
+
+// ErrTooManyVirtualNodes 表示加入节点之后虚拟节点总数会超过上限，见 SetMaxVirtualNodes
+var ErrTooManyVirtualNodes = errors.New("consistenthash: too many virtual nodes")
+
 // Map 是一致性哈希算法的主结构
 // 什么是一致性哈希算法参考：https://www.zsythink.net/archives/1182
 type Map struct {
-	hash     Hash           // 哈希函数，用于计算 key
-	replicas int            // 虚拟节点倍数，虚拟节点越多，哈希环的节点分布更均匀，数据也分配得更均匀，查找节点的时间也能优化
-	keys     []int          // 哈希环 keys
-	hashMap  map[int]string // 虚拟节点与真实节点的映射表
+	hash     Hash     // 哈希函数，用于计算 key
+	replicas int      // 虚拟节点倍数，虚拟节点越多，哈希环的节点分布更均匀，数据也分配得更均匀，查找节点的时间也能优化
+	keys     []int    // 哈希环 keys
+	owners   []int    // 与 keys 一一对应，虚拟节点所属真实节点在 nodes 中的下标
+	nodes    []string // 真实节点的名称
+	// 虚拟节点与真实节点的映射用两个对齐的切片表示而不是 map[int]string：
+	// 节点数量很多时哈希环有几十万个虚拟节点，Get 在二分查找之后只需要一次切片下标访问，避免了缓存不友好的 map 查找
+
+	disabled  []bool // 与 nodes 一一对应，被暂时禁用的真实节点不会被 Get 选中
+	ndisabled int    // 被禁用的真实节点数，为 0 时 Get 不需要检查 disabled
+
+	zones  []string // 与 nodes 一一对应，真实节点所在的可用区，空字符串表示未设置
+	nzoned int      // 设置了可用区的真实节点数，为 0 时 GetN 不需要考虑可用区
+
+	collisions int // 哈希值与其它真实节点的虚拟节点相同、被对方挡住的虚拟节点数
+
+	maxVirtual int // 虚拟节点总数的上限，0 表示不限制
+}
+
+// CollisionReport 是哈希环虚拟节点冲突的诊断信息
+type CollisionReport struct {
+	VirtualNodes int // 哈希环上实际起作用的虚拟节点数（不同的哈希值个数），没有冲突时等于真实节点数乘以虚拟节点倍数
+	Collisions   int // 冲突的虚拟节点数，不为 0 说明有虚拟节点被挡住，key 的分布会有偏差
 }
 
 func New(replicas int, fn Hash) *Map {
@@ -23,7 +52,8 @@ func New(replicas int, fn Hash) *Map {
 		hash: fn,
 		// 允许自定义虚拟节点倍数
 		replicas: replicas,
-		hashMap:  make(map[int]string),
+
+		maxVirtual: DefaultMaxVirtualNodes,
 	}
 
 	if m.hash == nil {
@@ -34,26 +64,55 @@ func New(replicas int, fn Hash) *Map {
 	return m
 }
 
+// SetMaxVirtualNodes 设置虚拟节点总数（真实节点数乘以虚拟节点倍数）的上限，默认为 DefaultMaxVirtualNodes，n 为 0 时不限制
+// 只在之后加入节点时检查，不影响已经在哈希环上的节点
+func (m *Map) SetMaxVirtualNodes(n int) {
+	if n < 0 {
+		panic("consistenthash: negative max virtual nodes")
+	}
+	m.maxVirtual = n
+}
+
 // Add 向哈希环中插入节点
 // keys 允许传入多个真实节点的名称（通常使用分布式节点的名称/编号/IP地址）
+// Add 是 AddE 的便捷版本，虚拟节点总数超过上限时直接 panic
 func (m *Map) Add(keys ...string) {
+	if err := m.AddE(keys...); err != nil {
+		panic(err)
+	}
+}
+
+// AddE 与 Add 相同，但加入之后虚拟节点总数会超过上限时返回 ErrTooManyVirtualNodes，不修改哈希环，也不会分配虚拟节点的内存
+func (m *Map) AddE(keys ...string) error {
+	if err := m.checkSize(keys); err != nil {
+		return err
+	}
 	for _, key := range keys {
+		owner := m.nodeIndex(key)
+		if owner < 0 {
+			owner = len(m.nodes)
+			m.nodes = append(m.nodes, key)
+			m.disabled = append(m.disabled, false)
+			m.zones = append(m.zones, "")
+		}
 		// 对每一个真实节点 key 生成 m.replicas 个虚拟节点
 		// 如：真实节点 6/4/2 生成虚拟节点 6/16/26、4/14/24、2/12/22
 		for i := 0; i < m.replicas; i++ {
 			// 基于真实节点的名称创建 m.replicas 个虚拟节点
 			k := strconv.Itoa(i) + key
 			hash := int(m.hash([]byte(k)))
-			// 将所有虚拟节点保存到 m.keys
-			m.keys = append(m.keys, hash)
-			// 将每个虚拟节点存到映射表中，每个虚拟节点都对应真实节点
+			// 将所有虚拟节点保存到 m.keys，并在 m.owners 的相同位置记录它对应的真实节点
 			// 如：6 -> 6、16 -> 6、26 -> 6
-			m.hashMap[hash] = key
+			m.keys = append(m.keys, hash)
+			m.owners = append(m.owners, owner)
 		}
 	}
 	// 将虚拟节点升序排序
 	// 如：2, 4, 6, 12, 14, 16...
-	sort.Ints(m.keys)
+	// 哈希值相同的虚拟节点按真实节点的名称排序，排序结果与加入的顺序无关
+	sort.Sort(ring{m})
+	m.dedupe()
+	return nil
 }
 
 func (m *Map) Get(key string) string {
@@ -66,6 +125,7 @@ func (m *Map) Get(key string) string {
 	// 该方法一般用于从一个已经排序的数组中找到某个值所对应的索引，或者从数组中找到满足某个条件的最小索引
 	// 使用这个方法，就实现了哈希环上按顺时针找到最接近的节点的功能
 	// 如：查找节点 8 会被定位到虚拟节点列表中第一个大于等于 8 的元素，即虚拟节点 12，对应的下标是 3
+	// 多个虚拟节点哈希值相同时，找到的是其中真实节点名称最小的那个，路由结果与节点加入的顺序无关
 	idx := sort.Search(len(m.keys), func(i int) bool {
 		return m.keys[i] >= hash
 	})
@@ -74,24 +134,327 @@ func (m *Map) Get(key string) string {
 		idx = 0
 	}
 
-	// 用匹配到的虚拟节点从映射表中找到对应的真实节点
-	// 如：虚拟节点 12 映射到真实节点 2（Add 方法中添加的映射）
-	return m.hashMap[m.keys[idx]]
+	// 用匹配到的虚拟节点找到对应的真实节点
+	// 如：虚拟节点 12 对应真实节点 2（Add 方法中记录的 owners）
+	if m.ndisabled == 0 {
+		return m.nodes[m.owners[idx]]
+	}
+
+	// 跳过被禁用节点的虚拟节点，继续顺时针查找，所有节点都被禁用时返回空字符串
+	for i := 0; i < len(m.keys); i++ {
+		if owner := m.owners[(idx+i)%len(m.keys)]; !m.disabled[owner] {
+			return m.nodes[owner]
+		}
+	}
+	return ""
+}
+
+// Owner 返回不考虑禁用状态时 key 所属的节点，以及这个节点当前是否被禁用，哈希环为空时返回空字符串
+// 节点没有被禁用时与 Get 的结果相同；被禁用时 Get 会返回顺时针的下一个节点
+func (m *Map) Owner(key string) (node string, disabled bool) {
+	if m.IsEmpty() {
+		return "", false
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+	if idx == len(m.keys) {
+		idx = 0
+	}
+	owner := m.owners[idx]
+	return m.nodes[owner], m.disabled[owner]
+}
+
+// Disable 暂时禁用节点，它的虚拟节点保留在哈希环上，但 Get 和 GetN 会跳过它，原本属于它的 key 顺时针落到下一个节点
+// 适合节点短暂故障、反复上下线的场景，比 Remove 再 Add 代价小得多，也不会改变 Fingerprint
+func (m *Map) Disable(key string) {
+	if owner := m.nodeIndex(key); owner >= 0 && !m.disabled[owner] {
+		m.disabled[owner] = true
+		m.ndisabled++
+	}
+}
+
+// Enable 重新启用被 Disable 禁用的节点，它负责的 key 立即回到它身上
+func (m *Map) Enable(key string) {
+	if owner := m.nodeIndex(key); owner >= 0 && m.disabled[owner] {
+		m.disabled[owner] = false
+		m.ndisabled--
+	}
+}
+
+// SetZone 设置节点所在的可用区（或机架），GetN 会尽量把 key 的多个节点分散到不同的可用区，zone 为空表示清除
+// 可用区只影响 GetN 选出的后备节点，不影响 Get 和 Fingerprint
+func (m *Map) SetZone(key, zone string) {
+	owner := m.nodeIndex(key)
+	if owner < 0 {
+		return
+	}
+	if m.zones[owner] == "" && zone != "" {
+		m.nzoned++
+	} else if m.zones[owner] != "" && zone == "" {
+		m.nzoned--
+	}
+	m.zones[owner] = zone
+}
+
+// Zone 返回节点所在的可用区，没有设置时返回空字符串
+func (m *Map) Zone(key string) string {
+	if owner := m.nodeIndex(key); owner >= 0 {
+		return m.zones[owner]
+	}
+	return ""
+}
+
+// GetN 从 key 所在的位置开始沿哈希环顺时针查找，返回最多 n 个互不相同的真实节点
+// 第一个节点与 Get 的结果相同，之后的节点依次是 key 的后备节点
+// 设置了可用区时优先选择可用区还没有被选中过的节点，这样的节点不够 n 个时，再按顺时针的顺序用剩下的节点补足
+func (m *Map) GetN(key string, n int) []string {
+	if m.IsEmpty() || n <= 0 {
+		return nil
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+
+	var nodes []string
+	seen := make(map[int]bool)
+	zones := make(map[string]bool)
+	// 每一轮最多绕哈希环一圈，第一轮跳过可用区已经被选中过的节点，第二轮补足
+	for pass := 0; pass < 2 && len(nodes) < n; pass++ {
+		distinct := pass == 0 && m.nzoned > 0
+		for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+			owner := m.owners[(idx+i)%len(m.keys)]
+			if seen[owner] || m.disabled[owner] {
+				continue
+			}
+			if zone := m.zones[owner]; distinct && zone != "" {
+				if zones[zone] {
+					continue
+				}
+				zones[zone] = true
+			}
+			seen[owner] = true
+			nodes = append(nodes, m.nodes[owner])
+		}
+		if m.nzoned == 0 {
+			break
+		}
+	}
+
+	return nodes
+}
+
+// GetTwo 返回 key 的所属节点（与 Get 的结果相同）和顺时针方向上下一个不同的真实节点，用于对冲请求：所属节点响应慢时再向第二个节点请求
+// 跳过被禁用的节点，不考虑可用区；只有一个可用节点时 secondary 为空字符串，哈希环为空或所有节点都被禁用时都为空字符串
+func (m *Map) GetTwo(key string) (primary, secondary string) {
+	if m.IsEmpty() {
+		return "", ""
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+
+	first := -1
+	for i := 0; i < len(m.keys); i++ {
+		owner := m.owners[(idx+i)%len(m.keys)]
+		if m.disabled[owner] || owner == first {
+			continue
+		}
+		if first < 0 {
+			first = owner
+			continue
+		}
+		return m.nodes[first], m.nodes[owner]
+	}
+	if first < 0 {
+		return "", ""
+	}
+	return m.nodes[first], ""
 }
 
 // Remove 删除节点及其对应的虚拟节点
 func (m *Map) Remove(key string) {
-	for i := 0; i < m.replicas; i++ {
-		k := strconv.Itoa(i) + key
-		hash := m.hash([]byte(k))
-		// sort.SearchInts 在一串有序的 int 数组中找到给定的值下标
-		idx := sort.SearchInts(m.keys, int(hash))
-		m.keys = append(m.keys[:idx], m.keys[idx+1:]...)
-		delete(m.hashMap, int(hash))
+	owner := m.nodeIndex(key)
+	if owner < 0 {
+		return
+	}
+
+	// 原地过滤掉属于该节点的虚拟节点，keys 仍然保持有序，不需要重新排序
+	n := 0
+	for i := range m.keys {
+		if m.owners[i] == owner {
+			continue
+		}
+		m.keys[n] = m.keys[i]
+		m.owners[n] = m.owners[i]
+		// 被删除节点之后的真实节点在 nodes 中整体前移了一位
+		if m.owners[n] > owner {
+			m.owners[n]--
+		}
+		n++
+	}
+	m.keys = m.keys[:n]
+	m.owners = m.owners[:n]
+	m.nodes = append(m.nodes[:owner], m.nodes[owner+1:]...)
+	// 被挡住的虚拟节点一直保留在哈希环上，删除节点之后它们自动起作用，只需要重新统计冲突
+	m.dedupe()
+	if m.disabled[owner] {
+		m.ndisabled--
+	}
+	m.disabled = append(m.disabled[:owner], m.disabled[owner+1:]...)
+	if m.zones[owner] != "" {
+		m.nzoned--
+	}
+	m.zones = append(m.zones[:owner], m.zones[owner+1:]...)
+}
+
+// PreviewRemove 预览删除节点 key 之后，sampleKeys 中每个 key 的新归属节点，不会修改当前的哈希环
+// 可以在缩容前用当前缓存的 key 作为样本，评估有多少 key 会迁移、迁移到哪里
+func (m *Map) PreviewRemove(key string, sampleKeys []string) map[string]string {
+	clone := m.Clone()
+	clone.Remove(key)
+
+	owners := make(map[string]string, len(sampleKeys))
+	for _, k := range sampleKeys {
+		owners[k] = clone.Get(k)
+	}
+	return owners
+}
+
+// checkSize 检查加入 keys 中的新节点之后虚拟节点总数是否超过上限
+func (m *Map) checkSize(keys []string) error {
+	if m.maxVirtual == 0 || m.replicas <= 0 {
+		return nil
+	}
+	// 用除法比较，避免节点数乘以倍数时溢出；连同重复的 key 一起都放得下时不需要逐个去重
+	limit := m.maxVirtual / m.replicas
+	if len(m.nodes)+len(keys) <= limit {
+		return nil
+	}
+	seen := make(map[string]bool, len(m.nodes)+len(keys))
+	for _, node := range m.nodes {
+		seen[node] = true
+	}
+	n := len(m.nodes)
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			n++
+		}
 	}
-	sort.Ints(m.keys)
+	if n > limit {
+		return fmt.Errorf("%w: %d nodes with %d replicas each exceed the limit of %d", ErrTooManyVirtualNodes, n, m.replicas, m.maxVirtual)
+	}
+	return nil
+}
+
+// Clone 深拷贝哈希环，修改副本不会影响原来的哈希环，可以用来实现写时复制
+func (m *Map) Clone() *Map {
+	c := &Map{
+		hash:     m.hash,
+		replicas: m.replicas,
+		keys:     make([]int, len(m.keys)),
+		owners:   make([]int, len(m.owners)),
+		nodes:    make([]string, len(m.nodes)),
+		disabled: make([]bool, len(m.disabled)),
+
+		ndisabled: m.ndisabled,
+		zones:     make([]string, len(m.zones)),
+		nzoned:    m.nzoned,
+
+		collisions: m.collisions,
+
+		maxVirtual: m.maxVirtual,
+	}
+	copy(c.keys, m.keys)
+	copy(c.owners, m.owners)
+	copy(c.nodes, m.nodes)
+	copy(c.disabled, m.disabled)
+	copy(c.zones, m.zones)
+	return c
 }
 
 func (m *Map) IsEmpty() bool {
 	return len(m.keys) == 0
 }
+
+// CollisionReport 返回起作用的虚拟节点数和冲突的虚拟节点数，开销只是读取两个计数
+// 冲突指属于不同真实节点的虚拟节点哈希值相同，这时由名称最小的真实节点负责这个位置，其它的被挡住，
+// 只有在前面的节点被禁用或删除之后才会起作用
+func (m *Map) CollisionReport() CollisionReport {
+	return CollisionReport{VirtualNodes: len(m.keys) - m.collisions, Collisions: m.collisions}
+}
+
+// Members 返回哈希环上所有真实节点的名称，按字典序排列
+func (m *Map) Members() []string {
+	members := make([]string, len(m.nodes))
+	copy(members, m.nodes)
+	sort.Strings(members)
+	return members
+}
+
+// Fingerprint 返回哈希环成员的指纹，由虚拟节点倍数和排序后的真实节点计算得到
+// 节点加入的顺序不影响指纹，两个节点的指纹相同说明它们会把同一个 key 路由到同一个节点
+func (m *Map) Fingerprint() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(strconv.Itoa(m.replicas)))
+	for _, member := range m.Members() {
+		// 以 0 字节分隔，避免 "ab"+"c" 与 "a"+"bc" 得到相同的指纹
+		h.Write([]byte{0})
+		h.Write([]byte(member))
+	}
+	return h.Sum64()
+}
+
+// nodeIndex 返回真实节点在 nodes 中的下标，不存在时返回 -1
+func (m *Map) nodeIndex(key string) int {
+	for i, node := range m.nodes {
+		if node == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// dedupe 去掉同一个真实节点重复的虚拟节点（同一个节点重复 Add 时产生），并重新统计冲突的虚拟节点数
+// 属于不同真实节点的同一个哈希值都保留下来，这样哈希环的内容只取决于节点集合，与 Add 和 Remove 的顺序无关
+func (m *Map) dedupe() {
+	n := 0
+	m.collisions = 0
+	for i := range m.keys {
+		if n > 0 && m.keys[n-1] == m.keys[i] {
+			if m.owners[n-1] == m.owners[i] {
+				continue
+			}
+			m.collisions++
+		}
+		m.keys[n] = m.keys[i]
+		m.owners[n] = m.owners[i]
+		n++
+	}
+	m.keys = m.keys[:n]
+	m.owners = m.owners[:n]
+}
+
+// ring 按哈希值对 keys 排序，同时同步交换 owners，保证两个切片始终对齐
+type ring struct {
+	m *Map
+}
+
+func (r ring) Len() int { return len(r.m.keys) }
+func (r ring) Less(i, j int) bool {
+	if r.m.keys[i] != r.m.keys[j] {
+		return r.m.keys[i] < r.m.keys[j]
+	}
+	return r.m.nodes[r.m.owners[i]] < r.m.nodes[r.m.owners[j]]
+}
+func (r ring) Swap(i, j int) {
+	r.m.keys[i], r.m.keys[j] = r.m.keys[j], r.m.keys[i]
+	r.m.owners[i], r.m.owners[j] = r.m.owners[j], r.m.owners[i]
+}
@@ -1,7 +1,9 @@
 package consistenthash
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -14,14 +16,14 @@ func TestHashing(t *testing.T) {
 		i, _ := strconv.Atoi(string(key))
 		return uint32(i)
 	})
-	
+
 	hash.Add("6", "4", "2")
 
 	fmt.Println(hash.Get("2"))
 	fmt.Println(hash.Get("4"))
 	fmt.Println(hash.Get("6"))
 	fmt.Println(hash.Get("8"))
-	
+
 	hash.Remove("4")
 }
 
@@ -43,4 +45,355 @@ func TestConsistency(t *testing.T) {
 		hash1.Get("Bonny") != hash2.Get("Bonny") {
 		t.Errorf("Direct matches should always return the same entry")
 	}
-}
\ No newline at end of file
+}
+
+func TestFingerprint(t *testing.T) {
+	hash1 := New(3, nil)
+	hash2 := New(3, nil)
+
+	hash1.Add("a", "b", "c")
+	hash2.Add("c", "a", "b")
+
+	if hash1.Fingerprint() != hash2.Fingerprint() {
+		t.Errorf("the same members should have the same fingerprint")
+	}
+
+	hash2.Add("d")
+	if hash1.Fingerprint() == hash2.Fingerprint() {
+		t.Errorf("different members should have different fingerprints")
+	}
+
+	hash3 := New(5, nil)
+	hash3.Add("a", "b", "c")
+	if hash1.Fingerprint() == hash3.Fingerprint() {
+		t.Errorf("different replicas should have different fingerprints")
+	}
+}
+
+func TestGetN(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+
+	// 虚拟节点：2/4/6/12/14/16/22/24/26
+	hash.Add("6", "4", "2")
+
+	testCases := []struct {
+		key  string
+		n    int
+		want []string
+	}{
+		{"11", 2, []string{"2", "4"}},
+		{"23", 3, []string{"4", "6", "2"}},
+		{"27", 1, []string{"2"}},
+		{"27", 5, []string{"2", "4", "6"}},
+		{"27", 0, nil},
+	}
+	for _, tc := range testCases {
+		if got := hash.GetN(tc.key, tc.n); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("GetN(%s, %d) = %v, want %v", tc.key, tc.n, got, tc.want)
+		}
+		if got := hash.GetN(tc.key, tc.n); len(got) > 0 && got[0] != hash.Get(tc.key) {
+			t.Errorf("the first node of GetN(%s) should be %s", tc.key, hash.Get(tc.key))
+		}
+	}
+}
+
+func TestGetTwo(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+	if p, s := hash.GetTwo("1"); p != "" || s != "" {
+		t.Fatalf("GetTwo on an empty ring = %q, %q", p, s)
+	}
+
+	// 虚拟节点：2/4/6/12/14/16/22/24/26
+	hash.Add("6", "4", "2")
+	testCases := map[string][2]string{
+		"11": {"2", "4"},
+		"23": {"4", "6"},
+		"27": {"2", "4"},
+	}
+	for key, want := range testCases {
+		if p, s := hash.GetTwo(key); p != want[0] || s != want[1] {
+			t.Errorf("GetTwo(%s) = %s, %s, want %v", key, p, s, want)
+		}
+	}
+
+	// 跳过被禁用的节点，只剩一个节点时没有第二选择
+	hash.Disable("4")
+	if p, s := hash.GetTwo("23"); p != "6" || s != "2" {
+		t.Errorf("GetTwo(23) = %s, %s, want 6, 2", p, s)
+	}
+	hash.Disable("6")
+	if p, s := hash.GetTwo("23"); p != "2" || s != "" {
+		t.Errorf("GetTwo(23) = %s, %s, want 2 only", p, s)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+
+	hash.Add("6", "4", "2")
+	hash.Remove("4")
+
+	// 删除 4 之后，原本落在 04/14/24 上的 key 顺延到下一个虚拟节点
+	testCases := map[string]string{
+		"2":  "2",
+		"3":  "6",
+		"13": "6",
+		"23": "6",
+		"25": "6",
+		"27": "2",
+	}
+	for k, v := range testCases {
+		if got := hash.Get(k); got != v {
+			t.Errorf("Get(%s) = %s, want %s", k, got, v)
+		}
+	}
+
+	if members := hash.Members(); !reflect.DeepEqual(members, []string{"2", "6"}) {
+		t.Errorf("Members() = %v, want [2 6]", members)
+	}
+}
+
+// benchmarkGet 在 nodes 个真实节点、每个节点 50 个虚拟节点的哈希环上测试 Get 的吞吐
+func benchmarkGet(b *testing.B, nodes int) {
+	peers := make([]string, nodes)
+	for i := range peers {
+		peers[i] = fmt.Sprintf("http://10.0.%d.%d:8001", i/256, i%256)
+	}
+	hash := New(50, nil)
+	hash.Add(peers...)
+
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hash.Get(keys[i&(len(keys)-1)])
+	}
+}
+
+func BenchmarkGet8(b *testing.B)    { benchmarkGet(b, 8) }
+func BenchmarkGet512(b *testing.B)  { benchmarkGet(b, 512) }
+func BenchmarkGet4096(b *testing.B) { benchmarkGet(b, 4096) }
+
+func TestPreviewRemove(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+	hash.Add("6", "4", "2")
+
+	got := hash.PreviewRemove("4", []string{"3", "11", "23", "27"})
+	want := map[string]string{"3": "6", "11": "2", "23": "6", "27": "2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("PreviewRemove = %v, want %v", got, want)
+	}
+
+	// 预览不会修改哈希环
+	if hash.Get("3") != "4" || len(hash.Members()) != 3 {
+		t.Fatal("PreviewRemove should not mutate the ring")
+	}
+}
+
+func TestDisable(t *testing.T) {
+	hash := New(50, nil)
+	hash.Add("a", "b", "c")
+
+	keys := make([]string, 100)
+	owners := make(map[string]string)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		owners[keys[i]] = hash.Get(keys[i])
+	}
+	fingerprint := hash.Fingerprint()
+
+	hash.Disable("b")
+	for _, k := range keys {
+		got := hash.Get(k)
+		if got == "b" {
+			t.Fatalf("disabled node b should not be picked for %s", k)
+		}
+		// 只有原本属于 b 的 key 会迁移
+		if owners[k] != "b" && got != owners[k] {
+			t.Fatalf("key %s moved from %s to %s", k, owners[k], got)
+		}
+		// Owner 仍然返回原本的节点，并标记它是否被禁用
+		if owner, disabled := hash.Owner(k); owner != owners[k] || disabled != (owner == "b") {
+			t.Fatalf("Owner(%s) = %s, %v, want %s", k, owner, disabled, owners[k])
+		}
+	}
+	if nodes := hash.GetN("x", 3); len(nodes) != 2 {
+		t.Fatalf("GetN = %v, want the two enabled nodes", nodes)
+	}
+	if hash.Fingerprint() != fingerprint {
+		t.Fatal("Disable should not change the fingerprint")
+	}
+
+	hash.Enable("b")
+	for _, k := range keys {
+		if got := hash.Get(k); got != owners[k] {
+			t.Fatalf("key %s is owned by %s after Enable, want %s", k, got, owners[k])
+		}
+	}
+
+	hash.Disable("a")
+	hash.Disable("b")
+	hash.Disable("c")
+	if got := hash.Get("x"); got != "" {
+		t.Fatalf("Get = %q with every node disabled, want empty", got)
+	}
+	hash.Remove("a")
+	hash.Enable("c")
+	if got := hash.Get("x"); got != "c" {
+		t.Fatalf("Get = %q, want the only enabled node c", got)
+	}
+}
+
+func TestZones(t *testing.T) {
+	hash := New(50, nil)
+	hash.Add("a1", "a2", "a3", "b1", "b2", "c1")
+	for _, node := range []string{"a1", "a2", "a3"} {
+		hash.SetZone(node, "a")
+	}
+	hash.SetZone("b1", "b")
+	hash.SetZone("b2", "b")
+	hash.SetZone("c1", "c")
+
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		nodes := hash.GetN(key, 3)
+		if len(nodes) != 3 || nodes[0] != hash.Get(key) {
+			t.Fatalf("GetN(%s) = %v, first node should be %s", key, nodes, hash.Get(key))
+		}
+		// 三个可用区各选一个节点
+		zones := map[string]bool{}
+		for _, node := range nodes {
+			zones[hash.Zone(node)] = true
+		}
+		if len(zones) != 3 {
+			t.Fatalf("GetN(%s) = %v, want nodes from 3 distinct zones", key, nodes)
+		}
+		// 可用区不够时用剩下的节点补足
+		if nodes := hash.GetN(key, 5); len(nodes) != 5 {
+			t.Fatalf("GetN(%s, 5) = %v, want 5 nodes", key, nodes)
+		}
+	}
+
+	hash.Remove("c1")
+	if hash.Zone("b1") != "b" || hash.Zone("c1") != "" {
+		t.Fatal("zones should stay aligned with nodes after Remove")
+	}
+}
+
+func TestCollisionReport(t *testing.T) {
+	// 只用第一个字节（虚拟节点序号）做哈希，不同节点的同序号虚拟节点一定冲突
+	hash := New(3, func(data []byte) uint32 {
+		return uint32(data[0])
+	})
+	hash.Add("a")
+	if r := hash.CollisionReport(); r != (CollisionReport{VirtualNodes: 3}) {
+		t.Fatalf("CollisionReport = %+v, want 3 virtual nodes and no collisions", r)
+	}
+	// 重复加入同一个节点不算冲突
+	hash.Add("a")
+	hash.Add("b")
+	if r := hash.CollisionReport(); r != (CollisionReport{VirtualNodes: 3, Collisions: 3}) {
+		t.Fatalf("CollisionReport = %+v, want 3 collisions", r)
+	}
+
+	hash = New(50, nil)
+	hash.Add("http://localhost:8001", "http://localhost:8002", "http://localhost:8003")
+	if r := hash.CollisionReport(); r.VirtualNodes != 150 || r.Collisions != 0 {
+		t.Fatalf("CollisionReport = %+v, want 150 virtual nodes without collisions", r)
+	}
+}
+
+func TestAddOrder(t *testing.T) {
+	// 只用第一个字节（虚拟节点序号）做哈希，不同节点的同序号虚拟节点全部冲突
+	collide := func(data []byte) uint32 {
+		return uint32(data[0])
+	}
+	nodes := []string{"n1", "n2", "n3", "n4"}
+	orders := [][]string{
+		{"n1", "n2", "n3", "n4"},
+		{"n4", "n3", "n2", "n1"},
+		{"n2", "n4", "n1", "n3"},
+	}
+
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, fn := range []Hash{collide, nil} {
+		want := New(10, fn)
+		want.Add(nodes...)
+		for _, order := range orders {
+			hash := New(10, fn)
+			for _, node := range order {
+				hash.Add(node)
+			}
+			for _, key := range keys {
+				if got := hash.Get(key); got != want.Get(key) {
+					t.Fatalf("order %v: Get(%s) = %s, want %s", order, key, got, want.Get(key))
+				}
+			}
+		}
+
+		// 删除节点之后与一开始就没有这个节点的哈希环一致
+		removed := New(10, fn)
+		removed.Add(nodes...)
+		removed.Remove("n1")
+		fresh := New(10, fn)
+		fresh.Add("n2", "n3", "n4")
+		for _, key := range keys {
+			if removed.Get(key) != fresh.Get(key) {
+				t.Fatalf("Get(%s) after Remove = %s, want %s", key, removed.Get(key), fresh.Get(key))
+			}
+		}
+		if removed.CollisionReport() != fresh.CollisionReport() {
+			t.Fatalf("CollisionReport after Remove = %+v, want %+v", removed.CollisionReport(), fresh.CollisionReport())
+		}
+	}
+}
+
+func TestMaxVirtualNodes(t *testing.T) {
+	// 虚拟节点倍数配置错误时，第一个节点就会超过默认上限，不会分配虚拟节点
+	hash := New(1<<30, nil)
+	if err := hash.AddE("a"); !errors.Is(err, ErrTooManyVirtualNodes) {
+		t.Fatalf("AddE = %v, want ErrTooManyVirtualNodes", err)
+	}
+	if !hash.IsEmpty() || len(hash.Members()) != 0 {
+		t.Fatalf("ring should stay empty, members = %v", hash.Members())
+	}
+
+	hash = New(10, nil)
+	hash.SetMaxVirtualNodes(30)
+	if err := hash.AddE("a", "b", "a"); err != nil {
+		t.Fatal(err)
+	}
+	// 已经在哈希环上的节点不重复计数
+	if err := hash.AddE("b", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hash.AddE("d"); !errors.Is(err, ErrTooManyVirtualNodes) {
+		t.Fatalf("AddE = %v, want ErrTooManyVirtualNodes", err)
+	}
+	if len(hash.Members()) != 3 {
+		t.Fatalf("members = %v, want the ring unchanged", hash.Members())
+	}
+
+	hash.SetMaxVirtualNodes(0)
+	if err := hash.AddE("d"); err != nil {
+		t.Fatalf("AddE without a limit = %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package mini_groupcache
+
+import (
+	"context"
+	"fmt"
+	"mini-groupcache/testpb"
+	"strconv"
+	"time"
+)
+
+// Increment 原子地给 key 上的计数器加上 delta 并返回相加之后的值，适合限流、浏览量这类近似计数
+// 计数器以十进制字符串的形式存放在缓存中，Get 读到的就是它的当前值；缓存中还没有这个 key 时从 0 开始，不会调用 getter
+// key 属于其它节点时把操作发给所属节点（需要它实现 PeerIncrementer，如 HTTPPool），由所属节点完成相加并返回新值，
+// 本节点 hotCache 中的旧副本随之删除；所属节点失败时返回错误，不会回退到本节点计数，避免同一个计数器分裂成多份
+//
+// 一致性保证：
+//   - 在所属节点内是原子的：同一个 key 上的 Increment、Put 逐个执行，不会丢失更新，正在进行的加载不会覆盖计数器
+//   - 跨节点是尽力而为的：计数器只存在于所属节点的缓存中，被淘汰、节点重启或哈希环变化之后从 0 重新开始；
+//     请求所属节点遇到网络错误时不会重试（重试可能重复计入 delta），直接返回错误，此时 delta 可能已经计入，也可能没有；
+//     其它节点 hotCache 中的副本可能是旧值
+//
+// 配置了 TTL 时计数器在第一次写入时开始计时，之后的 Increment 不会延长存活时间，适合按时间窗口限流
+// 已有的值不是十进制整数时返回错误；分组关闭了缓存时返回错误
+func (g *Group) Increment(key string, delta int64) (int64, error) {
+	return g.increment(context.Background(), key, delta)
+}
+
+// increment 是 Increment 带 ctx 的实现
+func (g *Group) increment(ctx context.Context, key string, delta int64) (int64, error) {
+	key = g.normalize(key)
+	if key == "" {
+		return 0, fmt.Errorf("key is required")
+	}
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			pi, ok := peer.(PeerIncrementer)
+			if !ok {
+				return 0, fmt.Errorf("peer %s does not support increment", peerName(peer))
+			}
+			res := &testpb.Response{}
+			if err := pi.Increment(ctx, &testpb.IncrementRequest{Group: g.name, Key: key, Delta: delta}, res); err != nil {
+				return 0, err
+			}
+			g.hotCache.remove(key)
+			return parseCounter(key, res.GetValue())
+		}
+	}
+	return g.incrementLocally(key, delta)
+}
+
+// incrementLocally 在本节点的 mainCache 中给计数器加上 delta，不论 key 是否属于本节点
+func (g *Group) incrementLocally(key string, delta int64) (int64, error) {
+	if g.mainCache.budget() == 0 {
+		return 0, fmt.Errorf("caching is disabled for group %s", g.name)
+	}
+
+	var n int64
+	add := func() (any, error) {
+		cur, ttl := int64(0), time.Duration(0)
+		if e, ok := g.mainCache.getEntry(key); ok {
+			v, err := parseCounter(key, g.originalValue(e).ByteSlice())
+			if err != nil {
+				return nil, err
+			}
+			cur = v
+			// 保持原来的过期时间，计数器的时间窗口不会因为持续写入而延长
+			if !e.expiresAt.IsZero() {
+				if ttl = time.Until(e.expiresAt); ttl <= 0 {
+					cur, ttl = 0, 0
+				}
+			}
+		}
+		n = cur + delta
+		view := ByteView{b: []byte(strconv.FormatInt(n, 10))}
+		if ttl == 0 {
+			ttl = g.ttlFor(key, view)
+		}
+		g.populateCate(&g.mainCache, key, view, SourceGetter, ttl)
+		return view, nil
+	}
+
+	err := g.loader.WithLock(key, func() error {
+		g.forgetLoads(key)
+		// 与 Put 一样：有加载（包括不允许回退的加载）正在进行时等它结束再相加，避免它随后用数据源的值覆盖计数器；
+		// 没有时由这次调用占住 key，期间到达的读取直接拿到新值
+		fn := add
+		for _, fk := range loadFlightKeys(key) {
+			fn = g.occupy(fk, fn)
+		}
+		_, err := fn()
+		return err
+	})
+	return n, err
+}
+
+// occupy 返回在 singleflight 中占住 fk 执行 fn 的函数，fk 上有加载正在进行时先等它结束再执行 fn，fn 总是恰好执行一次
+func (g *Group) occupy(fk string, fn func() (any, error)) func() (any, error) {
+	return func() (any, error) {
+		ran := false
+		v, err := g.loader.Do(fk, func() (any, error) {
+			ran = true
+			return fn()
+		})
+		if !ran {
+			return fn()
+		}
+		return v, err
+	}
+}
+
+// parseCounter 把缓存中计数器的十进制表示解析成整数
+func parseCounter(key string, b []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value of key %q is not an integer: %w", key, err)
+	}
+	return n, nil
+}
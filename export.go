@@ -0,0 +1,184 @@
+package mini_groupcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// exportRecord 是导出缓存内容时每个条目的格式，每个条目编码为一行 JSON
+// Value 是经过 onLoad 还原后的原始值，导入方会按自己的配置重新执行 onStore
+type exportRecord struct {
+	Group     string    `json:"group"`
+	Key       string    `json:"key"`
+	Value     []byte    `json:"value"`
+	LoadedAt  time.Time `json:"loaded_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// writeExport 将 gs 中所有分组 mainCache 的内容以 JSON Lines 的格式写入 w
+// hotCache 中是从其它节点获取的副本，不属于本节点，不会被导出
+func writeExport(w io.Writer, gs []*Group) error {
+	enc := json.NewEncoder(w)
+	now := time.Now()
+	for _, g := range gs {
+		for _, e := range g.mainCache.entries() {
+			if !e.expiresAt.IsZero() && !now.Before(e.expiresAt) {
+				continue
+			}
+			entry := g.toEntry(e.key, e.cacheEntry)
+			if err := enc.Encode(exportRecord{
+				Group:     g.name,
+				Key:       e.key,
+				Value:     entry.ByteSlice(),
+				LoadedAt:  e.loadedAt,
+				ExpiresAt: e.expiresAt,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RestoreResult 是导入缓存内容的结果
+type RestoreResult struct {
+	Loaded    int   // 成功写入缓存的条目数
+	Bytes     int64 // 写入缓存的字节数（key 加 value）
+	Skipped   int   // 跳过的条目数：本节点不存在的分组、已经过期或超出分组容量的条目
+	Malformed int   // 无法解析而被跳过的条目数
+	Truncated bool  // 是否因为达到字节预算而提前停止
+}
+
+// importRecords 从 r 中逐条读取导出的条目并写入对应分组的 mainCache
+// 导入到每个分组的字节数不会超过该分组的容量，本节点不存在的分组和已经过期的条目会被跳过；
+// 无法解析的行单独计数并跳过，不影响后面的条目。maxBytes 大于 0 时，导入的总字节数达到它就停止读取
+// 数据是边读边写的，读取速度受写入速度限制，对发送方形成自然的背压
+func importRecords(ctx context.Context, r io.Reader, maxBytes int64) (RestoreResult, error) {
+	br := bufio.NewReader(r)
+	budgets := make(map[*Group]int64)
+	var res RestoreResult
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 && importRecord(line, maxBytes, budgets, &res) {
+			res.Truncated = true
+			return res, nil
+		}
+		if err == io.EOF {
+			return res, nil
+		} else if err != nil {
+			return res, fmt.Errorf("reading record: %v", err)
+		}
+	}
+}
+
+// importRecord 导入一行记录并更新 res，返回 true 表示达到了字节预算 maxBytes
+func importRecord(line []byte, maxBytes int64, budgets map[*Group]int64, res *RestoreResult) bool {
+	var rec exportRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		res.Malformed++
+		return false
+	}
+
+	g := GetGroup(rec.Group)
+	if g == nil || rec.Key == "" {
+		res.Skipped++
+		return false
+	}
+	var ttl time.Duration
+	if !rec.ExpiresAt.IsZero() {
+		if ttl = time.Until(rec.ExpiresAt); ttl <= 0 {
+			res.Skipped++
+			return false
+		}
+	}
+
+	size := int64(len(rec.Key) + len(rec.Value))
+	if maxBytes > 0 && res.Bytes+size > maxBytes {
+		return true
+	}
+	if max := g.mainCache.budget(); max == 0 || max > 0 && budgets[g]+size > max {
+		res.Skipped++
+		return false
+	}
+	budgets[g] += size
+
+	g.populateCate(&g.mainCache, rec.Key, ByteView{b: rec.Value}, SourceGetter, ttl)
+	res.Loaded++
+	res.Bytes += size
+	return false
+}
+
+// allGroups 返回当前所有的分组，按名称排序
+func allGroups() []*Group {
+	mu.Lock()
+	defer mu.Unlock()
+
+	gs := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		gs = append(gs, g)
+	}
+	sort.Slice(gs, func(i, j int) bool {
+		return gs[i].name < gs[j].name
+	})
+	return gs
+}
+
+// serveExport 处理 GET <basepath>/_export，以流的形式返回本节点所有分组的缓存内容，没有开启时返回 404
+func (p *HTTPPool) serveExport(w http.ResponseWriter, r *http.Request) {
+	if !p.exportEndpoint {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if err := writeExport(w, allGroups()); err != nil {
+		p.Log("export failed: %v", err)
+	}
+}
+
+// ImportFrom 从节点 peer（如 http://localhost:8001）拉取它的全部缓存内容并写入本节点对应的分组
+// 适合蓝绿部署时用旧集群的缓存预热新集群，对方需要用 WithExportEndpoint 开启导出；导入受 ctx 的截止时间约束，返回成功导入的条目数
+// 请求与其它节点间请求一样携带协议版本，对方版本不兼容时返回 ErrProtocolMismatch
+func (p *HTTPPool) ImportFrom(ctx context.Context, peer string) (int, error) {
+	h := &httpGetter{baseURL: peer + p.basePath}
+	resp, err := h.do(ctx, http.MethodGet, h.baseURL+exportPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("server returned: %v", resp.Status)
+	}
+
+	res, err := importRecords(ctx, resp.Body, 0)
+	return res.Loaded, err
+}
+
+// Snapshot 将本节点所有分组 mainCache 中未过期的内容写入 w，格式与 <basepath>/_export 相同（每个条目一行 JSON）
+// 可以在停机前保存到文件，启动时用 Restore 恢复，避免冷启动时大量请求直接打到数据源
+func Snapshot(w io.Writer) error {
+	return writeExport(w, allGroups())
+}
+
+// Restore 从 Snapshot 写出的内容中恢复缓存，分组需要事先创建好，返回恢复的结果
+// ctx 结束时立即停止并返回 ctx.Err()，已经恢复的条目仍然保留；maxBytes 大于 0 时，恢复的总字节数达到它就停止，
+// 此时 Truncated 为 true；单条损坏的记录只会被跳过并计入 Malformed，不会中止整个恢复。
+// 用带超时的 ctx 和 maxBytes 可以限制超大或损坏的快照对节点启动时间的影响
+func Restore(ctx context.Context, r io.Reader, maxBytes int64) (RestoreResult, error) {
+	return importRecords(ctx, r, maxBytes)
+}
@@ -0,0 +1,58 @@
+package mini_groupcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRestore(t *testing.T) {
+	group := NewGroup("restore", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value-" + key), nil
+	}))
+	for _, key := range []string{"a", "b", "c"} {
+		group.Get(key)
+	}
+	var buf bytes.Buffer
+	if err := Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// 快照包含所有分组，只保留这个分组的记录
+	var lines []string
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.Contains(line, `"group":"restore"`) {
+			lines = append(lines, line)
+		}
+	}
+
+	// 损坏的记录被跳过，不影响后面的条目
+	group.Remove("a")
+	group.Remove("b")
+	group.Remove("c")
+	snapshot := lines[0] + "{not json\n" + strings.Join(lines[1:], "")
+	res, err := Restore(context.Background(), strings.NewReader(snapshot), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Malformed != 1 || res.Loaded != 3 || res.Truncated {
+		t.Fatalf("Restore = %+v", res)
+	}
+	if s := group.Stats(); s.Items != 3 {
+		t.Fatalf("restored %d items, want 3", s.Items)
+	}
+
+	// 达到字节预算时提前停止
+	size := int64(len("a") + len("value-a"))
+	res, err = Restore(context.Background(), strings.NewReader(snapshot), size)
+	if err != nil || res.Loaded != 1 || !res.Truncated {
+		t.Fatalf("Restore with budget = %+v, %v", res, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res, err = Restore(ctx, strings.NewReader(snapshot), 0); !errors.Is(err, context.Canceled) || res.Loaded != 0 {
+		t.Fatalf("Restore with cancelled ctx = %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,47 @@
+package mini_groupcache
+
+import "context"
+
+// FallbackPolicy 决定 key 的所属节点获取失败之后是否继续回退到备用集群和本地 getter
+type FallbackPolicy int
+
+const (
+	// FallbackLocal 所属节点失败后依次回退到备用集群和本地 getter，这是默认的行为
+	FallbackLocal FallbackPolicy = iota
+	// FallbackStrict 所属节点失败时直接返回它的错误，不回退，适合不能接受绕过所属节点读数据源的场景
+	// key 属于本节点时仍然由本节点的 getter 加载
+	FallbackStrict
+)
+
+type fallbackPolicyKey struct{}
+
+// ContextWithFallbackPolicy 返回为这一次请求指定了 FallbackPolicy 的 ctx，用于 Group.GetContext 等方法
+// 优先级：ctx 中指定的策略 > 分组通过 WithFallbackPolicy 设置的策略 > FallbackLocal
+// 策略只在本节点生效，不会随请求转发给其它节点
+func ContextWithFallbackPolicy(ctx context.Context, policy FallbackPolicy) context.Context {
+	return context.WithValue(ctx, fallbackPolicyKey{}, policy)
+}
+
+// fallbackPolicy 返回这一次请求实际使用的 FallbackPolicy
+func (g *Group) fallbackPolicy(ctx context.Context) FallbackPolicy {
+	if policy, ok := ctx.Value(fallbackPolicyKey{}).(FallbackPolicy); ok {
+		return policy
+	}
+	return g.fallback
+}
+
+// flightKey 返回 key 在 singleflight 中使用的 key
+// 不回退的加载只会把所属节点的值写入 hotCache，使用单独的 key，
+// 这样 FallbackStrict 的请求不会合并到一次会回退的加载上、拿到绕过所属节点加载的值，反之亦然
+// 注意 key 属于本节点（或者没有注册节点）时不回退的加载同样调用 getter 并写入 mainCache，见 loadFlightKeys
+func flightKey(key string, policy FallbackPolicy) string {
+	if policy == FallbackStrict {
+		return "\x00strict\x00" + key
+	}
+	return key
+}
+
+// loadFlightKeys 返回 key 上可能写入 mainCache 的加载在 singleflight 中使用的所有 key，Put 和 Increment 要等待它们全部结束
+func loadFlightKeys(key string) []string {
+	return []string{key, flightKey(key, FallbackStrict)}
+}
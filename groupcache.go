@@ -1,11 +1,17 @@
 package mini_groupcache
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"mini-groupcache/bloom"
+	"mini-groupcache/lru"
 	"mini-groupcache/singleflight"
 	"mini-groupcache/testpb"
+	"mini-groupcache/workerpool"
 	"sync"
+	"time"
 )
 
 // Getter 当缓存值不存在时，调用 Get 方法从其它数据源获取数据（文件、数据库、网络等）
@@ -21,26 +27,127 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// GetterContext 与 Getter 相同，但能拿到发起请求时的 ctx，可以用 MetadataFromContext 读取请求级的元数据（租户、鉴权信息、trace id 等）
+type GetterContext interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// GetterContextFunc 实现 GetterContext 接口
+type GetterContextFunc func(ctx context.Context, key string) ([]byte, error)
+
+func (f GetterContextFunc) Get(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
 // Group 是一个缓存的命名空间
 type Group struct {
 	name      string
-	getter    Getter // 缓存未命中时执行的回调用来获取数据源
-	mainCache cache  // 并发安全的缓存
+	getter    GetterContext // 缓存未命中时执行的回调用来获取数据源
+	mainCache shardedCache  // 并发安全的缓存，存储本节点负责的 key
+	// 存储从远程节点获取的值，避免热点 key 每次都要请求远程节点，容量为 mainCache 的 1/8
+	// 值会带着所属节点上的剩余存活时间一起存入，不会比所属节点上的值活得更久
+	hotCache shardedCache
+
+	cacheShards int // mainCache 和 hotCache 的分片数，0 表示按容量和 GOMAXPROCS 自动选择，见 WithCacheShards
 
 	peers  PeerPicker          // 分组内维护当前的节点信息（节点为 HTTPPool 结构）
 	loader *singleflight.Group // 分组内控制并发的相同请求只会实际去请求一次
+	pool   *workerpool.Pool    // 执行后台任务（如异步加载）的有界任务池
+
+	dropPolicy workerpool.DropPolicy // 任务池队列已满时的丢弃策略，在所有选项应用之后设置到 pool 上
+
+	// 备用集群（如异地灾备集群），按顺序在主集群获取失败之后、调用 getter 之前尝试
+	fallbackPeers []PeerPicker
+
+	stats groupStats // 分组的统计计数
+
+	peerStatsMu sync.Mutex
+	peerStats   map[string]*peerCounters // 每个远程节点的统计计数，以节点名称为 key
+
+	onStore func([]byte) []byte // 写入缓存前的转换钩子，可选
+	onLoad  func([]byte) []byte // 从缓存读出后的转换钩子，可选
+	keyFunc func(string) string // 查找、路由之前对 key 的规范化，可选
+	ttl     time.Duration       // 缓存值的存活时间，0 表示永不过期
+
+	ttlFunc func(string, []byte) time.Duration // 按条目计算存活时间，设置后代替 ttl，可选
+
+	bloomMu sync.RWMutex
+	bloom   *bloom.Filter // 已知 key 集合的布隆过滤器，判断一定不存在的 key 直接返回，可选
+
+	limiter *tokenBucket // 限制调用 getter 的速率，可选
+
+	writeThrough func(key string, value []byte) error // Put 写入数据源的函数，可选
+
+	validator func(key string, value []byte) error // 加载结果存入缓存前的校验，可选
+
+	compressor *compressor // 压缩写入缓存的值，可选
+
+	fallback FallbackPolicy // 所属节点获取失败时是否回退，可以被 ContextWithFallbackPolicy 按请求覆盖
+
+	onMiss      func(MissInfo) // 缓存未命中的加载完成后在后台调用的回调，可选
+	missSample  int64          // 每 missSample 次加载调用一次 onMiss
+	missCounter int64          // 已经完成的加载次数，用于抽样
+
+	warmFill  float64       // 预热完成的缓存填充比例，见 WithWarmCriteria
+	warmAfter time.Duration // 预热完成的时间，从第一个请求开始计
+	firstGet  int64         // 第一个请求的时间（UnixNano），还没有请求时为 0
+	warm      int32         // 为 1 表示已经预热完成
+
+	hedgeDelay time.Duration // 所属节点超过这个时间没有返回时向第二选择节点发起对冲请求，0 表示不对冲
+
+	healthWeights HealthWeights // Health 中各项指标的权重
+
+	readOnly bool // 只读副本，从不调用 getter，见 WithReadOnlyReplica
+
+	middlewares []Middleware // 通过 Use 注册的中间件，按注册顺序由外到内
+	chain       GetFunc      // 中间件包装之后的 GetContext，没有中间件时为 nil
 }
 
+var (
+	// ErrNotFound 表示 key 在数据源中一定不存在，getter 在 key 不存在时应返回它（或用 %w 包装它的错误），
+	// Get 会原样返回，调用方用 errors.Is 与其它加载错误区分，HTTP 接口可以用 HTTPStatus 把它映射成 404
+	ErrNotFound = errors.New("key not found")
+	// ErrWaitTimeout 表示 ctx 结束时其它调用方发起的相同加载还没有完成，调用方放弃了等待
+	ErrWaitTimeout = singleflight.ErrWaitTimeout
+	// ErrCacheFull 表示固定的条目占满了缓存容量，新的值放不下（PinOverflowReject 策略下）
+	ErrCacheFull = lru.ErrCacheFull
+	// ErrNoBackingStore 表示只读副本（见 WithReadOnlyReplica）上缓存和远程节点都没能提供值，副本不能调用 getter 自己加载
+	ErrNoBackingStore = errors.New("read-only replica has no backing store")
+)
+
+const (
+	defaultWorkers   = 8   // 后台任务池默认的 worker 数量
+	defaultQueueSize = 256 // 后台任务池默认的队列长度
+)
+
 var (
 	mu sync.Mutex
 	// groups 全局变量用来存储所有的 group，以 name 作为分组名
 	groups = make(map[string]*Group)
 )
 
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+// UnlimitedCacheBytes 作为 cacheBytes 传入时表示缓存容量不受限制，缓存的值永远不会因为容量不足被淘汰
+// cacheBytes 为 0 表示关闭缓存（每次都重新加载），为正数时表示缓存的最大字节数，其它负数是配置错误
+const UnlimitedCacheBytes = -1
+
+// NewGroup 创建一个分组，cacheBytes 的取值见 UnlimitedCacheBytes
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
+	if getter == nil {
+		panic("nil getter")
+	}
+	return NewGroupContext(name, cacheBytes, GetterContextFunc(func(_ context.Context, key string) ([]byte, error) {
+		return getter.Get(key)
+	}), opts...)
+}
+
+// NewGroupContext 与 NewGroup 相同，但 getter 能拿到发起请求时的 ctx
+func NewGroupContext(name string, cacheBytes int64, getter GetterContext, opts ...GroupOption) *Group {
 	if getter == nil {
 		panic("nil getter")
 	}
+	if cacheBytes < 0 && cacheBytes != UnlimitedCacheBytes {
+		panic(fmt.Sprintf("invalid cacheBytes %d: use 0 to disable caching or UnlimitedCacheBytes for no limit", cacheBytes))
+	}
 
 	// 初始化分组时要加上互斥锁，因为它们都操作了同一个全局变量 groups
 	mu.Lock()
@@ -49,15 +156,51 @@ func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
 	g := &Group{
 		name:      name,
 		getter:    getter,
-		mainCache: cache{cacheBytes: cacheBytes},
+		mainCache: shardedCache{cache: cache{cacheBytes: cacheBytes}},
+		hotCache:  shardedCache{cache: cache{cacheBytes: hotCacheBytes(cacheBytes)}},
 		loader:    &singleflight.Group{},
+		pool:      workerpool.New(defaultWorkers, defaultQueueSize),
+		warmFill:  defaultWarmFill,
+		warmAfter: defaultWarmAfter,
+	}
+	g.healthWeights = DefaultHealthWeights
+	for _, opt := range opts {
+		opt(g)
 	}
+	// WithWorkerPool 会替换任务池，丢弃策略放在最后统一设置，与选项的顺序无关
+	g.pool.SetDropPolicy(g.dropPolicy)
+	// 分片按选项设置好的配置创建
+	g.mainCache.split(g.cacheShards)
+	g.hotCache.split(g.cacheShards)
 
 	groups[name] = g
 
 	return g
 }
 
+// hotCacheBytes 计算 hotCache 的容量，mainCache 有容量限制时 hotCache 也必须有，关闭或不限制时与 mainCache 相同
+func hotCacheBytes(cacheBytes int64) int64 {
+	if cacheBytes <= 0 {
+		return cacheBytes
+	}
+	if cacheBytes < 8 {
+		return 1
+	}
+	return cacheBytes / 8
+}
+
+// SetCacheBytes 在运行时修改分组的缓存容量，cacheBytes 的取值与 NewGroup 相同，hotCache 的容量随之按比例调整
+// 容量变小时立即淘汰多出的条目，改为 0 时清空缓存；调用期间并发的读写照常进行，不会丢失写入
+// mainCache 和 hotCache 依次各自加锁调整，两次调整之间分组的总占用可能短暂地按一个缓存的新容量、另一个缓存的旧容量计算，
+// 即缩容时短暂超出、扩容时短暂低于新的总容量；调整期间写入的值按写入时所在缓存的容量淘汰
+func (g *Group) SetCacheBytes(cacheBytes int64) {
+	if cacheBytes < 0 && cacheBytes != UnlimitedCacheBytes {
+		panic(fmt.Sprintf("invalid cacheBytes %d: use 0 to disable caching or UnlimitedCacheBytes for no limit", cacheBytes))
+	}
+	g.mainCache.resize(cacheBytes)
+	g.hotCache.resize(hotCacheBytes(cacheBytes))
+}
+
 func GetGroup(name string) *Group {
 	return groups[name]
 }
@@ -70,21 +213,357 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 	g.peers = peers
 }
 
+// RegisterFallbackPeers 按顺序追加备用集群，每个备用集群按自己的哈希环路由
+// 主集群没能提供数据时（key 属于自己或请求失败），会在调用 getter 之前依次尝试备用集群
+// 需要在分组开始处理请求之前调用
+func (g *Group) RegisterFallbackPeers(peers ...PeerPicker) {
+	g.fallbackPeers = append(g.fallbackPeers, peers...)
+}
+
 // Get 获取缓存值
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext 与 Get 相同，ctx 会随着请求传递给远程节点，用于取消请求和传递请求的跳数等信息
+// ctx 没有请求 ID（见 ContextWithRequestID）时生成一个，之后经过的每个节点都会在日志中打印它
+// 通过 Use 注册了中间件时，先依次经过中间件
+func (g *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
+	ctx = ensureRequestID(ctx)
+	if g.chain != nil {
+		return g.chain(ctx, key)
+	}
+	return g.getContext(ctx, key)
+}
+
+// getContext 是不经过中间件的 GetContext
+func (g *Group) getContext(ctx context.Context, key string) (ByteView, error) {
+	key = g.normalize(key)
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is required")
 	}
+	g.countGet()
 
 	// 收到客户端或其它节点的请求，现在本地（自身节点）查找该 key 是否存在
 	// 如果有多个相同的并发请求，同时读本地的缓存是被允许的
-	if v, ok := g.mainCache.get(key); ok {
+	if v, ok := g.lookupCache(key); ok {
+		g.stats.cacheHits.Add(1)
 		log.Println("cache hit")
 		return v, nil
 	}
 
 	// 本地不存在该值，尝试向其它节点查找
-	return g.load(key)
+	return g.load(ctx, key)
+}
+
+// GetOrQueue 非阻塞地获取缓存值，适合先渲染占位内容、再异步刷新的场景
+// 缓存命中时直接返回缓存值；否则立即返回 placeholder 且 loading 为 true，
+// 同时在后台任务池中加载该 key，加载完成后的调用就能拿到真实值
+// 同一个 key 多次排队时，开始执行时值已经在缓存中的任务直接结束，同时执行的任务经过 singleflight 合并，数据源只会被加载一次
+func (g *Group) GetOrQueue(key string, placeholder []byte) (value ByteView, loading bool) {
+	return g.GetOrQueueContext(context.Background(), key, placeholder)
+}
+
+// GetOrQueueContext 与 GetOrQueue 相同，后台加载使用 ctx 中的值（请求 ID、回退策略等），但不会随 ctx 一起被取消
+func (g *Group) GetOrQueueContext(ctx context.Context, key string, placeholder []byte) (value ByteView, loading bool) {
+	placeholderView := ByteView{b: cloneBytes(placeholder)}
+	key = g.normalize(key)
+	if key == "" {
+		return placeholderView, false
+	}
+	g.countGet()
+
+	if v, ok := g.lookupCache(key); ok {
+		g.stats.cacheHits.Add(1)
+		return v, false
+	}
+
+	ctx = detachedContext{ensureRequestID(ctx)}
+	// 队列已满时放弃这次后台加载，之后的调用会再次尝试
+	if !g.pool.Submit(func() {
+		// 排队期间之前的任务已经加载完成
+		if _, ok := g.findCacheEntry(key); ok {
+			return
+		}
+		if _, err := g.load(ctx, key); err != nil {
+			log.Printf("[Groupcache] Failed to load in background (request_id=%s): %v", RequestIDFromContext(ctx), err)
+		}
+	}) {
+		log.Println("[Groupcache] Worker pool is full, drop background load of", key)
+	}
+
+	return placeholderView, true
+}
+
+// Entry 是缓存值及其元数据
+type Entry struct {
+	ByteView
+	StoredBytes int64     // 缓存中实际占用的字节数（key 加上经过 OnStore 转换后的值），未缓存时为 0
+	LoadedAt    time.Time // 加载的时间
+	ExpiresAt   time.Time // 过期时间，零值表示永不过期
+	Source      Source    // 值的来源
+}
+
+// GetEntry 与 Get 相同，但同时返回值的元数据
+// 值与元数据在一次加锁内读出，避免分多次查询时中途被淘汰或刷新导致前后不一致
+func (g *Group) GetEntry(key string) (Entry, error) {
+	return g.getEntry(context.Background(), key)
+}
+
+// getEntry 是 GetEntry 带 ctx 的实现，供处理其它节点的请求时使用
+func (g *Group) getEntry(ctx context.Context, key string) (Entry, error) {
+	key = g.normalize(key)
+	if key == "" {
+		return Entry{}, fmt.Errorf("key is required")
+	}
+	g.countGet()
+
+	if e, ok := g.getCacheEntry(key); ok {
+		g.stats.cacheHits.Add(1)
+		return g.toEntry(key, e), nil
+	}
+
+	view, err := g.load(ctx, key)
+	if err != nil {
+		return Entry{}, err
+	}
+	if e, ok := g.findCacheEntry(key); ok {
+		return g.toEntry(key, e), nil
+	}
+	// 值已经加载成功，但没能留在缓存中（如容量太小）
+	return Entry{ByteView: view, LoadedAt: time.Now()}, nil
+}
+
+// toEntry 将缓存中存储的条目转换成对外的 Entry，并还原 onStore 转换过的值
+func (g *Group) toEntry(key string, e cacheEntry) Entry {
+	return Entry{
+		ByteView:    g.originalValue(e),
+		StoredBytes: int64(len(key) + e.view.Len()),
+		LoadedAt:    e.loadedAt,
+		ExpiresAt:   e.expiresAt,
+		Source:      e.source,
+	}
+}
+
+// GetFresh 获取缓存值，并由调用方按请求决定能容忍多旧的值
+// 缓存命中时立即返回缓存值，如果它加载进缓存的时间已经超过 maxAge，则在后台重新加载；
+// 缓存未命中时与 Get 一样同步加载。这样不需要修改分组统一的 TTL 就能按请求控制新鲜度
+// 同一个旧值触发的多次刷新只会加载一次：开始执行时缓存中的值已经被其它刷新替换的任务直接结束
+func (g *Group) GetFresh(key string, maxAge time.Duration) (ByteView, error) {
+	return g.GetFreshContext(context.Background(), key, maxAge)
+}
+
+// GetFreshContext 与 GetFresh 相同，ctx 用于同步加载，后台刷新使用 ctx 中的值但不会随 ctx 一起被取消
+func (g *Group) GetFreshContext(ctx context.Context, key string, maxAge time.Duration) (ByteView, error) {
+	ctx = ensureRequestID(ctx)
+	key = g.normalize(key)
+	if key == "" {
+		return ByteView{}, fmt.Errorf("key is required")
+	}
+	g.countGet()
+
+	e, ok := g.getCacheEntry(key)
+	if !ok {
+		return g.load(ctx, key)
+	}
+	g.stats.cacheHits.Add(1)
+
+	if time.Since(e.loadedAt) > maxAge {
+		ctx := detachedContext{ctx}
+		// 队列已满时放弃这次刷新，之后的调用会再次尝试
+		if !g.pool.Submit(func() {
+			// 排队期间其它刷新已经替换了这个旧值
+			if cur, ok := g.findCacheEntry(key); ok && cur.loadedAt.After(e.loadedAt) {
+				return
+			}
+			if _, err := g.load(ctx, key); err != nil {
+				log.Printf("[Groupcache] Failed to refresh in background (request_id=%s): %v", RequestIDFromContext(ctx), err)
+			}
+		}) {
+			log.Println("[Groupcache] Worker pool is full, drop background refresh of", key)
+		}
+	}
+
+	return g.toEntry(key, e).ByteView, nil
+}
+
+// Remove 从当前节点的缓存（包括 mainCache 和 hotCache）中删除 key，下次访问时会重新加载
+// 只影响当前节点，所属节点上的值可以用 RemoveAndVerify 同步删除，其它节点上的副本需要通过 HTTP DELETE <basepath>/<group>/<key> 逐个删除
+func (g *Group) Remove(key string) {
+	key = g.normalize(key)
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
+	g.forgetLoads(key)
+}
+
+// Clear 清空当前节点的缓存（包括 mainCache 和 hotCache），适合重新加载配置之后丢弃所有旧值，之后的访问会重新加载
+// 被清空的条目与淘汰一样计入 Stats.Evictions 并通知 WithOnEvicted 设置的回调；只影响当前节点
+func (g *Group) Clear() {
+	g.mainCache.clear()
+	g.hotCache.clear()
+	g.loader.ForgetAll()
+}
+
+// forgetLoads 丢弃 singleflight 中为 key 保留的加载结果（见 WithMicroCache），包括不允许回退的请求单独使用的那一份
+func (g *Group) forgetLoads(key string) {
+	for _, fk := range loadFlightKeys(key) {
+		g.loader.Forget(fk)
+	}
+}
+
+// StrandedKeys 返回 mainCache 中按当前注册的 PeerPicker 已经不属于当前节点的 key（如重新平衡之后留下的值），从最近访问的开始
+// 这些值不会再被其它节点请求，可以删除或迁移到新的所属节点；hotCache 本来就存放其它节点的值，不在此列
+// PeerPicker 实现了 OwnedKeys（如 HTTPPool）时，所有 key 按同一个哈希环判断，不会因为并发的节点变更而前后不一致；
+// 否则逐个调用 PickPeer。没有注册节点时返回 nil
+func (g *Group) StrandedKeys() []string {
+	if g.peers == nil {
+		return nil
+	}
+	entries := g.mainCache.entries()
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.key
+	}
+
+	var stranded []string
+	if op, ok := g.peers.(ownedKeysPicker); ok {
+		owned := make(map[string]bool)
+		for _, key := range op.OwnedKeys(keys) {
+			owned[key] = true
+		}
+		for _, key := range keys {
+			if !owned[key] {
+				stranded = append(stranded, key)
+			}
+		}
+		return stranded
+	}
+	for _, key := range keys {
+		if _, ok := g.peers.PickPeer(key); ok {
+			stranded = append(stranded, key)
+		}
+	}
+	return stranded
+}
+
+// RemoveAndVerify 删除 key 在当前节点的缓存，并同步删除它所属节点上的值，返回从开始删除到所属节点确认的耗时
+// 返回 nil 错误表示所属节点已经删除（或 key 就属于当前节点），之后的读取不会再拿到旧值；
+// 所属节点不支持删除（没有实现 PeerRemover）时返回错误。其它节点 hotCache 中的副本不在此列，它们会按 TTL 过期
+func (g *Group) RemoveAndVerify(ctx context.Context, key string) (time.Duration, error) {
+	start := time.Now()
+	key = g.normalize(key)
+	g.Remove(key)
+	if g.peers == nil {
+		return time.Since(start), nil
+	}
+	peer, ok := g.peers.PickPeer(key)
+	if !ok {
+		return time.Since(start), nil
+	}
+	remover, ok := peer.(PeerRemover)
+	if !ok {
+		return 0, fmt.Errorf("peer %s does not support remove", peerName(peer))
+	}
+
+	g.stats.pendingRemoves.Add(1)
+	defer g.stats.pendingRemoves.Add(-1)
+	if err := remover.Remove(ctx, &testpb.DeleteRequest{Group: g.name, Key: key}); err != nil {
+		return 0, fmt.Errorf("removing %s on peer %s: %w", key, peerName(peer), err)
+	}
+	return time.Since(start), nil
+}
+
+// Pin 加载 key 并将它固定在缓存中，使它不会因为容量不足被淘汰，适合功能开关、路由表这类必须一直可用的配置
+// 固定的条目仍然计入缓存占用，所有固定条目的总大小超过缓存容量时的行为见 WithPinOverflow；设置了 TTL 时过期后仍会重新加载，
+// 重新加载的值不再是固定的，需要再次调用 Pin
+func (g *Group) Pin(key string) error {
+	key = g.normalize(key)
+	if _, err := g.Get(key); err != nil {
+		return err
+	}
+	for _, c := range []*shardedCache{&g.mainCache, &g.hotCache} {
+		if ok, err := c.pin(key); ok {
+			return err
+		}
+	}
+	return fmt.Errorf("key %q could not be kept in cache", key)
+}
+
+// Unpin 取消固定 key，之后它与其它条目一样参与淘汰
+func (g *Group) Unpin(key string) {
+	key = g.normalize(key)
+	g.mainCache.unpin(key)
+	g.hotCache.unpin(key)
+}
+
+// WithLock 在 key 的互斥锁保护下执行 fn，同一个 key 上的调用会逐个执行而不会被合并
+// 适合基于缓存值做“读-改-写”的场景；注意锁只在当前节点内有效，并不是整个集群范围的锁
+func (g *Group) WithLock(key string, fn func() error) error {
+	key = g.normalize(key)
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	return g.loader.WithLock(key, fn)
+}
+
+// normalize 对 key 做规范化，没有配置时原样返回
+func (g *Group) normalize(key string) string {
+	if g.keyFunc == nil {
+		return key
+	}
+	return g.keyFunc(key)
+}
+
+// lookupCache 从本地缓存中查找，命中时还原出原始值
+func (g *Group) lookupCache(key string) (ByteView, bool) {
+	e, ok := g.getCacheEntry(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	return g.originalValue(e), true
+}
+
+// originalValue 按与 populateCate 相反的顺序还原缓存中保存的值：先解压，再执行 onLoad 钩子
+func (g *Group) originalValue(e cacheEntry) ByteView {
+	v := e.view
+	if e.compressed {
+		v = g.compressor.decompress(v)
+	}
+	if g.onLoad != nil {
+		v = ByteView{b: g.onLoad(v.ByteSlice())}
+	}
+	return v
+}
+
+// staleValue 查找已经过期但还在 staleGrace 内的旧值，并还原 onStore 转换过的值
+func (g *Group) staleValue(key string) (ByteView, bool) {
+	for _, c := range []*shardedCache{&g.mainCache, &g.hotCache} {
+		if e, ok := c.getStale(key); ok {
+			return g.toEntry(key, e).ByteView, true
+		}
+	}
+	return ByteView{}, false
+}
+
+// getCacheEntry 依次从 mainCache 和 hotCache 中查找缓存条目，并分别统计两者的命中次数
+func (g *Group) getCacheEntry(key string) (cacheEntry, bool) {
+	if e, ok := g.mainCache.getEntry(key); ok {
+		g.stats.mainCacheHits.Add(1)
+		return e, true
+	}
+	if e, ok := g.hotCache.getEntry(key); ok {
+		g.stats.hotCacheHits.Add(1)
+		return e, true
+	}
+	return cacheEntry{}, false
+}
+
+// findCacheEntry 与 getCacheEntry 相同但不计入命中次数，用于同一个请求中的再次查找
+func (g *Group) findCacheEntry(key string) (cacheEntry, bool) {
+	if e, ok := g.mainCache.getEntry(key); ok {
+		return e, true
+	}
+	return g.hotCache.getEntry(key)
 }
 
 // load 缓存没命中时，根据 getter 加载数据源到缓存里
@@ -105,24 +584,43 @@ func (g *Group) Get(key string) (ByteView, error) {
 // 	return g.getLocally(key)
 // }
 
+// SetBloomFilter 替换分组的布隆过滤器，传入 nil 表示不再使用过滤器
+// key 集合发生变化时，先构建好包含完整 key 集合的新过滤器再调用它替换，替换过程中不会出现误判为不存在的情况
+func (g *Group) SetBloomFilter(f *bloom.Filter) {
+	g.bloomMu.Lock()
+	defer g.bloomMu.Unlock()
+	g.bloom = f
+}
+
+// mayExist 判断 key 是否可能存在，没有配置布隆过滤器时总是返回 true
+func (g *Group) mayExist(key string) bool {
+	g.bloomMu.RLock()
+	defer g.bloomMu.RUnlock()
+	return g.bloom == nil || g.bloom.MayContain(key)
+}
+
 // load 缓存没命中时，根据 getter 加载数据源到缓存里
-func (g *Group) load(key string) (value ByteView, err error) {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
+	// 布隆过滤器判断一定不存在的 key 不会去请求远程节点或数据源，防止缓存穿透
+	if !g.mayExist(key) {
+		g.stats.bloomRejects.Add(1)
+		return ByteView{}, ErrNotFound
+	}
+	g.stats.loads.Add(1)
 	// 缓存不存在时开始向其它节点或本地 Getter 查找，保证只会有一个实际的查找
-	view, err := g.loader.Do(key, func() (any, error) {
-		// 在节点启动时，已经将哈希环上的节点信息都挂载到了这个分组上了
-		if g.peers != nil {
-			// 开始根据 key 从哈希环上寻找到对应的节点
-			if peer, ok := g.peers.PickPeer(key); ok {
-				// 找到了目标远程节点，开始向这个远程节点请求数据
-				if value, err = g.getFromPeer(peer, key); err == nil {
-					return value, nil
-				}
-				log.Println("[Groupcache] Failed to get from peer", err)
-			}
+	// 每个调用方最多等到自己的 ctx 结束；实际的加载与发起它的调用方解绑，调用方放弃等待不会取消其它调用方共享的加载
+	waitCtx := ctx
+	ctx = detachedContext{ctx}
+	policy := g.fallbackPolicy(ctx)
+	view, err := g.loader.DoContext(waitCtx, flightKey(key, policy), func() (any, error) {
+		info := MissInfo{Key: key}
+		start := time.Now()
+		value, err := g.fetch(ctx, key, policy, &info)
+		g.reportMiss(info, start, err)
+		if err != nil {
+			return nil, err
 		}
-
-		// 找到的节点是自身或是没有找到其它节点或是没有存储其它节点，则直接调用定义分组时传入的 Getter 从其它数据源获取数据
-		return g.getLocally(key)
+		return value, nil
 	})
 	if err != nil {
 		return
@@ -131,6 +629,81 @@ func (g *Group) load(key string) (value ByteView, err error) {
 	return view.(ByteView), nil
 }
 
+// fetch 依次向所属节点、备用集群和本地 getter 获取 key，最后尝试的一方（成功时就是提供值的一方）记录在 info 中
+func (g *Group) fetch(ctx context.Context, key string, policy FallbackPolicy, info *MissInfo) (ByteView, error) {
+	var failed *peerCounters // 获取失败的主集群节点，用于统计它导致的本地回退
+	// 在节点启动时，已经将哈希环上的节点信息都挂载到了这个分组上了
+	// 收到的是对冲请求时本节点就是被选中的第二选择，不再转发给所属节点
+	// 带有 GetWithLoader 传入的 loader 时只在本地加载，否则 loader 不会被调用
+	if loaderFromContext(ctx) != nil {
+		info.Source = SourceGetter
+		return g.getLocally(ctx, key)
+	}
+	if g.peers != nil && !isHedge(ctx) {
+		// 开始根据 key 从哈希环上寻找到对应的节点
+		if peer, ok := g.peers.PickPeer(key); ok {
+			// 找到了目标远程节点，开始向这个远程节点请求数据
+			info.Source, info.Peer = SourcePeer, peerName(peer)
+			value, from, err := g.getFromOwner(ctx, peer, key)
+			if err == nil {
+				g.stats.peerLoads.Add(1)
+				info.Peer = peerName(from)
+				return value, nil
+			}
+			// 所属节点确定 key 不存在，这是它的答复而不是故障，不再回退到备用集群和数据源重复查询
+			if errors.Is(err, ErrNotFound) {
+				return ByteView{}, err
+			}
+			g.stats.peerErrors.Add(1)
+			failed = g.peerCounters(peer)
+			failed.errors.Add(1)
+			log.Printf("[Groupcache] Failed to get from peer (request_id=%s): %v", RequestIDFromContext(ctx), err)
+			// 请求在节点之间循环转发，说明哈希环配置有误，直接返回错误而不是在本地加载
+			if errors.Is(err, ErrTooManyHops) || policy == FallbackStrict {
+				return ByteView{}, err
+			}
+		}
+	}
+
+	return g.loadFallback(ctx, key, failed, info)
+}
+
+// loadFallback 在主集群没能提供数据时继续加载 key，failed 是获取失败的主集群节点的计数器，没有时为 nil
+// 最后尝试的一方记录在 info 中
+func (g *Group) loadFallback(ctx context.Context, key string, failed *peerCounters, info *MissInfo) (ByteView, error) {
+	// 主集群没能提供数据时，在请求数据源之前依次尝试备用集群，备用集群的失败不影响后续流程
+	for _, peers := range g.fallbackPeers {
+		if peer, ok := peers.PickPeer(key); ok {
+			info.Source, info.Peer = SourcePeer, peerName(peer)
+			value, err := g.getFromPeer(ctx, peer, key)
+			if err == nil {
+				g.stats.fallbackLoads.Add(1)
+				return value, nil
+			}
+			g.peerCounters(peer).errors.Add(1)
+			log.Println("[Groupcache] Failed to get from fallback peer", err)
+		}
+	}
+
+	if failed != nil {
+		failed.fallbacks.Add(1)
+	}
+	// 找到的节点是自身或是没有找到其它节点或是没有存储其它节点，则直接调用定义分组时传入的 Getter 从其它数据源获取数据
+	info.Source, info.Peer = SourceGetter, ""
+	return g.getLocally(ctx, key)
+}
+
+// detachedContext 保留 ctx 中的值和截止时间，但不会随 ctx 一起被取消
+// 截止时间只作为参考（如限速时判断能否等到令牌），不会中断正在进行的加载
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) { return c.parent.Deadline() }
+func (c detachedContext) Done() <-chan struct{}       { return nil }
+func (c detachedContext) Err() error                  { return nil }
+func (c detachedContext) Value(key any) any           { return c.parent.Value(key) }
+
 // getFromPeer 从远程节点获取数据
 // func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
 // 	// 开始向远程节点发起 http 请求
@@ -143,19 +716,38 @@ func (g *Group) load(key string) (value ByteView, err error) {
 // }
 
 // getFromPeer 从远程节点获取数据（使用 protobuf 通信）
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
 	in := &testpb.Request{
 		Group: g.name,
 		Key:   key,
 	}
 	res := &testpb.Response{}
+	// 登记节点，让 PeerStats 能看到所有请求过的节点
+	pc := g.peerCounters(peer)
 	// 开始向远程节点发起 http 请求
-	err := peer.Get(in, res)
+	start := time.Now()
+	err := peer.Get(ctx, in, res)
+	pc.latency.observe(time.Since(start))
 	if err != nil {
 		return ByteView{}, err
 	}
+	// 校验失败与请求失败一样处理，调用方会继续回退到备用集群和本地数据源
+	if err := g.validate(key, res.Value); err != nil {
+		return ByteView{}, err
+	}
 
-	return ByteView{b: res.Value}, nil
+	return g.storePeerResponse(key, res), nil
+}
+
+// storePeerResponse 按所属节点返回的剩余存活时间将值存入 hotCache，保证本地副本不会比所属节点上的值活得更久
+func (g *Group) storePeerResponse(key string, res *testpb.Response) ByteView {
+	value := ByteView{b: res.Value}
+	if !res.GetNoStore() {
+		g.stats.hotCacheFills.Add(1)
+		ttl := time.Duration(res.GetTtlMs()) * time.Millisecond
+		g.populateCate(&g.hotCache, key, value, SourcePeer, ttl)
+	}
+	return value
 }
 
 // load 缓存没命中时，根据用户给定的 getter 加载数据源到缓存里
@@ -164,20 +756,147 @@ func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
 // }
 
 // getLocally 实际调用 getter，并将值加入 cache
-func (g *Group) getLocally(key string) (ByteView, error) {
-	bytes, err := g.getter.Get(key)
+func (g *Group) getLocally(ctx context.Context, key string) (ByteView, error) {
+	// 只读副本没有自己的数据源，但调用方通过 GetWithLoader 显式给出的 loader 仍然可以使用
+	if g.readOnly && loaderFromContext(ctx) == nil {
+		return ByteView{}, ErrNoBackingStore
+	}
+	if g.limiter != nil {
+		start := time.Now()
+		err := g.limiter.wait(ctx)
+		g.stats.queueWait.observe(time.Since(start))
+		if err != nil {
+			// 开启了 WithStaleOnBackpressure 时，宁可返回过期不久的旧值也不返回错误
+			if v, ok := g.staleValue(key); ok {
+				g.stats.staleHits.Add(1)
+				return v, nil
+			}
+			return ByteView{}, err
+		}
+	}
+
+	start := time.Now()
+	bytes, err := g.getterFor(ctx).Get(ctx, key)
+	g.stats.getterTimes.observe(time.Since(start))
+	var nc *noCacheError
+	noCache := errors.As(err, &nc)
+	if noCache {
+		bytes, err = nc.value, nil
+	}
+	if err == nil {
+		err = g.validate(key, bytes)
+	}
 	if err != nil {
+		g.stats.localLoadErrs.Add(1)
 		return ByteView{}, err
 	}
+	if noCache {
+		// getter 标记了这个值不能缓存，只返回给调用方
+		g.stats.localLoads.Add(1)
+		return ByteView{b: cloneBytes(bytes)}, nil
+	}
+	g.stats.localLoads.Add(1)
 
 	// 将数据源复制一份，不影响原来的数据源
 	value := ByteView{b: cloneBytes(bytes)}
-	g.populateCate(key, value)
+	g.populateCate(&g.mainCache, key, value, SourceGetter, g.ttlFor(key, value))
 
 	return value, nil
 }
 
-// populateCate 将值加入缓存
-func (g *Group) populateCate(key string, value ByteView) {
-	g.mainCache.add(key, value)
+// validate 用 WithValidator 设置的函数校验加载出的值，没有设置时总是返回 nil
+func (g *Group) validate(key string, value []byte) error {
+	if g.validator == nil {
+		return nil
+	}
+	if err := g.validator(key, value); err != nil {
+		g.stats.invalidValues.Add(1)
+		return fmt.Errorf("invalid value for key %q: %w", key, err)
+	}
+	return nil
+}
+
+// ttlFor 返回数据源中的值 value 存入 mainCache 时的存活时间
+func (g *Group) ttlFor(key string, value ByteView) time.Duration {
+	if g.ttlFunc != nil {
+		return g.ttlFunc(key, value.ByteSlice())
+	}
+	return g.ttl
+}
+
+// Put 先通过 WithWriteThrough 设置的函数把 value 写入数据源，写入成功后再更新当前节点的缓存；
+// 写入失败时返回错误，缓存保持不变。没有配置 WithWriteThrough 时返回错误
+//
+// 顺序保证（只在当前节点内成立）：
+//   - 同一个 key 上的 Put 逐个执行，缓存中最终是最后一次成功写入的值
+//   - 写入数据源之后，Put 会先等待这个 key 上正在进行的加载（singleflight）结束，再更新缓存，
+//     因此写入之前从数据源读出的旧值不会覆盖新值；Put 返回之后开始的读取都能拿到新值
+//   - 与 Put 同时进行的读取可能拿到旧值，也可能拿到新值
+//
+// 值存入 mainCache，TTL 的计算与 getter 加载的值相同；集群中应在 key 的所属节点上调用，
+// 其它节点 hotCache 中的副本不会更新，可以用 RemoveAndVerify 删除所属节点上的旧值
+func (g *Group) Put(key string, value []byte) error {
+	key = g.normalize(key)
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if g.readOnly {
+		return ErrNoBackingStore
+	}
+	if g.writeThrough == nil {
+		return fmt.Errorf("write-through is not configured for group %s", g.name)
+	}
+
+	return g.loader.WithLock(key, func() error {
+		if err := g.writeThrough(key, value); err != nil {
+			return err
+		}
+		view := ByteView{b: cloneBytes(value)}
+		g.forgetLoads(key)
+		// 有加载正在进行时等它结束，没有时由这次调用占住 key，期间到达的读取直接拿到新值
+		for _, fk := range loadFlightKeys(key) {
+			g.loader.Do(fk, func() (any, error) {
+				return view, nil
+			})
+		}
+		g.hotCache.remove(key)
+		g.populateCate(&g.mainCache, key, view, SourceGetter, g.ttlFor(key, view))
+		return nil
+	})
+}
+
+// noCacheError 包装了一个不能缓存的值，见 NoCache
+type noCacheError struct {
+	value []byte
+}
+
+func (e *noCacheError) Error() string {
+	return "value must not be cached"
+}
+
+// NoCache 用于 getter 返回一个有效但不能缓存的值（如每次请求实时计算的值）：
+// getter 返回 nil, NoCache(value) 时，value 会原样返回给调用方而不会存入缓存，其它节点也不会缓存它的副本
+func NoCache(value []byte) error {
+	return &noCacheError{value: value}
+}
+
+// populateCate 将值加入缓存 c，ttl 为 0 时永不过期
+// 配置了 onStore 钩子时缓存中保存的是转换后的值，节点间传输的始终是还原后的原始值
+func (g *Group) populateCate(c *shardedCache, key string, value ByteView, source Source, ttl time.Duration) {
+	if g.onStore != nil {
+		value = ByteView{b: g.onStore(value.ByteSlice())}
+	}
+	var compressed bool
+	if g.compressor != nil {
+		value, compressed = g.compressor.compress(value)
+	}
+
+	e := cacheEntry{view: value, loadedAt: time.Now(), source: source, compressed: compressed}
+	if ttl > 0 {
+		e.expiresAt = e.loadedAt.Add(ttl)
+	}
+	if err := c.add(key, e); err != nil {
+		// 值仍然会返回给调用方，只是不写入缓存
+		g.stats.cacheFullRejects.Add(1)
+	}
 }
@@ -1,9 +1,21 @@
 package mini_groupcache
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"mini-groupcache/bloom"
+	"mini-groupcache/lru"
+	"mini-groupcache/testpb"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var db = map[string]string{
@@ -51,3 +63,1197 @@ func TestGroup_Get(t *testing.T) {
 		t.Fatalf("the value of unknow should be empty, but %s got", view)
 	}
 }
+
+func TestGroup_Hooks(t *testing.T) {
+	// 模拟加密：写入缓存时逐字节取反并追加一个标记字节，读出时还原
+	xor := func(b []byte) []byte {
+		for i := range b {
+			b[i] ^= 0xff
+		}
+		return b
+	}
+	group := NewGroup("hooks", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("%s is not exist", key)
+	}), WithOnStore(func(b []byte) []byte {
+		return append(xor(b), '#')
+	}), WithOnLoad(func(b []byte) []byte {
+		return xor(b[:len(b)-1])
+	}))
+
+	for k, v := range db {
+		// 第一次从数据源加载，第二次命中缓存，两次都应该拿到原始值
+		for i := 0; i < 2; i++ {
+			if view, err := group.Get(k); err != nil || view.String() != v {
+				t.Fatalf("get %s = %q, %v, want %q", k, view, err, v)
+			}
+		}
+
+		stored, ok := group.mainCache.get(k)
+		if !ok || stored.Len() != len(v)+1 || stored.String() == v {
+			t.Fatalf("cache should hold the transformed value of %s, got %q", k, stored)
+		}
+	}
+}
+
+func TestGroup_GetOrQueue(t *testing.T) {
+	release := make(chan struct{})
+	group := NewGroup("queue", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		<-release // 模拟一个很慢的冷启动数据源
+		return []byte("real"), nil
+	}))
+
+	view, loading := group.GetOrQueue("k", []byte("placeholder"))
+	if !loading || view.String() != "placeholder" {
+		t.Fatalf("GetOrQueue = %q, %v, want the placeholder while loading", view, loading)
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if view, loading = group.GetOrQueue("k", []byte("placeholder")); !loading {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if loading || view.String() != "real" {
+		t.Fatalf("GetOrQueue = %q, %v, want the loaded value", view, loading)
+	}
+}
+
+func TestGroup_GetOrQueueLoadsOnce(t *testing.T) {
+	var loads int32
+	group := NewGroup("queue-once", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(20 * time.Millisecond)
+		return []byte("real"), nil
+	}))
+
+	// 后面排队的任务开始执行时第一次加载已经完成，不应再次调用 getter
+	for i := 0; i < 50; i++ {
+		group.GetOrQueue("k", nil)
+	}
+	waitIdle(t, group)
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("getter called %d times, want 1", n)
+	}
+}
+
+// waitIdle 等待分组的后台任务池执行完所有任务
+func waitIdle(t *testing.T, g *Group) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if st := g.pool.Stats(); st.Active == 0 && st.Queued == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("background tasks did not finish")
+}
+
+func TestGroup_GetEntry(t *testing.T) {
+	group := NewGroup("entry", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value"), nil
+	}), WithTTL(time.Minute))
+
+	before := time.Now()
+	e, err := group.GetEntry("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.String() != "value" || e.StoredBytes != int64(len("k")+len("value")) || e.Source != SourceGetter {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if e.LoadedAt.Before(before) || e.ExpiresAt.Sub(e.LoadedAt) != time.Minute {
+		t.Fatalf("unexpected entry times: loaded at %v, expires at %v", e.LoadedAt, e.ExpiresAt)
+	}
+
+	// 第二次命中缓存，元数据保持不变
+	if e2, err := group.GetEntry("k"); err != nil || !e2.LoadedAt.Equal(e.LoadedAt) {
+		t.Fatalf("GetEntry = %+v, %v, want a cache hit", e2, err)
+	}
+}
+
+// stubPeer 模拟一个远程节点，返回固定的响应
+type stubPeer struct {
+	res   *testpb.Response
+	err   error
+	calls int
+}
+
+func (p *stubPeer) Get(ctx context.Context, in *testpb.Request, out *testpb.Response) error {
+	p.calls++
+	if p.err != nil {
+		return p.err
+	}
+	out.Value, out.TtlMs, out.NoStore = p.res.Value, p.res.TtlMs, p.res.NoStore
+	return nil
+}
+
+// stubPicker 总是把 key 路由到同一个远程节点
+type stubPicker struct {
+	peer PeerGetter
+}
+
+func (p stubPicker) PickPeer(key string) (PeerGetter, bool) {
+	return p.peer, p.peer != nil
+}
+
+func TestGroup_PeerTTL(t *testing.T) {
+	peer := &stubPeer{res: &testpb.Response{Value: []byte("remote"), TtlMs: 5000}}
+	group := NewGroup("peer-ttl", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("should be loaded from peer")
+	}))
+	group.RegisterPeers(stubPicker{peer})
+
+	e, err := group.GetEntry("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.String() != "remote" || e.Source != SourcePeer {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	// 本地副本的过期时间与所属节点上的剩余存活时间一致
+	if ttl := e.ExpiresAt.Sub(e.LoadedAt); ttl != 5*time.Second {
+		t.Fatalf("local copy ttl = %v, want 5s", ttl)
+	}
+
+	if _, err := group.Get("k"); err != nil || peer.calls != 1 {
+		t.Fatalf("the second get should hit the hot cache, peer called %d times", peer.calls)
+	}
+}
+
+func TestGroup_FallbackPeers(t *testing.T) {
+	primary := &stubPeer{err: fmt.Errorf("primary is down")}
+	broken := &stubPeer{err: fmt.Errorf("fallback is down")}
+	dr := &stubPeer{res: &testpb.Response{Value: []byte("from-dr")}}
+	group := NewGroup("fallback-peers", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("from-db"), nil
+	}))
+	group.RegisterPeers(stubPicker{primary})
+	group.RegisterFallbackPeers(stubPicker{broken}, stubPicker{dr})
+
+	// 主集群失败后依次尝试备用集群，失败的备用集群会被跳过
+	if v, err := group.Get("k"); err != nil || v.String() != "from-dr" {
+		t.Fatalf("Get = %q, %v, want the value from the fallback cluster", v, err)
+	}
+	if primary.calls != 1 || broken.calls != 1 || dr.calls != 1 || group.Stats().FallbackLoads != 1 {
+		t.Fatalf("calls = %d/%d/%d, want every cluster tried once in order", primary.calls, broken.calls, dr.calls)
+	}
+
+	// 所有备用集群都失败时回退到 getter
+	dr.err = fmt.Errorf("dr is down")
+	if v, err := group.Get("k2"); err != nil || v.String() != "from-db" {
+		t.Fatalf("Get = %q, %v, want the value from the getter", v, err)
+	}
+}
+
+func TestGroup_KeyNormalizer(t *testing.T) {
+	var keys []string
+	group := NewGroup("normalized", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		keys = append(keys, key)
+		return []byte(key), nil
+	}), WithKeyNormalizer(func(key string) string {
+		return strings.ToLower(strings.TrimSpace(key))
+	}))
+
+	for _, key := range []string{"Foo ", "foo", " FOO"} {
+		if v, err := group.Get(key); err != nil || v.String() != "foo" {
+			t.Fatalf("Get(%q) = %q, %v", key, v, err)
+		}
+	}
+	if !reflect.DeepEqual(keys, []string{"foo"}) {
+		t.Fatalf("getter called with %q, want only the normalized key once", keys)
+	}
+	if _, err := group.Get("  "); err == nil {
+		t.Fatal("a key normalized to empty should be rejected")
+	}
+
+	// 按前缀查找和删除时前缀经过同样的规范化
+	if got := group.KeysWithPrefix("FO"); !reflect.DeepEqual(got, []string{"foo"}) {
+		t.Fatalf("KeysWithPrefix(FO) = %v, want [foo]", got)
+	}
+	if n := group.RemovePrefix("FO"); n != 1 {
+		t.Fatalf("RemovePrefix(FO) removed %d keys, want 1", n)
+	}
+}
+
+func TestGroup_NoCache(t *testing.T) {
+	loads := 0
+	group := NewGroup("no-cache", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		if key == "volatile" {
+			return nil, NoCache([]byte("now"))
+		}
+		return []byte("stable"), nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		if v, err := group.Get("volatile"); err != nil || v.String() != "now" {
+			t.Fatalf("Get(volatile) = %q, %v", v, err)
+		}
+		group.Get("stable")
+	}
+	if loads != 3 {
+		t.Fatalf("loads = %d, want the volatile key loaded every time and the stable one once", loads)
+	}
+	if e, _ := group.GetEntry("volatile"); e.StoredBytes != 0 {
+		t.Fatalf("volatile value should not be stored, got %d bytes", e.StoredBytes)
+	}
+
+	// 所属节点标记了不缓存的值，请求方也不会缓存
+	peer := &stubPeer{res: &testpb.Response{Value: []byte("remote"), NoStore: true}}
+	remote := NewGroup("no-cache-peer", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("should be loaded from peer")
+	}))
+	remote.RegisterPeers(stubPicker{peer})
+	remote.Get("k")
+	remote.Get("k")
+	if peer.calls != 2 {
+		t.Fatalf("peer called %d times, want the value never cached locally", peer.calls)
+	}
+}
+
+func TestGroup_Pin(t *testing.T) {
+	loads := 0
+	// 每个 key 加上值占 2 个字节，容量只能放下 3 个 key
+	group := NewGroup("pin", 6, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("v"), nil
+	}))
+
+	if err := group.Pin("a"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"b", "c", "d", "e"} {
+		group.Get(key)
+	}
+	loads = 0
+	group.Get("a")
+	if loads != 0 {
+		t.Fatal("pinned key should never be evicted")
+	}
+
+	// 固定的总量不能超过容量
+	if err := group.Pin("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := group.Pin("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := group.Pin("d"); err == nil {
+		t.Fatal("pinning more bytes than the budget should fail")
+	}
+
+	group.Unpin("a")
+	for _, key := range []string{"x", "y", "z"} {
+		group.Get(key)
+	}
+	loads = 0
+	group.Get("a")
+	if loads != 1 {
+		t.Fatal("unpinned key should be evicted again")
+	}
+}
+
+func TestGroup_PinOverflow(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	})
+	store := WithWriteThrough(func(key string, value []byte) error { return nil })
+
+	// 默认拒绝：固定的条目占满容量后，新的值照常返回但不写入缓存
+	group := NewGroup("pin-overflow-reject", 4, getter, store)
+	group.Pin("a")
+	group.Pin("b")
+	if err := group.Pin("c"); err == nil {
+		t.Fatal("pinning beyond the budget should fail")
+	}
+	if v, err := group.Get("d"); err != nil || v.String() != "v" {
+		t.Fatalf("Get(d) = %q, %v", v, err)
+	}
+	// 固定的条目变大后放不下时删除旧值，而不是留下过时的数据
+	if err := group.Put("a", []byte("vvv")); err != nil {
+		t.Fatal(err)
+	}
+	if s := group.Stats(); s.Bytes != 2 || s.Items != 1 || s.CacheFullRejects != 3 {
+		t.Fatalf("cache should stay within budget: %+v", s)
+	}
+
+	// 允许超出：缓存暂时超出容量
+	group = NewGroup("pin-overflow-allow", 4, getter, store, WithPinOverflow(PinOverflowAllow))
+	group.Pin("a")
+	group.Pin("b")
+	if err := group.Put("a", []byte("vvv")); err != nil {
+		t.Fatal(err)
+	}
+	if s := group.Stats(); s.Bytes != 6 || s.Items != 2 {
+		t.Fatalf("pinned entries should overshoot the budget: %+v", s)
+	}
+	if v, _ := group.Get("a"); v.String() != "vvv" {
+		t.Fatalf("Get(a) = %q, want vvv", v)
+	}
+}
+
+func TestGroup_CacheBytes(t *testing.T) {
+	testCases := []struct {
+		name       string
+		cacheBytes int64
+		loads      int // 依次获取 a、b、a 时调用 getter 的次数
+	}{
+		{"disabled", 0, 3},
+		{"unlimited", UnlimitedCacheBytes, 2},
+		{"limited", 2, 3},
+		{"enough", 2 << 10, 2},
+	}
+	for _, tc := range testCases {
+		loads := 0
+		group := NewGroup("cache-bytes-"+tc.name, tc.cacheBytes, GetterFunc(func(key string) ([]byte, error) {
+			loads++
+			return []byte("v"), nil
+		}))
+		for _, key := range []string{"a", "b", "a"} {
+			if _, err := group.Get(key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if loads != tc.loads {
+			t.Errorf("%s: got %d loads, want %d", tc.name, loads, tc.loads)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("negative cacheBytes other than UnlimitedCacheBytes should panic")
+		}
+	}()
+	NewGroup("cache-bytes-invalid", -2, GetterFunc(func(key string) ([]byte, error) {
+		return nil, nil
+	}))
+}
+
+func TestGroup_TTLFunc(t *testing.T) {
+	group := NewGroup("ttl-func", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(strings.Repeat("v", len(key))), nil
+	}), WithTTL(time.Hour), WithTTLFunc(func(key string, value []byte) time.Duration {
+		if len(value) > 3 {
+			return time.Minute
+		}
+		return 0
+	}))
+
+	small, _ := group.GetEntry("k")
+	large, _ := group.GetEntry("large")
+	if !small.ExpiresAt.IsZero() {
+		t.Fatalf("small value expires at %v, want never", small.ExpiresAt)
+	}
+	if ttl := time.Until(large.ExpiresAt); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("large value ttl = %v, want about a minute", ttl)
+	}
+}
+
+func TestGroup_GetFresh(t *testing.T) {
+	var mu sync.Mutex
+	version := 1
+	group := NewGroup("fresh", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return []byte(strconv.Itoa(version)), nil
+	}))
+
+	if v, err := group.GetFresh("k", time.Hour); err != nil || v.String() != "1" {
+		t.Fatalf("GetFresh = %q, %v, want 1", v, err)
+	}
+
+	mu.Lock()
+	version = 2
+	mu.Unlock()
+
+	// 值还足够新，不会触发刷新
+	if v, _ := group.GetFresh("k", time.Hour); v.String() != "1" {
+		t.Fatalf("GetFresh = %q, want the cached value", v)
+	}
+
+	// 值太旧，立即返回旧值并在后台刷新
+	if v, _ := group.GetFresh("k", 0); v.String() != "1" {
+		t.Fatalf("GetFresh = %q, want the stale value immediately", v)
+	}
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if v, _ := group.Get("k"); v.String() == "2" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("the stale value should be refreshed in background")
+}
+
+func TestGroup_GetFreshRefreshesOnce(t *testing.T) {
+	var loads int32
+	group := NewGroup("fresh-once", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(20 * time.Millisecond)
+		return []byte("v"), nil
+	}))
+	group.Get("k")
+	time.Sleep(2 * time.Millisecond)
+
+	// 同一个旧值触发的刷新，开始执行时值已经被第一次刷新替换，不应再次调用 getter
+	for i := 0; i < 50; i++ {
+		group.GetFresh("k", time.Millisecond)
+	}
+	waitIdle(t, group)
+	if n := atomic.LoadInt32(&loads); n != 2 {
+		t.Fatalf("getter called %d times, want 2", n)
+	}
+}
+
+func TestGroup_BackgroundLoadContext(t *testing.T) {
+	ids := make(chan string, 2)
+	group := NewGroupContext("background-ctx", 2<<10, GetterContextFunc(func(ctx context.Context, key string) ([]byte, error) {
+		ids <- RequestIDFromContext(ctx)
+		return []byte("v"), nil
+	}))
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+
+	// 后台加载和刷新都带着调用方的请求 ID
+	group.GetOrQueueContext(ctx, "k", nil)
+	if id := <-ids; id != "req-1" {
+		t.Fatalf("background load request id = %q, want req-1", id)
+	}
+	waitIdle(t, group)
+	group.GetFreshContext(ctx, "k", 0)
+	if id := <-ids; id != "req-1" {
+		t.Fatalf("background refresh request id = %q, want req-1", id)
+	}
+}
+
+func TestGroup_BloomFilter(t *testing.T) {
+	filter := bloom.New(100, 0.01)
+	filter.Add("known")
+	loads := 0
+	group := NewGroup("bloom", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte(key), nil
+	}), WithBloomFilter(filter))
+
+	if v, err := group.Get("known"); err != nil || v.String() != "known" {
+		t.Fatalf("Get(known) = %q, %v", v, err)
+	}
+	if _, err := group.Get("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(unknown) err = %v, want %v", err, ErrNotFound)
+	}
+	if loads != 1 || group.Stats().BloomRejects != 1 {
+		t.Fatalf("loads = %d, rejects = %d, want the getter skipped for unknown keys", loads, group.Stats().BloomRejects)
+	}
+
+	// key 集合变化后替换成新的过滤器
+	rebuilt := bloom.New(100, 0.01)
+	rebuilt.Add("known")
+	rebuilt.Add("unknown")
+	group.SetBloomFilter(rebuilt)
+	if _, err := group.Get("unknown"); err != nil {
+		t.Fatalf("Get(unknown) after rebuild err = %v", err)
+	}
+}
+
+func TestGroup_Put(t *testing.T) {
+	var mu sync.Mutex
+	store := map[string]string{"a": "1", "bad": "1"}
+	entered, release := make(chan struct{}), make(chan struct{})
+	group := NewGroup("write-through", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		v := store[key]
+		mu.Unlock()
+		if key == "slow" {
+			close(entered)
+			<-release
+		}
+		return []byte(v), nil
+	}), WithWriteThrough(func(key string, value []byte) error {
+		if key == "bad" {
+			return fmt.Errorf("store is read-only")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		store[key] = string(value)
+		return nil
+	}))
+
+	group.Get("a")
+	if err := group.Put("a", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := group.Get("a"); v.String() != "2" || store["a"] != "2" {
+		t.Fatalf("Get(a) = %q, store = %q", v, store["a"])
+	}
+
+	// 写入失败时缓存保持不变
+	group.Get("bad")
+	if err := group.Put("bad", []byte("2")); err == nil {
+		t.Fatal("Put should return the write error")
+	}
+	if v, _ := group.Get("bad"); v.String() != "1" {
+		t.Fatalf("Get(bad) = %q, want the cached value 1", v)
+	}
+
+	// 写入之前开始的加载读到的旧值不会覆盖新值
+	go group.Get("slow")
+	<-entered
+	done := make(chan error)
+	go func() { done <- group.Put("slow", []byte("new")) }()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := group.Get("slow"); v.String() != "new" {
+		t.Fatalf("Get(slow) = %q, want new", v)
+	}
+}
+
+func TestGroup_Validator(t *testing.T) {
+	errCorrupt := fmt.Errorf("corrupt value")
+	peer := &stubPeer{res: &testpb.Response{Value: []byte("garbage")}}
+	loads := 0
+	group := NewGroup("validator", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		if key == "bad" {
+			return []byte("garbage"), nil
+		}
+		return []byte("ok-" + key), nil
+	}), WithValidator(func(key string, value []byte) error {
+		if !strings.HasPrefix(string(value), "ok-") {
+			return errCorrupt
+		}
+		return nil
+	}))
+
+	// getter 返回的值校验失败时返回错误，不会被缓存
+	for i := 0; i < 2; i++ {
+		if _, err := group.Get("bad"); !errors.Is(err, errCorrupt) {
+			t.Fatalf("Get(bad) error = %v, want %v", err, errCorrupt)
+		}
+	}
+	if loads != 2 {
+		t.Fatalf("getter called %d times, want 2 (invalid values must not be cached)", loads)
+	}
+
+	// 远程节点返回的值校验失败时回退到本地 getter
+	group.RegisterPeers(stubPicker{peer})
+	if v, err := group.Get("k"); err != nil || v.String() != "ok-k" {
+		t.Fatalf("Get(k) = %q, %v, want the value from the getter", v, err)
+	}
+	if s := group.Stats(); s.InvalidValues != 3 || s.PeerErrors != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestNoopPeerPicker(t *testing.T) {
+	group := NewGroup("noop-peers", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local-" + key), nil
+	}))
+	group.RegisterPeers(NoopPeerPicker{})
+
+	if v, err := group.Get("k"); err != nil || v.String() != "local-k" {
+		t.Fatalf("Get = %q, %v, want the value from the getter", v, err)
+	}
+	if s := group.Stats(); s.LocalLoads != 1 || s.PeerLoads != 0 || s.PeerErrors != 0 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestGroup_OnEvicted(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	})
+
+	// 同步通知按淘汰顺序在淘汰发生时送达
+	var evicted []string
+	group := NewGroup("on-evicted", 4, getter, WithOnEvicted(func(key string, value ByteView) {
+		evicted = append(evicted, key+"="+value.String())
+	}, EvictionSync))
+	for _, k := range []string{"a", "b", "c", "d"} {
+		group.Get(k)
+	}
+	group.Remove("d")
+	if want := []string{"a=v", "b=v", "d=v"}; !reflect.DeepEqual(evicted, want) {
+		t.Fatalf("evicted = %v, want %v", evicted, want)
+	}
+
+	// 异步通知在后台任务池中送达
+	ch := make(chan string, 4)
+	group = NewGroup("on-evicted-async", 4, getter, WithOnEvicted(func(key string, value ByteView) {
+		ch <- key
+	}, EvictionAsync), WithWorkerPool(1, 4))
+	for _, k := range []string{"a", "b", "c"} {
+		group.Get(k)
+	}
+	select {
+	case k := <-ch:
+		if k != "a" {
+			t.Fatalf("evicted %q, want a", k)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("async eviction notification was not delivered")
+	}
+	if drops := group.Stats().EvictionNotifyDrops; drops != 0 {
+		t.Fatalf("dropped %d notifications", drops)
+	}
+}
+
+func TestGroup_StrandedKeys(t *testing.T) {
+	group := NewGroup("stranded", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	if keys := group.StrandedKeys(); keys != nil {
+		t.Fatalf("StrandedKeys = %v before RegisterPeers", keys)
+	}
+	for _, k := range []string{"a", "p:1", "b", "p:2"} {
+		group.Get(k)
+	}
+
+	// 重新平衡之后，"p:" 开头的 key 属于了其它节点
+	group.RegisterPeers(prefixPicker{"p": &stubBatchPeer{name: "p"}})
+	if keys := group.StrandedKeys(); !reflect.DeepEqual(keys, []string{"p:2", "p:1"}) {
+		t.Fatalf("StrandedKeys = %v, want [p:2 p:1]", keys)
+	}
+}
+
+func TestGroup_FallbackPolicy(t *testing.T) {
+	errDown := fmt.Errorf("owner is down")
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local-" + key), nil
+	})
+
+	// 分组默认回退，单个请求可以要求不回退
+	group := NewGroup("fallback-policy", 2<<10, getter)
+	group.RegisterPeers(stubPicker{&stubPeer{err: errDown}})
+	strict := ContextWithFallbackPolicy(context.Background(), FallbackStrict)
+	if _, err := group.GetContext(strict, "a"); !errors.Is(err, errDown) {
+		t.Fatalf("strict GetContext error = %v, want %v", err, errDown)
+	}
+	if v, err := group.Get("a"); err != nil || v.String() != "local-a" {
+		t.Fatalf("Get = %q, %v, want the local value", v, err)
+	}
+
+	// 分组默认不回退，单个请求可以强制回退
+	group = NewGroup("fallback-policy-strict", 2<<10, getter, WithFallbackPolicy(FallbackStrict))
+	group.RegisterPeers(stubPicker{&stubPeer{err: errDown}})
+	if _, err := group.Get("a"); !errors.Is(err, errDown) {
+		t.Fatalf("Get error = %v, want %v", err, errDown)
+	}
+	local := ContextWithFallbackPolicy(context.Background(), FallbackLocal)
+	if v, err := group.GetContext(local, "a"); err != nil || v.String() != "local-a" {
+		t.Fatalf("GetContext = %q, %v, want the local value", v, err)
+	}
+	if s := group.Stats(); s.PeerErrors != 2 || s.LocalLoads != 1 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestGroup_OnMiss(t *testing.T) {
+	ch := make(chan MissInfo, 8)
+	peer := &stubPeer{res: &testpb.Response{Value: []byte("remote")}}
+	group := NewGroup("on-miss", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	}), WithOnMiss(func(info MissInfo) { ch <- info }, 1))
+	group.RegisterPeers(prefixPicker{"p": peer})
+
+	group.Get("a")
+	group.Get("p:1")
+	group.Get("a") // 命中缓存，不会触发回调
+
+	got := map[string]MissInfo{}
+	for i := 0; i < 2; i++ {
+		select {
+		case info := <-ch:
+			got[info.Key] = info
+		case <-time.After(time.Second):
+			t.Fatal("miss callback was not delivered")
+		}
+	}
+	if info := got["a"]; info.Source != SourceGetter || info.Peer != "" || info.Err != nil {
+		t.Fatalf("unexpected miss info for a: %+v", info)
+	}
+	if info := got["p:1"]; info.Source != SourcePeer || info.Peer == "" || info.Latency <= 0 {
+		t.Fatalf("unexpected miss info for p:1: %+v", info)
+	}
+
+	// 每 3 次加载抽样一次
+	sampled := 0
+	group = NewGroup("on-miss-sampled", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	}), WithOnMiss(func(info MissInfo) { sampled++ }, 3), WithWorkerPool(1, 16))
+	for i := 0; i < 9; i++ {
+		group.Get(strconv.Itoa(i))
+	}
+	done := make(chan struct{})
+	group.pool.Submit(func() { close(done) })
+	<-done
+	if sampled != 3 {
+		t.Fatalf("sampled %d misses, want 3", sampled)
+	}
+}
+
+func TestGroup_MicroCache(t *testing.T) {
+	var calls int
+	// 缓存关闭，每次 Get 都会进入 load
+	group := NewGroup("micro-cache", 0, GetterFunc(func(key string) ([]byte, error) {
+		calls++
+		return []byte(strconv.Itoa(calls)), nil
+	}), WithMicroCache(time.Hour, 10))
+
+	for i := 0; i < 3; i++ {
+		if v, _ := group.Get("k"); v.String() != "1" {
+			t.Fatalf("Get = %s, want the retained value", v)
+		}
+	}
+	if calls != 1 || group.Stats().MicroCacheHits != 2 {
+		t.Fatalf("calls = %d, hits = %d", calls, group.Stats().MicroCacheHits)
+	}
+
+	group.Remove("k")
+	if v, _ := group.Get("k"); v.String() != "2" {
+		t.Fatalf("Remove should drop the retained value, got %s", v)
+	}
+	group.ResetStats()
+	if hits := group.Stats().MicroCacheHits; hits != 0 {
+		t.Fatalf("hits after ResetStats = %d", hits)
+	}
+}
+
+// hedgePicker 给出固定的所属节点和第二选择节点
+type hedgePicker struct {
+	primary, secondary PeerGetter
+}
+
+func (p hedgePicker) PickPeer(key string) (PeerGetter, bool) { return p.primary, true }
+
+func (p hedgePicker) PickSecondary(key string) (PeerGetter, bool) { return p.secondary, true }
+
+// slowPeer 在 ctx 结束之前不返回，记录请求是否为对冲请求以及是否被取消
+type slowPeer struct {
+	value    string
+	delay    time.Duration
+	hedged   bool
+	canceled chan struct{} // 请求被取消时关闭
+}
+
+func (p *slowPeer) Get(ctx context.Context, in *testpb.Request, out *testpb.Response) error {
+	p.hedged = isHedge(ctx)
+	select {
+	case <-time.After(p.delay):
+		out.Value = []byte(p.value)
+		return nil
+	case <-ctx.Done():
+		close(p.canceled)
+		return ctx.Err()
+	}
+}
+
+func TestGroup_Hedging(t *testing.T) {
+	primary := &slowPeer{value: "primary", delay: time.Hour, canceled: make(chan struct{})}
+	secondary := &slowPeer{value: "secondary"}
+	group := NewGroup("hedging", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("should be loaded from a peer")
+	}), WithHedging(5*time.Millisecond))
+	group.RegisterPeers(hedgePicker{primary: primary, secondary: secondary})
+
+	if v, err := group.Get("slow"); err != nil || v.String() != "secondary" {
+		t.Fatalf("Get = %q, %v, want the hedged value", v, err)
+	}
+	// 输掉的请求被取消
+	select {
+	case <-primary.canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the request to the primary should be canceled")
+	}
+	if !secondary.hedged || primary.hedged {
+		t.Fatal("only the request to the secondary should be marked as hedged")
+	}
+	if s := group.Stats(); s.Hedges != 1 || s.HedgeWins != 1 {
+		t.Fatalf("hedges = %d, wins = %d", s.Hedges, s.HedgeWins)
+	}
+
+	// 所属节点在 delay 之内返回时不发起对冲
+	primary.delay = 0
+	if v, _ := group.Get("fast"); v.String() != "primary" || group.Stats().Hedges != 1 {
+		t.Fatalf("Get = %q, hedges = %d", v, group.Stats().Hedges)
+	}
+}
+
+func TestGroup_Increment(t *testing.T) {
+	group := NewGroup("increment", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("not a number"), nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := group.Increment("views", 2); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := group.Increment("views", -1); n != 99 {
+		t.Fatalf("counter = %d, want 99", n)
+	}
+	if v, _ := group.Get("views"); v.String() != "99" {
+		t.Fatalf("Get = %q, want the counter value", v)
+	}
+
+	// 已有的值不是整数时不修改
+	group.Get("text")
+	if _, err := group.Increment("text", 1); err == nil {
+		t.Fatal("incrementing a non-numeric value should fail")
+	}
+	if v, _ := group.Get("text"); v.String() != "not a number" {
+		t.Fatalf("value should be unchanged, got %q", v)
+	}
+}
+
+func TestGroup_WritesWaitForStrictLoads(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	group := NewGroup("strict-writes", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		entered <- struct{}{}
+		<-release
+		return []byte("5"), nil
+	}), WithWriteThrough(func(key string, value []byte) error {
+		return nil
+	}))
+	strict := ContextWithFallbackPolicy(context.Background(), FallbackStrict)
+
+	// 没有注册节点时不回退的加载同样写入 mainCache，Put 和 Increment 要等它结束，不能被它读到的旧值覆盖
+	for _, tc := range []struct {
+		key   string
+		write func(key string) error
+		want  string
+	}{
+		{"put", func(key string) error { return group.Put(key, []byte("new")) }, "new"},
+		{"incr", func(key string) error {
+			_, err := group.Increment(key, 1)
+			return err
+		}, "6"},
+	} {
+		go group.GetContext(strict, tc.key)
+		<-entered
+		done := make(chan error)
+		go func() { done <- tc.write(tc.key) }()
+		time.Sleep(10 * time.Millisecond)
+		release <- struct{}{}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := group.Get(tc.key); v.String() != tc.want {
+			t.Fatalf("Get(%s) = %q, want %q", tc.key, v, tc.want)
+		}
+	}
+}
+
+func TestGroup_ReadOnlyReplica(t *testing.T) {
+	peer := &stubPeer{res: &testpb.Response{Value: []byte("remote")}}
+	group := NewGroup("read-only", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		t.Fatal("a read-only replica should never call the getter")
+		return nil, nil
+	}), WithReadOnlyReplica())
+	group.RegisterPeers(stubPicker{peer})
+
+	if v, err := group.Get("k"); err != nil || v.String() != "remote" {
+		t.Fatalf("Get = %q, %v, want the peer's value", v, err)
+	}
+	peer.err = fmt.Errorf("peer is down")
+	if _, err := group.Get("other"); !errors.Is(err, ErrNoBackingStore) {
+		t.Fatalf("err = %v, want ErrNoBackingStore", err)
+	}
+	if err := group.Put("k", []byte("v")); !errors.Is(err, ErrNoBackingStore) {
+		t.Fatalf("Put err = %v, want ErrNoBackingStore", err)
+	}
+}
+
+func TestGroup_GetWithLoader(t *testing.T) {
+	peer := &stubPeer{res: &testpb.Response{Value: []byte("remote")}}
+	group := NewGroup("get-with-loader", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("default-" + key), nil
+	}))
+	group.RegisterPeers(stubPicker{peer})
+
+	// loader 代替 getter 和所属节点，加载出的值照常缓存
+	v, err := group.GetWithLoader("k", func() ([]byte, error) { return []byte("custom"), nil })
+	if err != nil || v.String() != "custom" {
+		t.Fatalf("GetWithLoader = %q, %v, want custom", v, err)
+	}
+	if peer.calls != 0 {
+		t.Fatalf("peer called %d times, want 0", peer.calls)
+	}
+	if v, err := group.Get("k"); err != nil || v.String() != "custom" {
+		t.Fatalf("Get = %q, %v, want the cached custom value", v, err)
+	}
+
+	// 并发加载同一个 key 时只有第一个调用方的 loader 被调用
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		v, err := group.GetWithLoader("shared", func() ([]byte, error) {
+			close(started)
+			<-release
+			return []byte("first"), nil
+		})
+		if err != nil || v.String() != "first" {
+			t.Errorf("first caller got %q, %v", v, err)
+		}
+	}()
+	<-started
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := group.GetWithLoader("shared", func() ([]byte, error) {
+				t.Error("a joined caller's loader should not be called")
+				return nil, nil
+			})
+			if err != nil || v.String() != "first" {
+				t.Errorf("joined caller got %q, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func TestGroup_OnEvictedReason(t *testing.T) {
+	var reasons, plain []string
+	group := NewGroup("on-evicted-reason", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}), WithOnEvictedReason(func(key string, value ByteView, reason lru.EvictionReason) {
+		reasons = append(reasons, key+"="+value.String()+":"+reason.String())
+	}, EvictionSync), WithWriteThrough(func(key string, value []byte) error { return nil }))
+	group.Get("a")
+	group.Put("a", []byte("w"))
+	group.Remove("a")
+	if want := []string{"a=v:replaced", "a=w:removed"}; !reflect.DeepEqual(reasons, want) {
+		t.Fatalf("reasons = %v, want %v", reasons, want)
+	}
+
+	// 旧的两个参数的回调看不到覆盖
+	group = NewGroup("on-evicted-plain", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}), WithOnEvicted(func(key string, value ByteView) {
+		plain = append(plain, key+"="+value.String())
+	}, EvictionSync), WithWriteThrough(func(key string, value []byte) error { return nil }))
+	group.Get("a")
+	group.Put("a", []byte("w"))
+	group.Remove("a")
+	if want := []string{"a=w"}; !reflect.DeepEqual(plain, want) {
+		t.Fatalf("evicted = %v, want %v", plain, want)
+	}
+}
+
+func TestGroup_SetCacheBytes(t *testing.T) {
+	group := NewGroup("set-cache-bytes", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value"), nil
+	}), WithWriteThrough(func(key string, value []byte) error { return nil }))
+	for i := 0; i < 20; i++ {
+		group.Get("k" + strconv.Itoa(i))
+	}
+	if s := group.Stats(); s.Items != 20 {
+		t.Fatalf("items = %d, want 20", s.Items)
+	}
+
+	// 缩容时立即淘汰到新的容量以内
+	group.SetCacheBytes(30)
+	if s := group.Stats(); s.Bytes > 30 || s.Items == 0 {
+		t.Fatalf("after shrinking: bytes = %d, items = %d", s.Bytes, s.Items)
+	}
+	// 关闭缓存时清空所有条目，之后的读取不再缓存
+	group.SetCacheBytes(0)
+	group.Get("k0")
+	if s := group.Stats(); s.Items != 0 || s.Bytes != 0 {
+		t.Fatalf("after disabling: bytes = %d, items = %d", s.Bytes, s.Items)
+	}
+
+	// 并发读写期间反复调整容量，结束之后仍然遵守最后的容量
+	group.SetCacheBytes(2 << 10)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := "c" + strconv.Itoa(w) + "-" + strconv.Itoa(i%50)
+				if i%3 == 0 {
+					group.Put(key, []byte("put"))
+				} else {
+					group.Get(key)
+				}
+			}
+		}(w)
+	}
+	for _, n := range []int64{64, 0, UnlimitedCacheBytes, 256, 1 << 10} {
+		group.SetCacheBytes(n)
+	}
+	wg.Wait()
+	group.SetCacheBytes(100)
+	if s := group.Stats(); s.Bytes > 100 {
+		t.Fatalf("bytes = %d after resizing to 100", s.Bytes)
+	}
+}
+
+// prefixPeer 记录收到的按前缀删除请求
+type prefixPeer struct {
+	stubPeer
+	prefixes []string
+}
+
+func (p *prefixPeer) RemovePrefix(ctx context.Context, in *testpb.DeleteRequest) error {
+	p.prefixes = append(p.prefixes, in.GetKey())
+	return nil
+}
+
+// listPicker 能列出所有节点，但不把任何 key 路由到其它节点
+type listPicker []PeerGetter
+
+func (p listPicker) PickPeer(key string) (PeerGetter, bool) { return nil, false }
+func (p listPicker) Peers() []PeerGetter                    { return p }
+
+func TestGroup_RemovePrefix(t *testing.T) {
+	loads := 0
+	group := NewGroup("remove-prefix", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("v"), nil
+	}))
+	for _, key := range []string{"user:1:name", "user:1:email", "user:12:name", "order:1"} {
+		group.Get(key)
+	}
+
+	got := group.KeysWithPrefix("user:1:")
+	sort.Strings(got)
+	if want := []string{"user:1:email", "user:1:name"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("KeysWithPrefix = %v, want %v", got, want)
+	}
+	if got := group.KeysWithPrefix("missing:"); len(got) != 0 {
+		t.Fatalf("KeysWithPrefix(missing:) = %v, want none", got)
+	}
+
+	if n := group.RemovePrefix("user:1:"); n != 2 {
+		t.Fatalf("RemovePrefix removed %d keys, want 2", n)
+	}
+	if n := group.RemovePrefix("missing:"); n != 0 {
+		t.Fatalf("RemovePrefix(missing:) removed %d keys", n)
+	}
+	got = group.KeysWithPrefix("")
+	sort.Strings(got)
+	if want := []string{"order:1", "user:12:name"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("remaining keys = %v, want %v", got, want)
+	}
+	group.Get("user:1:name")
+	if loads != 5 {
+		t.Fatalf("removed key should be reloaded, got %d loads", loads)
+	}
+
+	// 空前缀不会清空缓存
+	if n := group.RemovePrefix(""); n != 0 || len(group.KeysWithPrefix("")) != 3 {
+		t.Fatalf("RemovePrefix(\"\") removed %d keys", n)
+	}
+	if _, err := group.RemovePrefixEverywhere(context.Background(), ""); err == nil {
+		t.Fatal("RemovePrefixEverywhere should reject an empty prefix")
+	}
+
+	// 广播给所有节点，不支持按前缀删除的节点报告错误，但不影响其它节点
+	a, b := &prefixPeer{}, &prefixPeer{}
+	group.RegisterPeers(listPicker{a, &stubPeer{}, b})
+	n, err := group.RemovePrefixEverywhere(context.Background(), "user:")
+	if n != 2 || err == nil {
+		t.Fatalf("RemovePrefixEverywhere = %d, %v, want 2 and an error", n, err)
+	}
+	if !reflect.DeepEqual(a.prefixes, []string{"user:"}) || !reflect.DeepEqual(b.prefixes, []string{"user:"}) {
+		t.Fatalf("peers got %v and %v", a.prefixes, b.prefixes)
+	}
+}
+
+func TestGroup_Clear(t *testing.T) {
+	loads := 0
+	group := NewGroup("clear", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("v"), nil
+	}), WithMicroCache(time.Minute, 10))
+	group.Get("a")
+	group.Get("b")
+
+	group.Clear()
+	if s := group.Stats(); s.Items != 0 || s.Bytes != 0 || s.Evictions != 2 {
+		t.Fatalf("after Clear: %+v", s)
+	}
+	// 保留的加载结果也被丢弃，不会拿到清空之前的值
+	group.Get("a")
+	if loads != 3 {
+		t.Fatalf("got %d loads, want a reload after Clear", loads)
+	}
+}
+
+func TestGroup_EvictionPolicy(t *testing.T) {
+	loads := 0
+	group := NewGroup("eviction-policy", 6, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("v"), nil
+	}), WithEvictionPolicy(lru.LFU))
+	for i := 0; i < 3; i++ {
+		group.Get("a")
+	}
+	// 一次性的扫描不会挤掉热点 key
+	for _, k := range []string{"b", "c", "d", "e"} {
+		group.Get(k)
+	}
+	loads = 0
+	group.Get("a")
+	if loads != 0 {
+		t.Fatal("the hot key should survive the scan under LFU")
+	}
+}
+
+// noopStore 什么都不保存
+type noopStore struct{}
+
+func (noopStore) AddWithExpire(key string, value lru.Value, expire time.Time) {}
+func (noopStore) Get(key string) (lru.Value, bool)                            { return nil, false }
+func (noopStore) Remove(key string) bool                                      { return false }
+func (noopStore) RemoveOldest() bool                                          { return false }
+func (noopStore) Range(f func(key string, value lru.Value) bool)              {}
+func (noopStore) Clear()                                                      {}
+func (noopStore) Resize(maxBytes int64)                                       {}
+func (noopStore) Len() int                                                    { return 0 }
+func (noopStore) Bytes() int64                                                { return 0 }
+
+func TestGroup_Store(t *testing.T) {
+	loads := 0
+	group := NewGroup("store", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("v"), nil
+	}), WithStore(func(maxBytes int64, onEvicted func(string, lru.Value, lru.EvictionReason)) Store {
+		return noopStore{}
+	}))
+	for i := 0; i < 3; i++ {
+		if v, err := group.Get("k"); err != nil || v.String() != "v" {
+			t.Fatalf("Get = %q, %v", v, err)
+		}
+	}
+	if loads != 3 {
+		t.Fatalf("got %d loads, want every Get to reload with a no-op store", loads)
+	}
+	// 不支持固定条目的存储上 Pin 失败
+	if err := group.Pin("k"); err == nil {
+		t.Fatal("Pin should fail on a store without pinning support")
+	}
+	if s := group.Stats(); s.Items != 0 || s.Overhead != 0 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
@@ -0,0 +1,50 @@
+package mini_groupcache
+
+// HealthWeights 是 Group.Health 中各项指标的权重，只有相对大小有意义，不需要加起来等于 1
+type HealthWeights struct {
+	HitRate     float64 // 命中率的权重
+	Churn       float64 // 淘汰压力的权重
+	GetterError float64 // getter 错误率的权重
+}
+
+// DefaultHealthWeights 是默认的权重，命中率占 40%，淘汰压力和 getter 错误率各占 30%
+var DefaultHealthWeights = HealthWeights{HitRate: 0.4, Churn: 0.3, GetterError: 0.3}
+
+// HealthScore 是分组的综合健康度以及参与计算的各项指标
+type HealthScore struct {
+	// Score 取值 0 到 1，越大越健康，按权重对下面三项求加权平均：
+	//
+	//	Score = (w.HitRate*HitRate + w.Churn*(1-EvictionChurn) + w.GetterError*(1-GetterErrorRate)) / (w.HitRate + w.Churn + w.GetterError)
+	Score float64
+
+	HitRate         float64 // CacheHits / Gets，还没有请求时为 1
+	EvictionChurn   float64 // Stats.EvictionChurn 截断到 [0, 1]，接近 1 说明缓存正在被新 key 冲刷
+	GetterErrorRate float64 // LocalLoadErrs / (LocalLoads + LocalLoadErrs)，还没有调用过 getter 时为 0
+}
+
+// Health 返回分组的综合健康度，供健康检查接口按一个阈值决定是否降级或拒绝流量，权重见 WithHealthWeights
+// 只读取已经在统计的计数器，开销与 Stats 相当；命中率和错误率是自上次 ResetStats 以来的累计值，淘汰压力是滚动估计值
+func (g *Group) Health() HealthScore {
+	gets, hits := g.stats.gets.Get(), g.stats.cacheHits.Get()
+	loads, loadErrs := g.stats.localLoads.Get(), g.stats.localLoadErrs.Get()
+	cs := g.mainCache.stats()
+
+	h := HealthScore{HitRate: 1}
+	if gets > 0 {
+		h.HitRate = float64(hits) / float64(gets)
+	}
+	if cs.newKeyRate > 0 {
+		h.EvictionChurn = cs.evictionRate / cs.newKeyRate
+		if h.EvictionChurn > 1 {
+			h.EvictionChurn = 1
+		}
+	}
+	if loads+loadErrs > 0 {
+		h.GetterErrorRate = float64(loadErrs) / float64(loads+loadErrs)
+	}
+
+	w := g.healthWeights
+	h.Score = (w.HitRate*h.HitRate + w.Churn*(1-h.EvictionChurn) + w.GetterError*(1-h.GetterErrorRate)) /
+		(w.HitRate + w.Churn + w.GetterError)
+	return h
+}
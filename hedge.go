@@ -0,0 +1,86 @@
+package mini_groupcache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+type hedgeKey struct{}
+
+// withHedge 返回标记为对冲请求的 ctx：发出请求时带上 hedgeHeader，收到请求的节点据此直接在本地加载
+func withHedge(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hedgeKey{}, true)
+}
+
+// isHedge 判断 ctx 是否属于一个对冲请求
+func isHedge(ctx context.Context) bool {
+	hedge, _ := ctx.Value(hedgeKey{}).(bool)
+	return hedge
+}
+
+// peerResult 是向某个节点请求的结果，hedge 为 true 表示来自第二选择节点
+type peerResult struct {
+	value ByteView
+	err   error
+	hedge bool
+}
+
+// getFromOwner 向 key 的所属节点 peer 获取值，开启了 WithHedging 且 PeerPicker 能给出第二选择节点时，
+// peer 在 hedgeDelay 之内没有返回就同时向第二选择节点请求，先成功的一方胜出，另一方的请求随即被取消
+// 返回提供值的节点；两个节点都失败时返回 peer 的错误，peer 在发起对冲之前就失败时不再对冲，由调用方照常回退
+func (g *Group) getFromOwner(ctx context.Context, peer PeerGetter, key string) (ByteView, PeerGetter, error) {
+	hp, ok := g.peers.(HedgePeerPicker)
+	if g.hedgeDelay <= 0 || !ok {
+		value, err := g.getFromPeer(ctx, peer, key)
+		return value, peer, err
+	}
+	secondary, ok := hp.PickSecondary(key)
+	if !ok {
+		value, err := g.getFromPeer(ctx, peer, key)
+		return value, peer, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// 容量为 2，输掉的一方不会因为没人接收而阻塞
+	results := make(chan peerResult, 2)
+	get := func(ctx context.Context, p PeerGetter, hedge bool) {
+		value, err := g.getFromPeer(ctx, p, key)
+		results <- peerResult{value: value, err: err, hedge: hedge}
+	}
+	go get(ctx, peer, false)
+
+	timer := time.NewTimer(g.hedgeDelay)
+	defer timer.Stop()
+	hedged, pending := false, 1
+	var ownerErr error
+	for {
+		select {
+		case <-timer.C:
+			hedged, pending = true, pending+1
+			g.stats.hedges.Add(1)
+			go get(withHedge(ctx), secondary, true)
+		case r := <-results:
+			pending--
+			// key 不存在也是确定的答复，第二选择节点会查询数据源，它的答复与所属节点的一样可信
+			if r.err == nil || errors.Is(r.err, ErrNotFound) {
+				if r.hedge {
+					g.stats.hedgeWins.Add(1)
+					return r.value, secondary, r.err
+				}
+				return r.value, peer, r.err
+			}
+			if r.hedge {
+				g.peerCounters(secondary).errors.Add(1)
+				log.Println("[Groupcache] Failed to get from hedge peer", r.err)
+			} else {
+				ownerErr = r.err
+			}
+			if !hedged || pending == 0 {
+				return ByteView{}, peer, ownerErr
+			}
+		}
+	}
+}
@@ -1,26 +1,149 @@
 package mini_groupcache
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/golang/protobuf/proto"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"mini-groupcache/consistenthash"
 	"mini-groupcache/testpb"
 	"net/http"
 	"net/url"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	defaultBasePath = "/_groupcache/"
 	defaultReplicas = 50
+
+	// 节点 HTTP 服务默认的超时时间，防止慢速连接（slowloris）和泄漏的空闲连接耗尽节点资源
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 10 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+
+	// 以下划线开头的路径是节点间的管理接口，不会与 <groupname>/<key> 形式的请求冲突
+	fingerprintPath = "_fingerprint"
+	candidatesPath  = "_candidates"
+	exportPath      = "_export"
+	statsPath       = "_stats"
+	batchPath       = "_batch"
+	incrementPath   = "_increment"
+	prefixPath      = "_remove_prefix"
+
+	// ownerHeader 是单跳模式下节点拒绝请求时，告知请求方 key 真正归属节点的响应头
+	ownerHeader = "X-Groupcache-Owner"
+
+	// hopsHeader 记录请求已经在节点之间转发的次数，每转发一次加一
+	hopsHeader = "X-Groupcache-Hops"
+	// 正常情况下请求只需一跳就能到达所属节点，各节点配置短暂不一致时可能多转发一次
+	defaultMaxHops = 3
+
+	// 向其它节点请求遇到网络错误时的重试次数和退避时间，退避时间会加上随机抖动，避免多个节点同时重试
+	peerRetries      = 2
+	peerRetryBackoff = 10 * time.Millisecond
+
+	// hedgeHeader 标记对冲请求，收到的节点不再转发给 key 的所属节点，见 WithHedging
+	hedgeHeader = "X-Groupcache-Hedge"
+	// protocolHeader 携带发送方的节点间协议版本，请求和响应中都有
+	protocolHeader = "X-Groupcache-Protocol"
+	// requestIDHeader 携带请求 ID，见 ContextWithRequestID，节点在响应中原样返回
+	requestIDHeader = "X-Groupcache-Request-Id"
+	// notFoundHeader 标记 404 响应是 key 不存在（ErrNotFound），而不是节点上没有这个分组
+	notFoundHeader = "X-Groupcache-Not-Found"
 )
 
+// ProtocolVersion 是本节点使用的节点间协议版本，消息格式或路由发生不兼容的变化时加一
+// 节点只接受版本相同的请求和响应；没有携带版本的一方视为加入版本号之前的节点，按版本 1 处理
+const ProtocolVersion = 1
+
+// ErrProtocolMismatch 表示对方节点使用的协议版本与本节点不兼容，通常出现在滚动升级的过程中
+var ErrProtocolMismatch = errors.New("incompatible peer protocol version")
+
+// ErrTooManyHops 表示请求在节点之间转发的次数超过了限制，通常是各节点的哈希环配置不一致导致循环转发
+var ErrTooManyHops = errors.New("too many hops between peers")
+
+// ErrTooManyInFlight 表示本节点发往其它节点的并发请求数已经达到 WithMaxInFlight 设置的上限
+var ErrTooManyInFlight = errors.New("too many in-flight peer requests")
+
+// HTTPStatus 返回 Get 等方法的错误对应的 HTTP 状态码，供在分组之上提供 HTTP 接口的 API 层使用：
+// ErrNotFound 对应 404，背压拒绝（ErrBackpressure）对应 503，等待超时对应 504，循环转发对应 508，其它错误对应 500，nil 对应 200
+// getter 需要在 key 不存在时返回 ErrNotFound（或用 %w 包装它的错误），Get 会原样返回，调用方才能区分“不存在”和“加载失败”
+func HTTPStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrBackpressure):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrWaitTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrTooManyHops):
+		return http.StatusLoopDetected
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+type hopsKey struct{}
+
+// withHops 返回携带请求已转发跳数的 ctx
+func withHops(ctx context.Context, hops int) context.Context {
+	return context.WithValue(ctx, hopsKey{}, hops)
+}
+
+// hopsFromContext 返回 ctx 中请求已转发的跳数，来自客户端的请求为 0
+func hopsFromContext(ctx context.Context) int {
+	hops, _ := ctx.Value(hopsKey{}).(int)
+	return hops
+}
+
 // httpGetter 实现 PeerGetter 接口，用于与客户端通信
 type httpGetter struct {
 	baseURL string
+
+	sem       chan struct{} // 同一个 HTTPPool 的所有 httpGetter 共享的信号量，nil 表示不限制并发数
+	inFlight  AtomicInt     // 正在进行的请求数
+	coalescer *coalescer    // 合并短时间内的单个 key 请求，nil 表示不合并
+
+	peerVersion AtomicInt // 最近一次响应中对方的协议版本，还没有收到过响应时为 0
+}
+
+// protocolVersion 解析 HTTP 头中的协议版本，没有携带时返回 1
+func protocolVersion(h http.Header) (int, error) {
+	v := h.Get(protocolHeader)
+	if v == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed version %q", ErrProtocolMismatch, v)
+	}
+	return n, nil
+}
+
+// checkProtocol 检查对方的协议版本是否与本节点兼容
+func checkProtocol(h http.Header) (int, error) {
+	v, err := protocolVersion(h)
+	if err != nil {
+		return 0, err
+	}
+	if v != ProtocolVersion {
+		return v, fmt.Errorf("%w: peer speaks version %d, this node speaks version %d", ErrProtocolMismatch, v, ProtocolVersion)
+	}
+	return v, nil
 }
 
 // HTTPPool 实现服务端与服务端之间的通信
@@ -30,18 +153,171 @@ type HTTPPool struct {
 	// 如 http://example.com/_groupcache/ 开头的请求就用于节点之间的通信，相当于一个路由标识
 	basePath string
 
-	mu    sync.Mutex
-	peers *consistenthash.Map // 每个节点持有整个哈希环上的真实和虚拟节点，用来根据 key 选择相应的节点
-	// 映射远程节点与对应的 httpGetter，每一个远程节点对应一个 httpGetter，因为 httpGetter 与远程节点的地址 baseURL 有关
-	// 这里就是持有每个节点与之对应的 http 请求地址
-	// 如：http://localhost:8001 -> http://localhost:8001/_groupcache/  http://localhost:8002 -> http://localhost:8002/_groupcache/
-	httpGetters map[string]*httpGetter
+	// 哈希环及每个节点对应的 httpGetter 以只读快照（*peerRing）的形式保存，调用 Set 之前为空
+	// 读取（PickPeer 等）无锁地取出当前快照；修改（Set、DisablePeer 等）在 mu 的保护下复制一份、修改副本后整体替换
+	mu   sync.Mutex
+	ring atomic.Value
+
+	overrides atomic.Value // 指定了节点的 key 到节点地址的映射（map[string]string），同样写时复制，在 mu 的保护下替换
+
+	maxPeers  int  // 哈希环上最多允许的节点数，0 表示不限制
+	singleHop bool // 为 true 时只处理自己负责的 key，不再转发给其它节点
+	maxHops   int  // 请求最多允许转发的次数，超过时拒绝处理
+
+	forwardHeaders []string // 从请求头提取到 ctx 元数据中的头部名称
+
+	duplicatePeers DuplicatePeerPolicy // Set 时遇到重复地址的处理方式
+
+	inFlight chan struct{} // 限制发往其它节点的并发请求数的信号量，nil 表示不限制
+
+	coalesceWindow time.Duration // 合并发往同一个节点的单个 key 请求的时间窗口，0 表示不合并
+
+	zones map[string]string // 节点地址到可用区的映射，Set 时应用到哈希环上
+
+	statsEndpoint bool // 为 true 时通过 <basepath>/_stats 提供 JSON 格式的统计信息
+
+	exportEndpoint bool // 为 true 时通过 <basepath>/_export 导出本节点的缓存内容
+
+	propagatePanics bool // 为 true 时 ServeHTTP 不捕获 panic，见 WithPanicPropagation
+
+	maxVirtualNodes int // 哈希环上最多允许的虚拟节点总数，0 表示不限制
+}
+
+// HTTPPoolOption 用于在创建节点时配置可选的行为
+type HTTPPoolOption func(*HTTPPool)
+
+// WithMaxPeers 限制 Set 时最多允许的节点数量，防止配置错误导致哈希环过大
+func WithMaxPeers(n int) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.maxPeers = n
+	}
+}
+
+// WithMaxVirtualNodes 限制 Set 时哈希环上的虚拟节点总数（节点数乘以虚拟节点倍数），超过时 SetE 返回错误，
+// 防止配置错误的节点列表分配出巨大的哈希环；默认为 consistenthash.DefaultMaxVirtualNodes，n 为 0 时不限制，为负数时 panic
+func WithMaxVirtualNodes(n int) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		if n < 0 {
+			panic("negative max virtual nodes")
+		}
+		p.maxVirtualNodes = n
+	}
+}
+
+// WithSingleHop 开启单跳模式：收到不属于自己的 key 时直接返回 421 Misdirected Request，
+// 并在 X-Groupcache-Owner 响应头中给出正确的节点，而不是再转发一次
+// 可以避免各节点哈希环配置不一致时出现多跳甚至循环转发
+func WithSingleHop() HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.singleHop = true
+	}
+}
+
+// WithMaxHops 设置请求在节点之间最多允许转发的次数，超过时返回 ErrTooManyHops，以打断循环转发
+func WithMaxHops(n int) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.maxHops = n
+	}
+}
+
+// WithDuplicatePeerPolicy 设置 Set 时遇到重复节点地址的处理方式，默认忽略重复的地址
+func WithDuplicatePeerPolicy(policy DuplicatePeerPolicy) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.duplicatePeers = policy
+	}
+}
+
+// WithForwardHeaders 设置需要从其它节点的请求中提取的 HTTP 头，它们会作为元数据放入 ctx 中，
+// 本节点的 GetterContext 可以用 MetadataFromContext 读取，本节点继续转发请求时也会带上
+// 没有配置的头不会被接收，避免任意请求头都能影响 getter 的行为
+func WithForwardHeaders(names ...string) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.forwardHeaders = append(p.forwardHeaders, names...)
+	}
 }
 
-func NewHTTPPool(self string) *HTTPPool {
-	return &HTTPPool{
+// WithMaxInFlight 限制本节点同时发往所有其它节点的请求数，防止突发的扇出请求耗尽文件描述符，0 表示不限制
+// 与 Transport 对每个节点的连接复用不同，它限制的是总的并发数；超过上限的请求不排队，立即以 ErrTooManyInFlight 失败，
+// 分组会像对待其它节点错误一样回退到备用集群或本地 getter
+func WithMaxInFlight(n int) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		if n < 0 {
+			panic("negative max in-flight requests")
+		}
+		p.inFlight = nil
+		if n > 0 {
+			p.inFlight = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithCoalesceWindow 开启请求合并：window 时间内发往同一个节点、同一个分组的 Get 请求合并成一个批量请求（_batch），
+// 对调用方透明，每个 key 仍然得到各自的值或错误。window 通常取 1ms 左右，每个请求最多因此多等待 window；
+// 只合并本节点发起的请求，转发自其它节点或带有元数据的请求不合并。默认不开启
+func WithCoalesceWindow(window time.Duration) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.coalesceWindow = window
+	}
+}
+
+// WithPeerZones 设置每个节点所在的可用区（节点地址 -> 可用区），Candidates 返回的候选节点会尽量来自不同的可用区，
+// 降低同一个可用区故障时 key 的所有候选节点同时不可用的风险；没有出现在 zones 中的节点不受约束
+func WithPeerZones(zones map[string]string) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.zones = make(map[string]string, len(zones))
+		for peer, zone := range zones {
+			p.zones[strings.TrimSuffix(peer, "/")] = zone
+		}
+	}
+}
+
+// WithStatsEndpoint 开启 GET <basepath>/_stats，以 JSON 返回本节点所有分组的统计信息和各远程节点的统计信息，
+// 不需要编写 Go 代码就可以采集监控数据；统计信息会暴露分组名和节点地址，默认关闭
+func WithStatsEndpoint() HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.statsEndpoint = true
+	}
+}
+
+// WithExportEndpoint 开启 GET <basepath>/_export，以 JSON Lines 导出本节点所有分组的缓存内容，供其它节点的 ImportFrom 拉取
+// 导出的内容包括所有缓存的 key 和值，能访问节点端口的任何人都可以读取，只应在受信任的网络中开启，默认关闭
+func WithExportEndpoint() HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.exportEndpoint = true
+	}
+}
+
+// WithPanicPropagation 让 ServeHTTP 中的 panic 继续向上抛出，交给调用方自己的恢复中间件处理（没有时由 net/http 中断连接）
+// 默认情况下 ServeHTTP 捕获处理请求时的任何 panic（包括 getter 中的），记录调用栈并返回 500，一个有问题的请求不会影响其它请求
+func WithPanicPropagation() HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.propagatePanics = true
+	}
+}
+
+func NewHTTPPool(self string, opts ...HTTPPoolOption) *HTTPPool {
+	p := &HTTPPool{
 		self:     self,
 		basePath: defaultBasePath,
+		maxHops:  defaultMaxHops,
+	}
+	p.maxVirtualNodes = consistenthash.DefaultMaxVirtualNodes
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Server 返回一个以当前节点为处理器、配置好超时时间的 *http.Server，addr 为监听地址（如 localhost:8001）
+// 推荐用它代替 http.ListenAndServe(addr, pool)，后者使用的默认 Server 没有任何超时限制
+func (p *HTTPPool) Server(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           p,
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+		ReadTimeout:       defaultReadTimeout,
+		WriteTimeout:      defaultWriteTimeout,
+		IdleTimeout:       defaultIdleTimeout,
 	}
 }
 
@@ -76,7 +352,15 @@ func NewHTTPPool(self string) *HTTPPool {
 // }
 
 // Get 在 httpGetter 上实现 PeerGetter 接口，用于从其它节点获取缓存值（使用 protobuf 通信）
-func (h *httpGetter) Get(in *testpb.Request, out *testpb.Response) error {
+func (h *httpGetter) Get(ctx context.Context, in *testpb.Request, out *testpb.Response) error {
+	if h.coalescer != nil && hopsFromContext(ctx) == 0 && len(MetadataFromContext(ctx)) == 0 && !isHedge(ctx) {
+		return h.coalescer.get(ctx, in, out)
+	}
+	if err := h.acquire(); err != nil {
+		return err
+	}
+	defer h.release()
+
 	// 向远程节点发起请求很简单，就是将节点上存储的远程节点请求地址拼上 /<groupname>/<key> 并发送 GET 请求即可
 	u := fmt.Sprintf(
 		"%v%v/%v",
@@ -87,12 +371,23 @@ func (h *httpGetter) Get(in *testpb.Request, out *testpb.Response) error {
 
 	// 每个节点在启动了都开启了自己 http 服务，即在前面 main.go 中 startCacheServer 方法里
 	// 发送 http 请求，就会进入到目标节点自己的 ServeHTTP 方法中
-	resp, err := http.Get(u)
+	resp, err := h.do(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusLoopDetected {
+		return ErrTooManyHops
+	}
+	if resp.StatusCode == http.StatusMisdirectedRequest {
+		return fmt.Errorf("server returned: %v, key is owned by %s", resp.Status, resp.Header.Get(ownerHeader))
+	}
+	if resp.StatusCode == http.StatusNotFound && resp.Header.Get(notFoundHeader) != "" {
+		// 所属节点确定 key 不存在，保留它给出的错误信息
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return &notFoundError{msg: strings.TrimSpace(string(msg))}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("server returned: %v", resp.Status)
 	}
@@ -112,56 +407,461 @@ func (h *httpGetter) Get(in *testpb.Request, out *testpb.Response) error {
 	return nil
 }
 
+// GetBatch 在 httpGetter 上实现 BatchPeerGetter 接口，通过 POST <basepath>/_batch 一次获取多个 key
+func (h *httpGetter) GetBatch(ctx context.Context, in *testpb.BatchRequest, out *testpb.BatchResponse) error {
+	if err := h.acquire(); err != nil {
+		return err
+	}
+	defer h.release()
+
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	resp, err := h.do(ctx, http.MethodPost, h.baseURL+batchPath, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusLoopDetected {
+		return ErrTooManyHops
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %v", err)
+	}
+	if err = proto.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding response body: %v", err)
+	}
+	return nil
+}
+
+// Increment 在 httpGetter 上实现 PeerIncrementer 接口，通过 POST <basepath>/_increment 给所属节点上的计数器加上 delta
+// 相加不是幂等的，遇到网络错误时不重试，直接返回错误，此时无法确定所属节点是否已经计入了 delta
+func (h *httpGetter) Increment(ctx context.Context, in *testpb.IncrementRequest, out *testpb.Response) error {
+	if err := h.acquire(); err != nil {
+		return err
+	}
+	defer h.release()
+
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	resp, err := h.send(ctx, http.MethodPost, h.baseURL+incrementPath, body, 0)
+	if err != nil {
+		return fmt.Errorf("increment may or may not have been applied: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %v", err)
+	}
+	if err = proto.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding response body: %v", err)
+	}
+	return nil
+}
+
+// RemovePrefix 在 httpGetter 上实现 PeerPrefixRemover 接口，通过 POST <basepath>/_remove_prefix 让节点删除 in.Key 开头的 key
+func (h *httpGetter) RemovePrefix(ctx context.Context, in *testpb.DeleteRequest) error {
+	if err := h.acquire(); err != nil {
+		return err
+	}
+	defer h.release()
+
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	resp, err := h.do(ctx, http.MethodPost, h.baseURL+prefixPath, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", resp.Status)
+	}
+	return nil
+}
+
+// do 发送带跳数的请求，遇到网络错误时按带随机抖动的退避时间重试
+// 只重试网络错误，对方返回了响应（即使是错误状态码）就不再重试
+func (h *httpGetter) do(ctx context.Context, method, u string, body []byte) (*http.Response, error) {
+	return h.send(ctx, method, u, body, peerRetries)
+}
+
+// send 与 do 相同，但最多重试 retries 次；不幂等的请求传入 0，网络错误可能发生在对方已经处理完请求之后，重试会重复执行
+func (h *httpGetter) send(ctx context.Context, method, u string, body []byte, retries int) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u, r)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/octet-stream")
+		}
+		req.Header.Set(hopsHeader, strconv.Itoa(hopsFromContext(ctx)+1))
+		req.Header.Set(protocolHeader, strconv.Itoa(ProtocolVersion))
+		if isHedge(ctx) {
+			req.Header.Set(hedgeHeader, "1")
+		}
+		if id := RequestIDFromContext(ctx); id != "" {
+			req.Header.Set(requestIDHeader, id)
+		}
+		for k, v := range MetadataFromContext(ctx) {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			// 版本不兼容时不解析响应，避免按错误的格式解读
+			v, verr := checkProtocol(resp.Header)
+			h.peerVersion.store(int64(v))
+			if verr != nil {
+				resp.Body.Close()
+				return nil, verr
+			}
+			return resp, nil
+		}
+		if attempt >= retries {
+			return resp, err
+		}
+
+		backoff := peerRetryBackoff << attempt
+		backoff += time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// Remove 在 httpGetter 上实现 PeerRemover 接口，通过 DELETE <basepath>/<group>/<key> 删除节点上的缓存值
+func (h *httpGetter) Remove(ctx context.Context, in *testpb.DeleteRequest) error {
+	if err := h.acquire(); err != nil {
+		return err
+	}
+	defer h.release()
+
+	u := fmt.Sprintf(
+		"%v%v/%v",
+		h.baseURL,
+		url.QueryEscape(in.GetGroup()),
+		url.QueryEscape(in.GetKey()),
+	)
+	resp, err := h.do(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", resp.Status)
+	}
+	return nil
+}
+
+// acquire 占用一个并发请求名额，名额用完时返回 ErrTooManyInFlight
+func (h *httpGetter) acquire() error {
+	if h.sem != nil {
+		select {
+		case h.sem <- struct{}{}:
+		default:
+			return ErrTooManyInFlight
+		}
+	}
+	h.inFlight.Add(1)
+	return nil
+}
+
+// release 归还 acquire 占用的名额
+func (h *httpGetter) release() {
+	h.inFlight.Add(-1)
+	if h.sem != nil {
+		<-h.sem
+	}
+}
+
+// inFlightRequests 返回正在进行的请求数
+func (h *httpGetter) inFlightRequests() int64 {
+	return h.inFlight.Get()
+}
+
+// String 返回节点的请求地址，用于统计信息中区分不同的节点
+func (h *httpGetter) String() string {
+	return h.baseURL
+}
+
 // 这种写法先为 PeerGetter 接口创建一个地址，但不分配内存，如果给字段赋值会报错
 // 在代码中判断 httpGetter 这个 struct 是否实现了 PeerGetter 接口，没有实现则报错
 var _ PeerGetter = (*httpGetter)(nil)
 
 // Set 在当前节点上存储其它节点的信息（加入哈希环），包括真实与虚拟节点、其它节点的请求地址
+// Set 是 SetE 的便捷版本，节点配置有误（地址不合法、超过 WithMaxPeers 或虚拟节点数上限等）时只打印日志并保留之前的哈希环，
+// 服务发现推送的一条错误配置不会让节点崩溃；需要知道配置是否生效时使用 SetE
 func (p *HTTPPool) Set(peers ...string) {
+	if err := p.SetE(peers...); err != nil {
+		p.Log("ignoring invalid peer set, keeping the previous ring: %v", err)
+	}
+}
+
+// SetE 与 Set 相同，但会先校验节点地址，配置有误时返回错误且不修改当前的哈希环
+// 每个节点地址都必须是带协议和主机的 URL（如 http://localhost:8001），重复的地址只保留一个
+func (p *HTTPPool) SetE(peers ...string) error {
+	peers, err := validatePeers(peers, p.maxPeers, p.duplicatePeers)
+	if err != nil {
+		return err
+	}
+
+	ring, err := newPeerRing(peers, p.basePath, p.maxVirtualNodes, getterOptions{sem: p.inFlight, coalesceWindow: p.coalesceWindow})
+	if err != nil {
+		return err
+	}
+	for peer, zone := range p.zones {
+		ring.peers.SetZone(peer, zone)
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.ring.Store(ring)
 
-	// 创建哈希环，默认创建 50 倍的虚拟节点
-	p.peers = consistenthash.New(defaultReplicas, nil)
-	// 将真实节点加入哈希环
-	p.peers.Add(peers...)
-	p.httpGetters = make(map[string]*httpGetter, len(peers))
+	return nil
+}
+
+// snapshot 返回当前哈希环的快照，调用 Set 之前返回 nil
+// 快照发布之后不会再被修改，同一次调用中对它的多次查询总是基于同一个哈希环
+func (p *HTTPPool) snapshot() *peerRing {
+	ring, _ := p.ring.Load().(*peerRing)
+	return ring
+}
 
-	// 存储所有其它节点的服务请求地址
-	// 如 http://localhost:8001 -> http://localhost:8001/_groupcache/
-	for _, peer := range peers {
-		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.basePath}
+// updateRing 复制当前的哈希环，用 fn 修改副本之后替换当前快照，调用 Set 之前什么也不做
+// httpGetter 在快照之间共享，它们的并发计数、合并窗口等状态不会因为替换而丢失
+func (p *HTTPPool) updateRing(fn func(peers *consistenthash.Map)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ring := p.snapshot()
+	if ring == nil {
+		return
 	}
+	next := &peerRing{peers: ring.peers.Clone(), httpGetters: ring.httpGetters}
+	fn(next.peers)
+	p.ring.Store(next)
 }
 
 // PickPeer 实现了 PeerPicker 接口，用于从哈希环中选择一个节点
 func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	ring := p.snapshot()
+	if ring == nil {
+		return nil, false
+	}
 
-	// 使用一致性哈希算法的查找，找出该 key 对应的真实节点
-	peer := p.peers.Get(key)
+	// 使用一致性哈希算法的查找（Override 指定的节点优先），找出该 key 对应的真实节点
+	peer, getter := p.route(ring, key)
 	if peer != "" && peer != p.self {
 		// 找到了目标远程节点且不是自身节点，返回该远程节点的请求地址，如 http://localhost:8002/_groupcache/
 		p.Log("Pick peer %s", peer)
-		return p.httpGetters[peer], true
+		return getter, true
 	}
 
 	return nil, false
 }
 
+// PickSecondary 实现了 HedgePeerPicker 接口，返回哈希环上 key 的所属节点之后的下一个节点
+// 用 Override 固定了节点的 key 没有第二选择；第二选择是本节点时返回 false
+func (p *HTTPPool) PickSecondary(key string) (PeerGetter, bool) {
+	ring := p.snapshot()
+	if ring == nil {
+		return nil, false
+	}
+	if m, _ := p.overrides.Load().(map[string]string); m[key] != "" {
+		return nil, false
+	}
+	_, secondary := ring.peers.GetTwo(key)
+	if secondary == "" || secondary == p.self {
+		return nil, false
+	}
+	return ring.httpGetters[secondary], true
+}
+
+// OwnerHealthy 返回 key 所属的节点以及它当前是否可用，key 属于本节点（或者还没有调用 Set）时返回本节点且总是可用
+// 所属节点是不考虑 DisablePeer 时哈希环上的节点，被 DisablePeer 摘除的节点视为不可用，
+// 此时 key 实际由哈希环上的下一个节点负责；客户端可以据此决定是否主动回退，而不是等请求超时
+func (p *HTTPPool) OwnerHealthy(key string) (owner string, healthy bool) {
+	ring := p.snapshot()
+	if ring == nil {
+		return p.self, true
+	}
+	owner, disabled := ring.peers.Owner(key)
+	return owner, !disabled
+}
+
+// DisablePeer 暂时把节点 peer 从路由中摘除（如健康检查发现它不可用），它负责的 key 会落到哈希环上的下一个节点
+// 与重新调用 Set 不同，虚拟节点保留在哈希环上，恢复时调用 EnablePeer 即可，代价很小；再次调用 Set 会清除禁用状态
+func (p *HTTPPool) DisablePeer(peer string) {
+	p.updateRing(func(peers *consistenthash.Map) {
+		peers.Disable(peer)
+	})
+}
+
+// EnablePeer 恢复被 DisablePeer 摘除的节点
+func (p *HTTPPool) EnablePeer(peer string) {
+	p.updateRing(func(peers *consistenthash.Map) {
+		peers.Enable(peer)
+	})
+}
+
+// OwnedKeys 从 keys 中筛选出哈希环上属于当前节点的 key，保持原来的顺序，适合只预热或失效本节点负责的 key
+// 所有 key 都按同一个哈希环快照判断，不会因为并发的 Set 而前后不一致；还没有调用 Set 时所有 key 都属于当前节点
+func (p *HTTPPool) OwnedKeys(keys []string) []string {
+	ring := p.snapshot()
+	owned := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if ring != nil {
+			if peer, _ := p.route(ring, key); peer != "" && peer != p.self {
+				continue
+			}
+		}
+		owned = append(owned, key)
+	}
+	return owned
+}
+
+// owner 返回哈希环上负责 key 的节点，调用 Set 之前返回空字符串
+func (p *HTTPPool) owner(key string) string {
+	ring := p.snapshot()
+	if ring == nil {
+		return ""
+	}
+	peer, _ := p.route(ring, key)
+	return peer
+}
+
+// Override 把 key 固定路由到节点 node，优先于哈希环，适合复现某个节点上的问题或让少数特殊的 key 固定在已知的节点上
+// node 不在当前的哈希环上时（包括调用 Set 之前）忽略这条记录，按哈希环路由；之后 Set 把 node 加入哈希环时记录重新生效
+// 记录在 Set 之后保留，直到调用 RemoveOverride；它只影响本节点的路由，其它节点需要设置相同的记录，否则会按哈希环转发
+func (p *HTTPPool) Override(key, node string) {
+	p.updateOverrides(func(m map[string]string) {
+		m[key] = strings.TrimSuffix(node, "/")
+	})
+}
+
+// RemoveOverride 删除 Override 为 key 设置的记录，key 恢复按哈希环路由
+func (p *HTTPPool) RemoveOverride(key string) {
+	p.updateOverrides(func(m map[string]string) {
+		delete(m, key)
+	})
+}
+
+// updateOverrides 复制当前的 Override 记录，用 fn 修改副本之后替换
+func (p *HTTPPool) updateOverrides(fn func(m map[string]string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old, _ := p.overrides.Load().(map[string]string)
+	m := make(map[string]string, len(old)+1)
+	for k, v := range old {
+		m[k] = v
+	}
+	fn(m)
+	p.overrides.Store(m)
+}
+
+// route 返回 key 所属的节点地址及对应的 httpGetter，Override 指定的节点在哈希环上时优先使用它
+func (p *HTTPPool) route(ring *peerRing, key string) (string, *httpGetter) {
+	if m, _ := p.overrides.Load().(map[string]string); len(m) > 0 {
+		if node, ok := m[key]; ok {
+			if getter, ok := ring.httpGetters[node]; ok {
+				return node, getter
+			}
+		}
+	}
+	return ring.pick(key)
+}
+
 func (p *HTTPPool) Log(format string, v ...any) {
 	log.Printf("[Server %s] %s", p.self, fmt.Sprintf(format, v...))
 }
 
+// ServeHTTP 处理节点之间的请求，支持的路由（消息格式见 testpb/testpb.proto）：
+//
+//	GET    <basepath>/<group>/<key>  获取缓存值，返回 Response
+//	DELETE <basepath>/<group>/<key>  删除本节点上缓存的值，返回空的 Response
+//	POST   <basepath>/_batch         请求体为 BatchRequest，返回 BatchResponse
+//	POST   <basepath>/_increment     请求体为 IncrementRequest，在本节点上给计数器加上 delta，返回 Response
+//	POST   <basepath>/_remove_prefix 请求体为 DeleteRequest（key 为前缀），删除本节点上以它开头的 key
+//	GET    <basepath>/_fingerprint   哈希环指纹，GET <basepath>/_candidates 候选节点
+//	GET    <basepath>/_export        导出缓存（需要 WithExportEndpoint 开启）
+//	GET    <basepath>/_stats         JSON 格式的统计信息（需要 WithStatsEndpoint 开启）
 func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.propagatePanics {
+		defer p.recoverPanic(w, r)
+	}
 	if !strings.HasPrefix(r.URL.Path, p.basePath) { // 前缀匹配不上
 		panic("HTTPPool serving unexpected path: " + r.URL.Path)
 	}
 
-	p.Log("%s %s", r.Method, r.URL.Path)
+	// 请求 ID 从请求方传来，没有时（请求不是来自其它节点）由本节点生成，之后经过 requestContext 传给分组和下一跳
+	id := r.Header.Get(requestIDHeader)
+	if id == "" {
+		id = newRequestID()
+	}
+	r = r.WithContext(ContextWithRequestID(r.Context(), id))
+	w.Header().Set(requestIDHeader, id)
+	p.Log("%s %s request_id=%s", r.Method, r.URL.Path, id)
+
+	// 版本不兼容的请求直接拒绝，不按本节点的格式解析；管理接口（如 _fingerprint）也要求版本兼容
+	w.Header().Set(protocolHeader, strconv.Itoa(ProtocolVersion))
+	if _, err := checkProtocol(r.Header); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.URL.Path[len(p.basePath):] {
+	case fingerprintPath:
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(p.Fingerprint()))
+		return
+	case candidatesPath:
+		p.serveCandidates(w, r)
+		return
+	case exportPath:
+		p.serveExport(w, r)
+		return
+	case statsPath:
+		p.serveStats(w, r)
+		return
+	case batchPath:
+		p.serveBatch(w, r)
+		return
+	case incrementPath:
+		p.serveIncrement(w, r)
+		return
+	case prefixPath:
+		p.serveRemovePrefix(w, r)
+		return
+	}
 
 	// 通讯形式：example.com/<basepath>/<groupname>/<key>
+	// GET 获取缓存值，DELETE 删除本节点上缓存的值
 	// 将 <groupname> 和 <key> 从路由中分离出来
 	parts := strings.SplitN(r.URL.Path[len(p.basePath):], "/", 2)
 	if len(parts) != 2 {
@@ -171,22 +871,59 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// 拿到分组名和 key，从缓存查找值
 	groupName, key := parts[0], parts[1]
+	// 路径以 / 结尾或包含连续的 / 时分组名或 key 为空，属于请求方的错误，不交给分组处理
+	if groupName == "" {
+		http.Error(w, "group is required", http.StatusBadRequest)
+		return
+	}
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
 	group := GetGroup(groupName)
 	if group == nil {
 		http.Error(w, "No such group: "+groupName, http.StatusNotFound)
 		return
 	}
 
+	// 删除成功时返回空的 Response（protobuf 编码后正好是空的 body）
+	if r.Method == http.MethodDelete {
+		group.Remove(key)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		return
+	}
+
+	// 单跳模式下，不属于自己的 key 直接告诉请求方正确的节点，避免形成下面的闭环；对冲请求本来就是发给第二选择节点的
+	if owner := p.misdirected(key); owner != "" && r.Header.Get(hedgeHeader) == "" {
+		w.Header().Set(ownerHeader, owner)
+		http.Error(w, "key is owned by "+owner, http.StatusMisdirectedRequest)
+		return
+	}
+
+	// 请求转发的次数超过限制时拒绝处理，打断下面可能形成的循环
+	hops, ok := p.checkHops(w, r)
+	if !ok {
+		return
+	}
+
 	// 接收到了来自其它节点的请求，与发来请求的节点一样，进入查找缓存值的流程
 	// 这里就形成了一个闭环
-	view, err := group.Get(key)
+	entry, err := group.getEntry(p.requestContext(r, hops), key)
+	if errors.Is(err, ErrTooManyHops) {
+		// 继续把错误传回上一个节点，让整条链路都以错误结束，而不是在中途回退到本地加载
+		http.Error(w, err.Error(), http.StatusLoopDetected)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, ErrNotFound) {
+			w.Header().Set(notFoundHeader, "1")
+		}
+		http.Error(w, err.Error(), HTTPStatus(err))
 		return
 	}
 
-	// 使用 protobuf 通信
-	body, err := proto.Marshal(&testpb.Response{Value: view.ByteSlice()})
+	// 使用 protobuf 通信，同时带上值的剩余存活时间，请求方据此设置本地副本的过期时间
+	body, err := proto.Marshal(entryResponse(entry))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -197,3 +934,390 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// w.Write(view.ByteSlice())
 	w.Write(body)
 }
+
+// recoverPanic 捕获处理请求时的 panic，记录调用栈并返回 500；响应已经开始写出时只能记录日志
+// http.ErrAbortHandler 是主动中断请求的约定，照常抛出
+func (p *HTTPPool) recoverPanic(w http.ResponseWriter, r *http.Request) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+	p.Log("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, v, debug.Stack())
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// entryResponse 将缓存值及其元数据转换成 protobuf 的 Response
+func entryResponse(entry Entry) *testpb.Response {
+	res := &testpb.Response{Value: entry.ByteSlice()}
+	if !entry.ExpiresAt.IsZero() {
+		// 向上取整到毫秒，避免还没过期的值因为舍入变成永不过期
+		ttl := time.Until(entry.ExpiresAt)
+		res.TtlMs = int64((ttl + time.Millisecond - 1) / time.Millisecond)
+		if res.TtlMs <= 0 {
+			res.TtlMs = 1
+		}
+	}
+	if !entry.LoadedAt.IsZero() {
+		res.Version = entry.LoadedAt.UnixNano()
+	}
+	// 值没有留在本节点的缓存中，请求方也不应该缓存它
+	if entry.StoredBytes == 0 {
+		res.NoStore = true
+	}
+	return res
+}
+
+// misdirected 在单跳模式下返回负责 key 的其它节点，key 属于自己或没有开启单跳模式时返回空字符串
+func (p *HTTPPool) misdirected(key string) string {
+	if !p.singleHop {
+		return ""
+	}
+	if owner := p.owner(key); owner != p.self {
+		return owner
+	}
+	return ""
+}
+
+// requestContext 返回处理请求时使用的 ctx，带上请求已经转发的跳数和配置过的请求头
+func (p *HTTPPool) requestContext(r *http.Request, hops int) context.Context {
+	ctx := withHops(r.Context(), hops)
+	if r.Header.Get(hedgeHeader) != "" {
+		ctx = withHedge(ctx)
+	}
+	md := make(map[string]string)
+	for _, name := range p.forwardHeaders {
+		if v := r.Header.Get(name); v != "" {
+			md[name] = v
+		}
+	}
+	if len(md) > 0 {
+		ctx = ContextWithMetadata(ctx, md)
+	}
+	return ctx
+}
+
+// checkHops 读取请求已经转发的跳数，超过限制时直接返回错误响应且 ok 为 false
+func (p *HTTPPool) checkHops(w http.ResponseWriter, r *http.Request) (hops int, ok bool) {
+	hops, _ = strconv.Atoi(r.Header.Get(hopsHeader))
+	if hops > p.maxHops {
+		http.Error(w, ErrTooManyHops.Error(), http.StatusLoopDetected)
+		return hops, false
+	}
+	return hops, true
+}
+
+// serveBatch 处理 POST <basepath>/_batch，请求体和响应体分别是 protobuf 编码的 BatchRequest 和 BatchResponse
+// 单个 key 获取失败不影响其它 key，错误信息放在对应 Response 的 error 字段中
+func (p *HTTPPool) serveBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	hops, ok := p.checkHops(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &testpb.BatchRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	group := GetGroup(req.GetGroup())
+	if group == nil {
+		http.Error(w, "No such group: "+req.GetGroup(), http.StatusNotFound)
+		return
+	}
+
+	ctx := p.requestContext(r, hops)
+	res := &testpb.BatchResponse{Responses: make([]*testpb.Response, len(req.GetKeys()))}
+	for i, k := range req.GetKeys() {
+		key := string(k)
+		if owner := p.misdirected(key); owner != "" {
+			res.Responses[i] = &testpb.Response{Error: "key is owned by " + owner}
+			continue
+		}
+		entry, err := group.getEntry(ctx, key)
+		if err != nil {
+			// 错误信息中可能带有 key 本身，替换掉不合法的 UTF-8，否则整个响应都无法编码
+			res.Responses[i] = &testpb.Response{
+				Error:    strings.ToValidUTF8(err.Error(), "\uFFFD"),
+				NotFound: errors.Is(err, ErrNotFound),
+			}
+			continue
+		}
+		res.Responses[i] = entryResponse(entry)
+	}
+
+	body, err := proto.Marshal(res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
+
+// serveIncrement 处理 POST <basepath>/_increment，请求体是 protobuf 编码的 IncrementRequest
+// 计数器总是在本节点上相加，不再转发，避免哈希环不一致时在节点之间来回转发
+func (p *HTTPPool) serveIncrement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &testpb.IncrementRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	group := GetGroup(req.GetGroup())
+	if group == nil {
+		http.Error(w, "No such group: "+req.GetGroup(), http.StatusNotFound)
+		return
+	}
+
+	key := group.normalize(req.GetKey())
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+	n, err := group.incrementLocally(key, req.GetDelta())
+	if err != nil {
+		http.Error(w, err.Error(), HTTPStatus(err))
+		return
+	}
+
+	body, err := proto.Marshal(&testpb.Response{Value: []byte(strconv.FormatInt(n, 10))})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
+
+// serveRemovePrefix 处理 POST <basepath>/_remove_prefix，请求体是 protobuf 编码的 DeleteRequest，其中的 key 是要删除的前缀
+func (p *HTTPPool) serveRemovePrefix(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &testpb.DeleteRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 空前缀会匹配所有 key，不允许通过节点间接口清空缓存
+	if req.GetKey() == "" {
+		http.Error(w, "prefix is required", http.StatusBadRequest)
+		return
+	}
+	group := GetGroup(req.GetGroup())
+	if group == nil {
+		http.Error(w, "No such group: "+req.GetGroup(), http.StatusNotFound)
+		return
+	}
+
+	n := group.RemovePrefix(req.GetKey())
+	p.Log("removed %d keys with prefix %q from group %s", n, req.GetKey(), req.GetGroup())
+}
+
+// Fingerprint 返回本节点哈希环成员的指纹（十六进制），还没有调用 Set 时返回空字符串
+func (p *HTTPPool) Fingerprint() string {
+	ring := p.snapshot()
+	if ring == nil {
+		return ""
+	}
+	return strconv.FormatUint(ring.peers.Fingerprint(), 16)
+}
+
+// RingMismatch 描述一个与本节点哈希环不一致或无法访问的节点
+type RingMismatch struct {
+	Peer        string // 节点地址
+	Fingerprint string // 该节点返回的指纹
+	Err         error  // 访问该节点失败时的错误
+}
+
+// CheckRing 向哈希环上的其它节点查询指纹并与本节点比较，返回所有不一致或无法访问的节点
+// 指纹不一致说明节点之间的成员配置不同，同一个 key 在不同节点上会被路由到不同的节点，造成缓存不一致
+// 每个节点的查询受 ctx 约束，没有响应的节点在 ctx 结束时报告为错误，不会一直阻塞
+func (p *HTTPPool) CheckRing(ctx context.Context) []RingMismatch {
+	ring := p.snapshot()
+	if ring == nil {
+		return nil
+	}
+
+	local := p.Fingerprint()
+	var mismatches []RingMismatch
+	for _, peer := range ring.peers.Members() {
+		if peer == p.self {
+			continue
+		}
+		fp, err := fetchFingerprint(ctx, ring.httpGetters[peer])
+		if err != nil || fp != local {
+			mismatches = append(mismatches, RingMismatch{Peer: peer, Fingerprint: fp, Err: err})
+		}
+	}
+
+	return mismatches
+}
+
+// fetchFingerprint 通过 h 查询对方节点的指纹，与其它节点间请求一样携带并检查协议版本
+func fetchFingerprint(ctx context.Context, h *httpGetter) (string, error) {
+	resp, err := h.do(ctx, http.MethodGet, h.baseURL+fingerprintPath, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("server returned: %v", resp.Status)
+	}
+
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading response body: %v", err)
+	}
+
+	return string(bytes), nil
+}
+
+// PeerProtocolVersions 返回哈希环上每个其它节点最近一次响应中的协议版本，用于排查滚动升级中的版本问题
+// 还没有收到过某个节点的响应时它的版本为 0；版本与 ProtocolVersion 不同的节点的请求都会以 ErrProtocolMismatch 失败
+func (p *HTTPPool) PeerProtocolVersions() map[string]int {
+	ring := p.snapshot()
+	if ring == nil {
+		return nil
+	}
+	versions := make(map[string]int, len(ring.httpGetters))
+	for peer, getter := range ring.httpGetters {
+		if peer != p.self {
+			versions[peer] = int(getter.peerVersion.Get())
+		}
+	}
+	return versions
+}
+
+// Peers 返回哈希环上除本节点之外的所有节点，按地址排序，调用 Set 之前返回 nil
+func (p *HTTPPool) Peers() []PeerGetter {
+	ring := p.snapshot()
+	if ring == nil {
+		return nil
+	}
+	var peers []PeerGetter
+	for _, peer := range ring.peers.Members() {
+		if peer != p.self {
+			peers = append(peers, ring.httpGetters[peer])
+		}
+	}
+	return peers
+}
+
+// CollisionReport 返回本节点哈希环的虚拟节点冲突诊断信息，调用 Set 之前返回零值
+func (p *HTTPPool) CollisionReport() consistenthash.CollisionReport {
+	ring := p.snapshot()
+	if ring == nil {
+		return consistenthash.CollisionReport{}
+	}
+	return ring.peers.CollisionReport()
+}
+
+// Candidates 返回 key 的候选节点地址列表，最多 n 个，第一个是 key 的所属节点，其余按哈希环顺时针排列
+// 配置了 WithPeerZones 时优先选择不同可用区的节点
+// 外部客户端可以按顺序直接访问这些节点，某个节点失败时重试下一个，而不需要经过服务端的二次转发
+func (p *HTTPPool) Candidates(key string, n int) []string {
+	ring := p.snapshot()
+	if ring == nil {
+		return nil
+	}
+	return ring.peers.GetN(key, n)
+}
+
+// NodeStats 是 <basepath>/_stats 返回的本节点统计信息
+type NodeStats struct {
+	Self        string                `json:"self"`
+	Fingerprint string                `json:"fingerprint"`
+	Groups      map[string]GroupStats `json:"groups"`
+}
+
+// GroupStats 是某个分组的统计信息，Peers 以节点名称为 key，见 Group.PeerStats
+type GroupStats struct {
+	Stats Stats                `json:"stats"`
+	Peers map[string]PeerStats `json:"peers"`
+}
+
+// NodeStats 汇总本节点所有分组的统计信息
+func (p *HTTPPool) NodeStats() NodeStats {
+	s := NodeStats{
+		Self:        p.self,
+		Fingerprint: p.Fingerprint(),
+		Groups:      make(map[string]GroupStats),
+	}
+	for _, g := range allGroups() {
+		s.Groups[g.name] = GroupStats{Stats: g.Stats(), Peers: g.PeerStats()}
+	}
+	return s
+}
+
+// serveStats 处理 GET <basepath>/_stats，没有开启时返回 404
+func (p *HTTPPool) serveStats(w http.ResponseWriter, r *http.Request) {
+	if !p.statsEndpoint {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.NodeStats())
+}
+
+// serveCandidates 处理 <basepath>/_candidates?key=<key>&n=<n>，以 JSON 数组返回候选节点
+func (p *HTTPPool) serveCandidates(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+	n := 1
+	if s := r.URL.Query().Get("n"); s != "" {
+		var err error
+		if n, err = strconv.Atoi(s); err != nil || n <= 0 {
+			http.Error(w, "invalid n: "+s, http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.Candidates(key, n))
+}
+
+// PreviewRemove 预览从哈希环中删除节点 peer 之后，keys 中每个 key 的新归属节点，不会修改当前的哈希环
+func (p *HTTPPool) PreviewRemove(peer string, keys []string) map[string]string {
+	ring := p.snapshot()
+	if ring == nil {
+		return nil
+	}
+	return ring.peers.PreviewRemove(peer, keys)
+}
@@ -1,10 +1,28 @@
 package mini_groupcache
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
 	"log"
+	"mini-groupcache/consistenthash"
+	"mini-groupcache/testpb"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestHTTPPool_ServeHTTP(t *testing.T) {
@@ -16,10 +34,1097 @@ func TestHTTPPool_ServeHTTP(t *testing.T) {
 		}
 		return nil, fmt.Errorf("%s is not exist", key)
 	}))
-	
-	addr := "localhost:9999"
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "http://" + l.Addr().String()
 	peers := NewHTTPPool(addr)
+	srv := peers.Server(l.Addr().String())
+	if srv.ReadTimeout == 0 || srv.WriteTimeout == 0 || srv.IdleTimeout == 0 {
+		t.Fatalf("server should have timeouts configured: %+v", srv)
+	}
 	log.Println("groupcache is running at ", addr)
-	// 将实现了 ServeHTTP 方法的接口体传给 http.ListenAndServe 以接管请求
-	log.Fatal(http.ListenAndServe(addr, peers))
+	go srv.Serve(l)
+	defer srv.Shutdown(context.Background())
+
+	resp, err := http.Get(addr + defaultBasePath + "scores/" + url.QueryEscape("张三"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	out := &testpb.Response{}
+	if err := proto.Unmarshal(body, out); err != nil || string(out.Value) != db["张三"] {
+		t.Fatalf("got %q, %v, want %q", out.Value, err, db["张三"])
+	}
+}
+
+// newTestPool 启动一个以 HTTPPool 作为处理器的测试节点，节点地址即测试服务器的地址
+func newTestPool(t *testing.T) (*HTTPPool, *httptest.Server) {
+	srv := httptest.NewUnstartedServer(nil)
+	pool := NewHTTPPool("http://" + srv.Listener.Addr().String())
+	srv.Config.Handler = pool
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return pool, srv
+}
+
+func TestHTTPPool_CheckRing(t *testing.T) {
+	a, srvA := newTestPool(t)
+	b, srvB := newTestPool(t)
+	c, srvC := newTestPool(t)
+
+	a.Set(srvA.URL, srvB.URL, srvC.URL)
+	b.Set(srvC.URL, srvB.URL, srvA.URL)
+	// c 的配置少了一个节点
+	c.Set(srvA.URL, srvC.URL)
+
+	mismatches := a.CheckRing(context.Background())
+	if len(mismatches) != 1 || mismatches[0].Peer != srvC.URL || mismatches[0].Err != nil {
+		t.Fatalf("CheckRing = %+v, want only %s mismatched", mismatches, srvC.URL)
+	}
+	if mismatches[0].Fingerprint != c.Fingerprint() {
+		t.Fatalf("got fingerprint %s, want %s", mismatches[0].Fingerprint, c.Fingerprint())
+	}
+
+	if mismatches := b.CheckRing(context.Background()); len(mismatches) != 1 {
+		t.Fatalf("CheckRing = %+v, want 1 mismatch", mismatches)
+	}
+
+	// 没有响应的节点在 ctx 结束时报告为错误
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hung.Close()
+	a.Set(srvA.URL, hung.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if mismatches := a.CheckRing(ctx); len(mismatches) != 1 || mismatches[0].Peer != hung.URL || mismatches[0].Err == nil {
+		t.Fatalf("CheckRing = %+v, want an error for the unresponsive peer", mismatches)
+	}
+}
+
+func TestHTTPPool_Candidates(t *testing.T) {
+	pool, srv := newTestPool(t)
+	pool.Set(srv.URL, "http://localhost:8002", "http://localhost:8003")
+
+	want := pool.Candidates("Tom", 2)
+	if len(want) != 2 || want[0] == want[1] {
+		t.Fatalf("Candidates = %v, want 2 distinct nodes", want)
+	}
+
+	resp, err := http.Get(srv.URL + defaultBasePath + candidatesPath + "?key=Tom&n=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got []string
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// 两个节点在同一个可用区时，候选节点总是跨可用区
+	zoned := NewHTTPPool("http://localhost:8001", WithPeerZones(map[string]string{
+		"http://localhost:8001": "a", "http://localhost:8002/": "a", "http://localhost:8003": "b",
+	}))
+	zoned.Set("http://localhost:8001", "http://localhost:8002", "http://localhost:8003")
+	for i := 0; i < 20; i++ {
+		nodes := zoned.Candidates(strconv.Itoa(i), 2)
+		if len(nodes) != 2 || (nodes[0] != "http://localhost:8003" && nodes[1] != "http://localhost:8003") {
+			t.Fatalf("Candidates = %v, want one node from each zone", nodes)
+		}
+	}
+}
+
+func TestHTTPPool_SetE(t *testing.T) {
+	testCases := []struct {
+		name  string
+		peers []string
+		ok    bool
+	}{
+		{"valid", []string{"http://localhost:8001", "http://localhost:8002"}, true},
+		{"missing scheme", []string{"localhost:8001"}, false},
+		{"missing host", []string{"http://"}, false},
+		{"malformed", []string{"http://local host:8001"}, false},
+		{"empty", []string{""}, false},
+		{"too many", []string{"http://a:1", "http://b:1", "http://c:1", "http://d:1"}, false},
+	}
+	for _, tc := range testCases {
+		pool := NewHTTPPool("http://localhost:8001", WithMaxPeers(3))
+		if err := pool.SetE(tc.peers...); (err == nil) != tc.ok {
+			t.Errorf("%s: SetE(%v) = %v", tc.name, tc.peers, err)
+		}
+	}
+
+	// 重复的地址只保留一个，不会超过节点数量的限制
+	pool := NewHTTPPool("http://localhost:8001", WithMaxPeers(2))
+	if err := pool.SetE("http://a:1", "http://b:1", "http://a:1", "http://b:1"); err != nil {
+		t.Fatal(err)
+	}
+	if members := pool.snapshot().peers.Members(); !reflect.DeepEqual(members, []string{"http://a:1", "http://b:1"}) {
+		t.Fatalf("members = %v, want duplicates removed", members)
+	}
+
+	// 校验失败时保持原来的哈希环不变
+	if err := pool.SetE("http://a:1", "bad"); err == nil || len(pool.snapshot().peers.Members()) != 2 {
+		t.Fatalf("SetE should fail and keep the ring unchanged, err = %v", err)
+	}
+	// Set 不会 panic，同样保持原来的哈希环
+	pool.Set("http://a:1", "http://b:1", "http://c:1")
+	if n := len(pool.snapshot().peers.Members()); n != 2 {
+		t.Fatalf("Set with too many peers changed the ring to %d members", n)
+	}
+
+	// 节点数乘以虚拟节点倍数超过上限时同样拒绝
+	pool = NewHTTPPool("http://localhost:8001", WithMaxVirtualNodes(2*defaultReplicas))
+	err := pool.SetE("http://a:1", "http://b:1", "http://c:1")
+	if !errors.Is(err, consistenthash.ErrTooManyVirtualNodes) || pool.snapshot() != nil {
+		t.Fatalf("SetE = %v, want ErrTooManyVirtualNodes without a ring", err)
+	}
+}
+
+func TestHTTPPool_ServeHTTPWithTTL(t *testing.T) {
+	NewGroup("http-ttl", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}), WithTTL(time.Minute))
+	_, srv := newTestPool(t)
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	res := &testpb.Response{}
+	if err := getter.Get(context.Background(), &testpb.Request{Group: "http-ttl", Key: "k"}, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.TtlMs <= 0 || res.TtlMs > time.Minute.Milliseconds() {
+		t.Fatalf("ttl_ms = %d, want the remaining ttl", res.TtlMs)
+	}
+}
+
+func TestHTTPPool_ImportFrom(t *testing.T) {
+	NewGroup("export-src", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value-" + key), nil
+	}))
+	for _, key := range []string{"a", "b", "c"} {
+		GetGroup("export-src").Get(key)
+	}
+	// 默认关闭
+	_, disabled := newTestPool(t)
+	resp, err := http.Get(disabled.URL + defaultBasePath + exportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404 when disabled", resp.StatusCode)
+	}
+
+	srv := httptest.NewServer(NewHTTPPool("http://localhost:8001", WithExportEndpoint()))
+	defer srv.Close()
+	resp, err = http.Post(srv.URL+defaultBasePath+exportPath, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status = %d, want 405", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + defaultBasePath + exportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	exported := make(map[string]string)
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var rec exportRecord
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Group == "export-src" {
+			exported[rec.Key] = string(rec.Value)
+		}
+	}
+	if want := map[string]string{"a": "value-a", "b": "value-b", "c": "value-c"}; !reflect.DeepEqual(exported, want) {
+		t.Fatalf("exported %v, want %v", exported, want)
+	}
+
+	// 导入方的分组容量只够放下两个条目，过期的条目和未知分组的条目会被跳过
+	loads := 0
+	dst := NewGroup("export-dst", 20, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("reloaded"), nil
+	}))
+	old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		enc.Encode(exportRecord{Group: "export-dst", Key: "k1", Value: []byte("value1")})
+		enc.Encode(exportRecord{Group: "export-dst", Key: "k2", Value: []byte("value2"), ExpiresAt: time.Now().Add(-time.Second)})
+		enc.Encode(exportRecord{Group: "unknown", Key: "k3", Value: []byte("value3")})
+		enc.Encode(exportRecord{Group: "export-dst", Key: "k4", Value: []byte("value4"), ExpiresAt: time.Now().Add(time.Minute)})
+		enc.Encode(exportRecord{Group: "export-dst", Key: "k5", Value: []byte("value5")})
+	}))
+	defer old.Close()
+
+	pool := NewHTTPPool("http://localhost:8001")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	n, err := pool.ImportFrom(ctx, old.URL)
+	if err != nil || n != 2 {
+		t.Fatalf("ImportFrom = %d, %v, want 2 records", n, err)
+	}
+	for _, key := range []string{"k1", "k4"} {
+		if v, err := dst.Get(key); err != nil || v.String() != "value"+key[1:] {
+			t.Fatalf("Get(%s) = %q, %v", key, v.String(), err)
+		}
+	}
+	if e, _ := dst.GetEntry("k4"); e.ExpiresAt.IsZero() {
+		t.Fatal("imported entry should keep its expiry")
+	}
+	if loads != 0 {
+		t.Fatalf("imported keys should not be reloaded, got %d loads", loads)
+	}
+
+	// 与其它节点间请求一样检查协议版本
+	newer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(protocolHeader, strconv.Itoa(ProtocolVersion+1))
+	}))
+	defer newer.Close()
+	if _, err := pool.ImportFrom(ctx, newer.URL); !errors.Is(err, ErrProtocolMismatch) {
+		t.Fatalf("ImportFrom error = %v, want ErrProtocolMismatch", err)
+	}
+}
+
+func TestHTTPPool_SingleHop(t *testing.T) {
+	NewGroup("single-hop", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}))
+
+	srv := httptest.NewUnstartedServer(nil)
+	self := "http://" + srv.Listener.Addr().String()
+	pool := NewHTTPPool(self, WithSingleHop())
+	srv.Config.Handler = pool
+	srv.Start()
+	defer srv.Close()
+
+	other := "http://localhost:1"
+	pool.Set(self, other)
+
+	var mine, theirs string
+	for i := 0; mine == "" || theirs == ""; i++ {
+		key := strconv.Itoa(i)
+		if pool.owner(key) == self {
+			mine = key
+		} else {
+			theirs = key
+		}
+	}
+
+	getter := &httpGetter{baseURL: self + defaultBasePath}
+	if err := getter.Get(context.Background(), &testpb.Request{Group: "single-hop", Key: mine}, &testpb.Response{}); err != nil {
+		t.Fatalf("key owned by self should be served, got %v", err)
+	}
+
+	resp, err := http.Get(self + defaultBasePath + "single-hop/" + theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMisdirectedRequest || resp.Header.Get(ownerHeader) != other {
+		t.Fatalf("got %v with owner %q, want 421 with owner %s", resp.Status, resp.Header.Get(ownerHeader), other)
+	}
+
+	// 对冲请求本来就是发给第二选择节点的，直接在本地加载
+	if err := getter.Get(withHedge(context.Background()), &testpb.Request{Group: "single-hop", Key: theirs}, &testpb.Response{}); err != nil {
+		t.Fatalf("hedged request should be served, got %v", err)
+	}
+}
+
+// loopPeer 模拟两个哈希环配置不一致的节点：每个节点都认为 key 属于对方，于是把请求转发回去
+// 每次转发都改写 key，避免同一进程内相同 key 的请求被 singleflight 合并而互相等待
+type loopPeer struct {
+	getter *httpGetter
+}
+
+func (p loopPeer) Get(ctx context.Context, in *testpb.Request, out *testpb.Response) error {
+	return p.getter.Get(ctx, &testpb.Request{Group: in.Group, Key: in.Key + "'"}, out)
+}
+
+func TestHTTPPool_MaxHops(t *testing.T) {
+	loads := 0
+	group := NewGroup("hop-loop", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("v"), nil
+	}))
+	_, srv := newTestPool(t)
+	group.RegisterPeers(stubPicker{loopPeer{&httpGetter{baseURL: srv.URL + defaultBasePath}}})
+
+	if _, err := group.Get("k"); !errors.Is(err, ErrTooManyHops) {
+		t.Fatalf("err = %v, want %v", err, ErrTooManyHops)
+	}
+	if loads != 0 {
+		t.Fatalf("looping request should not fall back to the getter, got %d loads", loads)
+	}
+}
+
+func TestHTTPPool_NotFound(t *testing.T) {
+	var loads int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		return nil, ErrNotFound
+	})
+	group := NewGroup("http-not-found", 2<<10, getter)
+	// 两个节点在同一个进程中，所属节点改用另一个分组名加载，避免与请求方共用分组的 singleflight 而互相等待
+	NewGroup("http-not-found-owner", 2<<10, getter)
+	a, srvA := newTestPool(t)
+	b := NewHTTPPool("http://localhost:8002")
+	srvB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, batchPath) {
+			data, _ := ioutil.ReadAll(r.Body)
+			req := &testpb.BatchRequest{}
+			proto.Unmarshal(data, req)
+			req.Group += "-owner"
+			data, _ = proto.Marshal(req)
+			r.Body = ioutil.NopCloser(bytes.NewReader(data))
+		} else {
+			r.URL.Path = strings.Replace(r.URL.Path, "/http-not-found/", "/http-not-found-owner/", 1)
+		}
+		b.ServeHTTP(w, r)
+	}))
+	defer srvB.Close()
+	a.Set(srvA.URL, srvB.URL)
+	group.RegisterPeers(a)
+
+	var keys []string
+	for i := 0; len(keys) < 3; i++ {
+		if a.owner(strconv.Itoa(i)) == srvB.URL {
+			keys = append(keys, strconv.Itoa(i))
+		}
+	}
+
+	// 所属节点确定 key 不存在，本节点直接返回，不再回退到 getter 重复查询
+	if _, err := group.Get(keys[0]); !errors.Is(err, ErrNotFound) || HTTPStatus(err) != http.StatusNotFound {
+		t.Fatalf("err = %v, want %v", err, ErrNotFound)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("getter called %d times, want 1", n)
+	}
+
+	// 批量请求中的单个 key 也一样
+	atomic.StoreInt32(&loads, 0)
+	_, errs := group.GetMulti(context.Background(), keys[1:2])
+	if !errors.Is(errs[keys[1]], ErrNotFound) {
+		t.Fatalf("GetMulti err = %v, want %v", errs[keys[1]], ErrNotFound)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("getter called %d times, want 1", n)
+	}
+
+	// 合并成批量请求的 Get
+	coalescing := NewHTTPPool("http://localhost:8003", WithCoalesceWindow(time.Millisecond))
+	coalescing.Set(srvB.URL)
+	peer, _ := coalescing.PickPeer(keys[2])
+	if err := peer.Get(context.Background(), &testpb.Request{Group: "http-not-found", Key: keys[2]}, &testpb.Response{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("coalesced err = %v, want %v", err, ErrNotFound)
+	}
+
+	// 节点上没有这个分组同样是 404，但不是 key 不存在
+	direct := &httpGetter{baseURL: srvB.URL + defaultBasePath}
+	if err := direct.Get(context.Background(), &testpb.Request{Group: "no-such-group", Key: "k"}, &testpb.Response{}); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing group err = %v, want a non-ErrNotFound error", err)
+	}
+}
+
+func TestHTTPPool_BatchAndDelete(t *testing.T) {
+	loads := 0
+	group := NewGroup("batch", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		if key == "bad" {
+			return nil, fmt.Errorf("bad key")
+		}
+		return []byte("value-" + key), nil
+	}))
+	_, srv := newTestPool(t)
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	res := &testpb.BatchResponse{}
+	err := getter.GetBatch(context.Background(), &testpb.BatchRequest{Group: "batch", Keys: batchKeys([]string{"a", "bad", "b"})}, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := res.GetResponses()
+	if len(rs) != 3 || string(rs[0].Value) != "value-a" || rs[1].Error == "" || string(rs[2].Value) != "value-b" {
+		t.Fatalf("unexpected batch response: %v", res)
+	}
+	if rs[0].Version == 0 {
+		t.Fatal("version should be set from the load time")
+	}
+
+	// 删除之后再次访问会重新加载
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+defaultBasePath+"batch/a", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE = %v, %v", resp, err)
+	}
+	resp.Body.Close()
+	loads = 0
+	group.Get("a")
+	if loads != 1 {
+		t.Fatalf("removed key should be reloaded, got %d loads", loads)
+	}
+}
+
+func TestHTTPPool_ForwardHeaders(t *testing.T) {
+	NewGroupContext("forward-headers", 2<<10, GetterContextFunc(func(ctx context.Context, key string) ([]byte, error) {
+		md := MetadataFromContext(ctx)
+		return []byte(md["X-Tenant"] + "/" + md["X-Secret"]), nil
+	}))
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config.Handler = NewHTTPPool("http://"+srv.Listener.Addr().String(), WithForwardHeaders("X-Tenant"))
+	srv.Start()
+	defer srv.Close()
+
+	// 只有配置过的头会被提取出来交给 getter
+	ctx := ContextWithMetadata(context.Background(), map[string]string{"X-Tenant": "acme", "X-Secret": "s"})
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	res := &testpb.Response{}
+	if err := getter.Get(ctx, &testpb.Request{Group: "forward-headers", Key: "k"}, res); err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != "acme/" {
+		t.Fatalf("getter saw %q, want only the configured header", res.Value)
+	}
+}
+
+func TestHTTPPool_RequestID(t *testing.T) {
+	NewGroupContext("request-id", 2<<10, GetterContextFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return []byte(RequestIDFromContext(ctx)), nil
+	}))
+	_, srv := newTestPool(t)
+
+	// 请求方的请求 ID 传到对方节点的 getter
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	res := &testpb.Response{}
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	if err := getter.Get(ctx, &testpb.Request{Group: "request-id", Key: "a"}, res); err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != "req-1" {
+		t.Fatalf("getter saw request id %q, want req-1", res.Value)
+	}
+
+	// 没有请求 ID 时由收到请求的节点生成，并在响应头中返回
+	resp, err := http.Get(srv.URL + defaultBasePath + "request-id/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if id := resp.Header.Get(requestIDHeader); len(id) != 16 {
+		t.Fatalf("generated request id = %q", id)
+	}
+}
+
+func TestHTTPPool_RemovePrefix(t *testing.T) {
+	group := NewGroup("http-remove-prefix", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}))
+	_, srv := newTestPool(t)
+	for _, key := range []string{"a:1", "a:2", "b:1"} {
+		group.Get(key)
+	}
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	if err := getter.RemovePrefix(context.Background(), &testpb.DeleteRequest{Group: "http-remove-prefix", Key: "a:"}); err != nil {
+		t.Fatal(err)
+	}
+	if keys := group.KeysWithPrefix(""); !reflect.DeepEqual(keys, []string{"b:1"}) {
+		t.Fatalf("keys = %v, want only b:1", keys)
+	}
+	if err := getter.RemovePrefix(context.Background(), &testpb.DeleteRequest{Group: "missing", Key: "a:"}); err == nil {
+		t.Fatal("removing from an unknown group should fail")
+	}
+	// 不允许通过空前缀远程清空缓存
+	if err := getter.RemovePrefix(context.Background(), &testpb.DeleteRequest{Group: "http-remove-prefix"}); err == nil {
+		t.Fatal("an empty prefix should be rejected")
+	}
+	if keys := group.KeysWithPrefix(""); len(keys) != 1 {
+		t.Fatalf("keys = %v after an empty prefix, want b:1 kept", keys)
+	}
+}
+
+func TestHTTPPool_DuplicatePeers(t *testing.T) {
+	pool := NewHTTPPool("http://localhost:8001")
+	if err := pool.SetE("http://a:1", "http://b:1", "http://a:1/", "http://a:1"); err != nil {
+		t.Fatal(err)
+	}
+	// 每个节点只有一组虚拟节点，不会因为重复出现而占据更多的哈希环
+	if members := pool.snapshot().peers.Members(); !reflect.DeepEqual(members, []string{"http://a:1", "http://b:1"}) {
+		t.Fatalf("members = %v", members)
+	}
+	if n := len(pool.Candidates("k", 10)); n != 2 {
+		t.Fatalf("got %d candidates, want 2", n)
+	}
+	var a int
+	for i := 0; i < 1000; i++ {
+		if p, _ := pool.snapshot().pick(strconv.Itoa(i)); p == "http://a:1" {
+			a++
+		}
+	}
+	single := NewHTTPPool("http://localhost:8001")
+	single.Set("http://a:1", "http://b:1")
+	var want int
+	for i := 0; i < 1000; i++ {
+		if p, _ := single.snapshot().pick(strconv.Itoa(i)); p == "http://a:1" {
+			want++
+		}
+	}
+	if a != want {
+		t.Fatalf("http://a:1 owns %d keys, want %d as if it were added once", a, want)
+	}
+
+	strict := NewHTTPPool("http://localhost:8001", WithDuplicatePeerPolicy(DuplicatePeersError))
+	if err := strict.SetE("http://a:1", "http://a:1/"); err == nil {
+		t.Fatal("duplicate peers should be rejected")
+	}
+}
+
+func TestHTTPPool_OwnedKeys(t *testing.T) {
+	self := "http://localhost:8001"
+	pool := NewHTTPPool(self)
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	if owned := pool.OwnedKeys(keys); len(owned) != len(keys) {
+		t.Fatalf("all keys should be owned before Set, got %d", len(owned))
+	}
+
+	pool.Set(self, "http://localhost:8002", "http://localhost:8003")
+	owned := pool.OwnedKeys(keys)
+	if len(owned) == 0 || len(owned) == len(keys) {
+		t.Fatalf("got %d owned keys, want a share of them", len(owned))
+	}
+	for _, key := range owned {
+		if pool.owner(key) != self {
+			t.Fatalf("key %s is owned by %s", key, pool.owner(key))
+		}
+	}
+}
+
+func TestHTTPPool_MaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		body, _ := proto.Marshal(&testpb.Response{Value: []byte("remote")})
+		w.Write(body)
+	}))
+	defer slow.Close()
+
+	pool := NewHTTPPool("http://localhost:8001", WithMaxInFlight(1))
+	pool.Set(slow.URL)
+	group := NewGroup("max-in-flight", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	}))
+	group.RegisterPeers(pool)
+
+	done := make(chan ByteView)
+	go func() {
+		v, _ := group.Get("a")
+		done <- v
+	}()
+	name := slow.URL + defaultBasePath
+	for group.PeerStats()[name].InFlight != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 名额用完时立即失败，并回退到本地 getter
+	peer, _ := pool.PickPeer("b")
+	if err := peer.Get(context.Background(), &testpb.Request{Group: "max-in-flight", Key: "b"}, &testpb.Response{}); !errors.Is(err, ErrTooManyInFlight) {
+		t.Fatalf("err = %v, want %v", err, ErrTooManyInFlight)
+	}
+	if v, err := group.Get("b"); err != nil || v.String() != "local" {
+		t.Fatalf("Get(b) = %q, %v", v, err)
+	}
+
+	close(release)
+	if v := <-done; v.String() != "remote" {
+		t.Fatalf("Get(a) = %q, want remote", v)
+	}
+	if ps := group.PeerStats()[name]; ps.InFlight != 0 || ps.Errors != 1 {
+		t.Fatalf("PeerStats = %+v", ps)
+	}
+}
+
+func TestHTTPPool_CoalesceWindow(t *testing.T) {
+	NewGroup("coalesce", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if key == "bad" {
+			return nil, fmt.Errorf("bad key")
+		}
+		return []byte("value-" + key), nil
+	}))
+	var mu sync.Mutex
+	var paths []string
+	server := NewHTTPPool("http://localhost:8001")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		server.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	pool := NewHTTPPool("http://localhost:8002", WithCoalesceWindow(20*time.Millisecond))
+	pool.Set(srv.URL)
+	peer, _ := pool.PickPeer("k")
+
+	keys := []string{"a", "bad", "b"}
+	values := make([]string, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			out := &testpb.Response{}
+			errs[i] = peer.Get(context.Background(), &testpb.Request{Group: "coalesce", Key: key}, out)
+			values[i] = string(out.Value)
+		}(i, key)
+	}
+	wg.Wait()
+
+	// 三个请求合并成一个批量请求，每个 key 仍然得到各自的结果
+	if len(paths) != 1 || paths[0] != defaultBasePath+batchPath {
+		t.Fatalf("requests = %v, want a single batch request", paths)
+	}
+	if values[0] != "value-a" || errs[0] != nil || values[2] != "value-b" || errs[2] != nil {
+		t.Fatalf("values = %q, errs = %v", values, errs)
+	}
+	if errs[1] == nil {
+		t.Fatal("the bad key should fail on its own")
+	}
+}
+
+func TestGroup_RemoveAndVerify(t *testing.T) {
+	group := NewGroup("remove-verify", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}))
+	var deleted []string
+	server := NewHTTPPool("http://localhost:8001")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	pool := NewHTTPPool("http://localhost:8002")
+	pool.Set(srv.URL)
+	group.RegisterPeers(pool)
+
+	lag, err := group.RemoveAndVerify(context.Background(), "k")
+	if err != nil || lag <= 0 {
+		t.Fatalf("RemoveAndVerify = %v, %v", lag, err)
+	}
+	if len(deleted) != 1 || deleted[0] != defaultBasePath+"remove-verify/k" {
+		t.Fatalf("owner received deletes %v", deleted)
+	}
+
+	// 所属节点无法访问时返回错误
+	srv.Close()
+	if _, err := group.RemoveAndVerify(context.Background(), "k"); err == nil {
+		t.Fatal("remove should fail when the owner is unreachable")
+	}
+	if s := group.Stats(); s.PendingRemoves != 0 {
+		t.Fatalf("PendingRemoves = %d, want 0", s.PendingRemoves)
+	}
+}
+
+func TestHTTPPool_EmptyPathSegments(t *testing.T) {
+	NewGroup("empty-segments", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}))
+	_, srv := newTestPool(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{"trailing slash", "empty-segments/", http.StatusBadRequest, "key is required"},
+		{"double slash", "/k", http.StatusBadRequest, "group is required"},
+		{"only slashes", "//", http.StatusBadRequest, "group is required"},
+		{"no key segment", "empty-segments", http.StatusBadRequest, "Bad Request"},
+		{"valid", "empty-segments/k", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + defaultBasePath + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.body != "" && strings.TrimSpace(string(body)) != tt.body {
+				t.Fatalf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestHTTPPool_StatsEndpoint(t *testing.T) {
+	group := NewGroup("stats-endpoint", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}))
+	group.Get("a")
+	group.Get("a")
+
+	// 默认关闭
+	_, srv := newTestPool(t)
+	resp, err := http.Get(srv.URL + defaultBasePath + statsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404 when disabled", resp.StatusCode)
+	}
+
+	enabled := httptest.NewServer(NewHTTPPool("http://localhost:8001", WithStatsEndpoint()))
+	defer enabled.Close()
+	resp, err = http.Get(enabled.URL + defaultBasePath + statsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got NodeStats
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	gs, ok := got.Groups["stats-endpoint"]
+	if got.Self != "http://localhost:8001" || !ok || gs.Stats.Gets != 2 || gs.Stats.CacheHits != 1 || gs.Stats.Bytes == 0 {
+		t.Fatalf("unexpected stats: %+v", got)
+	}
+}
+
+func TestHTTPPool_OwnerHealthy(t *testing.T) {
+	self := "http://localhost:8001"
+	pool := NewHTTPPool(self)
+	if owner, healthy := pool.OwnerHealthy("k"); owner != self || !healthy {
+		t.Fatalf("OwnerHealthy before Set = %s, %v", owner, healthy)
+	}
+
+	other := "http://localhost:8002"
+	pool.Set(self, other)
+	var key string
+	for i := 0; key == ""; i++ {
+		if pool.owner(strconv.Itoa(i)) == other {
+			key = strconv.Itoa(i)
+		}
+	}
+	if owner, healthy := pool.OwnerHealthy(key); owner != other || !healthy {
+		t.Fatalf("OwnerHealthy = %s, %v, want healthy %s", owner, healthy, other)
+	}
+
+	pool.DisablePeer(other)
+	if owner, healthy := pool.OwnerHealthy(key); owner != other || healthy {
+		t.Fatalf("OwnerHealthy = %s, %v, want unhealthy %s", owner, healthy, other)
+	}
+}
+
+func TestGroup_StrandedKeysHTTPPool(t *testing.T) {
+	self := "http://localhost:8001"
+	pool := NewHTTPPool(self)
+	group := NewGroup("stranded-http", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	group.RegisterPeers(pool)
+	for i := 0; i < 100; i++ {
+		group.Get(strconv.Itoa(i))
+	}
+	if keys := group.StrandedKeys(); len(keys) != 0 {
+		t.Fatalf("got %d stranded keys before the ring changed", len(keys))
+	}
+
+	pool.Set(self, "http://localhost:8002", "http://localhost:8003")
+	stranded := group.StrandedKeys()
+	if len(stranded) == 0 || len(stranded) == 100 {
+		t.Fatalf("got %d stranded keys, want a share of them", len(stranded))
+	}
+	for _, key := range stranded {
+		if pool.owner(key) == self {
+			t.Fatalf("key %s is still owned by this node", key)
+		}
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusOK},
+		{fmt.Errorf("k not exist: %w", ErrNotFound), http.StatusNotFound},
+		{ErrRateLimited, http.StatusServiceUnavailable},
+		{ErrWaitTimeout, http.StatusGatewayTimeout},
+		{ErrTooManyHops, http.StatusLoopDetected},
+		{fmt.Errorf("db is down"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := HTTPStatus(tt.err); got != tt.want {
+			t.Errorf("HTTPStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+
+	// 节点之间的请求同样按 HTTPStatus 映射，不存在的 key 返回 404
+	NewGroup("status-not-found", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+	}))
+	_, srv := newTestPool(t)
+	resp, err := http.Get(srv.URL + defaultBasePath + "status-not-found/k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+// BenchmarkHTTPPool_PickPeer 测量并发读取哈希环的吞吐量，期间有一个 goroutine 不断切换节点的禁用状态
+func BenchmarkHTTPPool_PickPeer(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	self := "http://localhost:8001"
+	pool := NewHTTPPool(self)
+	peers := []string{self}
+	for i := 2; i <= 16; i++ {
+		peers = append(peers, fmt.Sprintf("http://localhost:80%02d", i))
+	}
+	pool.Set(peers...)
+	// 只使用属于本节点的 key，排除 PickPeer 为远程节点打印日志的开销，只测量读取哈希环本身
+	keys := make([]string, 16<<10)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	keys = pool.OwnedKeys(keys)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				pool.DisablePeer(peers[1])
+				pool.EnablePeer(peers[1])
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			pool.PickPeer(keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
+func TestHTTPPool_Override(t *testing.T) {
+	self := "http://localhost:8001"
+	pool := NewHTTPPool(self)
+	pool.Set(self, "http://localhost:8002", "http://localhost:8003")
+
+	// 找一个属于本节点的 key，把它固定到 8003 上
+	var key string
+	for i := 0; key == ""; i++ {
+		if pool.owner(strconv.Itoa(i)) == self {
+			key = strconv.Itoa(i)
+		}
+	}
+	pool.Override(key, "http://localhost:8003/")
+	peer, ok := pool.PickPeer(key)
+	if !ok || peer.(*httpGetter).baseURL != "http://localhost:8003"+defaultBasePath {
+		t.Fatalf("PickPeer(%s) = %v, %v, want the overridden node", key, peer, ok)
+	}
+	if owned := pool.OwnedKeys([]string{key}); len(owned) != 0 {
+		t.Fatalf("overridden key should not be owned by this node")
+	}
+
+	// 节点不在哈希环上时忽略记录，重新加入后记录恢复生效
+	pool.Set(self, "http://localhost:8002")
+	if pool.owner(key) != self {
+		t.Fatalf("owner = %s, want %s", pool.owner(key), self)
+	}
+	pool.Set(self, "http://localhost:8002", "http://localhost:8003")
+	if pool.owner(key) != "http://localhost:8003" {
+		t.Fatalf("override should survive Set, owner = %s", pool.owner(key))
+	}
+
+	pool.RemoveOverride(key)
+	if _, ok := pool.PickPeer(key); ok {
+		t.Fatal("key should route back to this node after RemoveOverride")
+	}
+}
+
+func TestHTTPPool_ProtocolVersion(t *testing.T) {
+	NewGroup("protocol", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	pool, srv := newTestPool(t)
+	_, peerSrv := newTestPool(t)
+	pool.Set(srv.URL, peerSrv.URL)
+
+	// 版本不同的请求直接被拒绝，响应中带有本节点的版本
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+defaultBasePath+"protocol/k", nil)
+	req.Header.Set(protocolHeader, "2")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get(protocolHeader) != strconv.Itoa(ProtocolVersion) {
+		t.Fatalf("status = %d, version = %q", resp.StatusCode, resp.Header.Get(protocolHeader))
+	}
+
+	// 同版本的节点之间正常通信，并记录对方的版本
+	getter := pool.snapshot().httpGetters[peerSrv.URL]
+	if err := getter.Get(context.Background(), &testpb.Request{Group: "protocol", Key: "k"}, &testpb.Response{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := pool.PeerProtocolVersions()[peerSrv.URL]; v != ProtocolVersion {
+		t.Fatalf("peer version = %d, want %d", v, ProtocolVersion)
+	}
+
+	// 对方的版本不同时不解析响应
+	newer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(protocolHeader, "2")
+		w.Write([]byte("v2 payload"))
+	}))
+	defer newer.Close()
+	getter = &httpGetter{baseURL: newer.URL + defaultBasePath}
+	err = getter.Get(context.Background(), &testpb.Request{Group: "protocol", Key: "k"}, &testpb.Response{})
+	if !errors.Is(err, ErrProtocolMismatch) {
+		t.Fatalf("err = %v, want ErrProtocolMismatch", err)
+	}
+	if getter.peerVersion.Get() != 2 {
+		t.Fatalf("peer version = %d, want 2", getter.peerVersion.Get())
+	}
+}
+
+func TestHTTPPool_Increment(t *testing.T) {
+	group := NewGroup("http-increment", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}))
+	a, srvA := newTestPool(t)
+	_, srvB := newTestPool(t)
+	a.Set(srvA.URL, srvB.URL)
+	group.RegisterPeers(a)
+
+	var key string
+	for i := 0; key == ""; i++ {
+		if a.owner(strconv.Itoa(i)) == srvB.URL {
+			key = strconv.Itoa(i)
+		}
+	}
+	// 计数发生在所属节点上
+	for i := int64(1); i <= 3; i++ {
+		if n, err := group.Increment(key, 10); err != nil || n != 10*i {
+			t.Fatalf("Increment = %d, %v, want %d", n, err, 10*i)
+		}
+	}
+	res := &testpb.Response{}
+	if err := a.snapshot().httpGetters[srvB.URL].Get(context.Background(), &testpb.Request{Group: "http-increment", Key: key}, res); err != nil || string(res.Value) != "30" {
+		t.Fatalf("owner value = %q, %v, want 30", res.Value, err)
+	}
+
+	// 连接在请求到达之后断开，不重试，避免重复计入
+	var attempts int32
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer broken.Close()
+	getter := &httpGetter{baseURL: broken.URL + defaultBasePath}
+	if err := getter.Increment(context.Background(), &testpb.IncrementRequest{Group: "http-increment", Key: key, Delta: 1}, res); err == nil {
+		t.Fatal("Increment should return the transport error")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Fatalf("got %d attempts, want 1", n)
+	}
+}
+
+func TestHTTPPool_RecoverPanic(t *testing.T) {
+	NewGroup("http-panic", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if key == "boom" {
+			panic("buggy getter")
+		}
+		return []byte(key), nil
+	}))
+	_, srv := newTestPool(t)
+
+	for key, want := range map[string]int{"boom": http.StatusInternalServerError, "ok": http.StatusOK} {
+		resp, err := http.Get(srv.URL + defaultBasePath + "http-panic/" + key)
+		if err != nil {
+			t.Fatalf("server should stay up after a panic: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("GET %s = %d, want %d", key, resp.StatusCode, want)
+		}
+	}
+
+	// 配置为继续抛出时由调用方处理
+	pool := NewHTTPPool("http://localhost:8001", WithPanicPropagation())
+	defer func() {
+		if recover() == nil {
+			t.Fatal("panic should propagate")
+		}
+	}()
+	pool.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unexpected", nil))
+}
+
+func TestHTTPPool_BatchKeyEncoding(t *testing.T) {
+	NewGroup("batch-keys", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if strings.HasPrefix(key, "bad") {
+			// 错误信息中带着不合法的 UTF-8
+			return nil, fmt.Errorf("%s failed", key)
+		}
+		return []byte(key), nil
+	}))
+	_, srv := newTestPool(t)
+
+	// 换行、斜杠、逗号、竖线以及不合法的 UTF-8 都原样往返
+	keys := []string{"a\nb", "x/y/", "a,b", "k|v", "\xff\xfe", "bad\xff"}
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	res := &testpb.BatchResponse{}
+	if err := getter.GetBatch(context.Background(), &testpb.BatchRequest{Group: "batch-keys", Keys: batchKeys(keys)}, res); err != nil {
+		t.Fatal(err)
+	}
+	rs := res.GetResponses()
+	if len(rs) != len(keys) {
+		t.Fatalf("got %d responses for %d keys", len(rs), len(keys))
+	}
+	for i, key := range keys[:len(keys)-1] {
+		if string(rs[i].Value) != key {
+			t.Errorf("value for %q = %q", key, rs[i].Value)
+		}
+	}
+	if rs[len(keys)-1].Error == "" {
+		t.Error("the failed key should carry its error")
+	}
 }
@@ -0,0 +1,38 @@
+package mini_groupcache
+
+import "context"
+
+type loaderKey struct{}
+
+// GetWithLoader 与 Get 相同，但缓存没命中时用 loader 代替分组的 getter 加载 key，加载出的值照常存入 mainCache
+// loader 只在本节点上调用：不会把请求转发给所属节点或备用集群，值也只缓存在本节点上
+// 同一个 key 的并发加载仍然经过 singleflight 合并，只有发起加载的那个调用方的 loader 会被调用，
+// 其它调用方（无论传入的 loader 是否相同，或者是普通的 Get）都等待并共享它的结果；
+// 反过来，key 已经有普通 Get 正在加载时，GetWithLoader 也会直接等待那次加载，loader 不会被调用
+func (g *Group) GetWithLoader(key string, loader func() ([]byte, error)) (ByteView, error) {
+	return g.GetWithLoaderContext(context.Background(), key, loader)
+}
+
+// GetWithLoaderContext 与 GetWithLoader 相同，ctx 的用法与 GetContext 一致
+func (g *Group) GetWithLoaderContext(ctx context.Context, key string, loader func() ([]byte, error)) (ByteView, error) {
+	if loader == nil {
+		panic("nil loader")
+	}
+	return g.GetContext(context.WithValue(ctx, loaderKey{}, loader), key)
+}
+
+// loaderFromContext 返回 GetWithLoader 传入的 loader，没有时返回 nil
+func loaderFromContext(ctx context.Context) func() ([]byte, error) {
+	loader, _ := ctx.Value(loaderKey{}).(func() ([]byte, error))
+	return loader
+}
+
+// getterFor 返回加载 key 时实际使用的 getter：ctx 带有 GetWithLoader 传入的 loader 时用它，否则用分组的 getter
+func (g *Group) getterFor(ctx context.Context) GetterContext {
+	if loader := loaderFromContext(ctx); loader != nil {
+		return GetterContextFunc(func(context.Context, string) ([]byte, error) {
+			return loader()
+		})
+	}
+	return g.getter
+}
@@ -1,6 +1,55 @@
 package lru
 
-import "container/list"
+import (
+	"container/heap"
+	"container/list"
+	"errors"
+	"math/bits"
+	"time"
+	"unsafe"
+)
+
+// ErrCacheFull 表示即使淘汰所有未固定的条目也放不下新的值，见 TryAddWithExpire
+var ErrCacheFull = errors.New("lru: cache is full of pinned entries")
+
+// EvictionReason 表示条目离开缓存的原因，见 Cache.OnEvictedReason
+type EvictionReason int
+
+const (
+	CapacityEvicted EvictionReason = iota // 容量不足，被 RemoveOldest 淘汰
+	Expired                               // 已经过期，在 Get 或 RemoveOldest 时被删除
+	Removed                               // 被 Remove 显式删除
+	Replaced                              // 被 Add 用新值覆盖，回调收到的是旧值
+	Flushed                               // 被 Clear 清空
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case CapacityEvicted:
+		return "capacity"
+	case Expired:
+		return "expired"
+	case Removed:
+		return "removed"
+	case Replaced:
+		return "replaced"
+	case Flushed:
+		return "flushed"
+	}
+	return "unknown"
+}
+
+// EvictionPolicy 决定容量不足时淘汰哪个条目，见 NewCacheWithPolicy
+type EvictionPolicy int
+
+const (
+	// LRU 淘汰最久未访问的条目（默认）
+	LRU EvictionPolicy = iota
+	// LFU 淘汰访问次数（Add 和 Get 命中都算一次）最少的条目，次数相同时淘汰更久未访问的
+	// 适合少数热点 key 被大量一次性的扫描请求冲刷的场景：扫描进来的 key 只有一次访问，会先于热点 key 被淘汰；
+	// 代价是访问次数不会衰减，曾经很热、之后不再访问的 key 要等其它 key 的次数超过它之后才会被淘汰
+	LFU
+)
 
 // Value 实现 Len() 方法来返回值占用的内存大小
 type Value interface {
@@ -9,80 +58,376 @@ type Value interface {
 
 // entry 是双向链表节点数据（Value）的数据类型
 type entry struct {
-	key   string
-	value Value
+	key    string
+	value  Value
+	hits   int64     // 条目被 Get 命中的次数
+	expire time.Time // 过期时间，零值表示永不过期
+	pinned bool      // 被固定的条目不会因为容量不足被淘汰
+	index  int       // 条目在 expiring 堆中的下标，没有过期时间时为 -1
+
+	// 以下字段只在 LFU 策略下使用
+	freq     int64  // 访问次数，Add 和 Get 命中时加一
+	used     uint64 // 最近一次访问的序号，次数相同时用来判断谁更久未访问
+	lfuIndex int    // 条目在 lfu 堆中的下标，不在堆中（被固定或者不是 LFU 策略）时为 -1
 }
 
-// Cache 采用 LRU 算法实现缓存，它暂时并不是并发安全的
+// Cache 采用 LRU 算法实现缓存，它不是并发安全的，需要并发访问时使用 SyncCache
 type Cache struct {
 	maxBytes int64      // 缓存最大容量
 	nbytes   int64      // 当前缓存总容量
+	npinned  int64      // 被固定的条目占用的容量，包含在 nbytes 中
 	ll       *list.List // 使用 Go 内置的双向链表实现 LRU 算法
 	// 使用 map（哈希表）存储缓存数据，值是双向链表中节点的指针，这样就可以通过 O(1) 复杂度访问到对应的缓存值
 	cache     map[string]*list.Element
-	OnEvicted func(key string, value Value) // 当一个对值被清除时执行（钩子），可选
+	OnEvicted func(key string, value Value) // 当一个对值被清除时执行（钩子），可选；值被覆盖时不会调用
+
+	// OnEvictedReason 与 OnEvicted 相同，但额外给出条目离开缓存的原因，值被覆盖时也会调用（reason 为 Replaced）
+	// 设置了它时不再调用 OnEvicted。OnEvicted 保留不变，原有的代码不需要修改；需要区分原因时的迁移方式：
+	// 把原来的 func(key, value) 改成 func(key, value, reason) 赋给 OnEvictedReason（NewCache 的 onEvicted 传 nil），
+	// 在其中跳过 reason == Replaced 的调用即可保持与 OnEvicted 完全相同的行为
+	OnEvictedReason func(key string, value Value, reason EvictionReason)
+
+	// ExpiryCandidates 大于 1 时，RemoveOldest 在没有过期条目可删的情况下，从最久未访问的 ExpiryCandidates 个条目中
+	// 淘汰最早过期的那个（没有过期时间的条目视为最晚过期，过期时间相同时淘汰更久未访问的），为 0 或 1 时按纯 LRU 淘汰
+	ExpiryCandidates int
+
+	// MaxEntries 是缓存最多保存的条目数，与 maxBytes 同时生效，任意一个超出时都会淘汰，为 0 时不限制条目数
+	// 适合海量小值、字节数很难起到限制作用的场景；与字节数一样，剩下的条目都被固定时缓存会暂时超出限制
+	MaxEntries int
+
+	expiring expiryHeap       // 设置了过期时间的条目，按过期时间排成最小堆
+	now      func() time.Time // 当前时间，测试时可以替换
+
+	policy EvictionPolicy // 淘汰策略
+	lfu    lfuHeap        // LFU 策略下所有未固定的条目，按访问次数和最近访问序号排成最小堆
+	tick   uint64         // 访问序号，每次访问加一
+
+	hits      int64 // Get 命中的次数
+	misses    int64 // Get 没有命中（包括命中了已经过期的条目）的次数
+	evictions int64 // RemoveOldest 淘汰的条目数
 }
 
+// CacheStats 是缓存统计信息的快照，见 Cache.Stats
+type CacheStats struct {
+	Hits      int64 // Get 命中的次数
+	Misses    int64 // Get 没有命中的次数，命中了已经过期的条目也算作未命中
+	Evictions int64 // 因为容量不足（或者淘汰时发现已经过期）被 RemoveOldest 删除的条目数，不包括 Remove 和 Clear
+	Bytes     int64 // 当前占用的字节数，与 Bytes 相同
+	Len       int   // 当前的条目数，与 Len 相同
+}
+
+// NewCache 创建一个最多占用 maxBytes 字节的缓存，maxBytes 为 0 时不限制容量（不会因为字节数淘汰条目，条目数的限制见 MaxEntries）
+// 注意 0 并不表示“不缓存”，需要关闭缓存时应该在上层直接跳过 Add；maxBytes 不能为负数
 func NewCache(maxBytes int64, onEvicted func(string, Value)) *Cache {
-	return &Cache{
+	return NewCacheWithPolicy(maxBytes, LRU, onEvicted)
+}
+
+// NewCacheWithPolicy 与 NewCache 相同，但按 policy 选择容量不足时淘汰的条目
+// 两种策略下过期的条目都先被淘汰、固定的条目都不会被淘汰；LFU 策略下 ExpiryCandidates 不起作用，
+// Keys、OldestN 等方法返回的仍然是按访问时间排列的顺序
+func NewCacheWithPolicy(maxBytes int64, policy EvictionPolicy, onEvicted func(string, Value)) *Cache {
+	if maxBytes < 0 {
+		panic("lru: negative maxBytes")
+	}
+	c := &Cache{
 		maxBytes:  maxBytes,
 		ll:        list.New(),
 		cache:     make(map[string]*list.Element),
 		OnEvicted: onEvicted,
+		now:       time.Now,
 	}
+	c.policy = policy
+	return c
 }
 
 // Add 新增/修改缓存值
 func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 新增/修改缓存值，并设置它的过期时间，expire 为零值时永不过期
+// 过期的条目在下一次被 Get 访问时删除，在此之前仍然占用容量；容量不足时它们先于其它条目被淘汰，见 RemoveOldest
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
 	if ele, ok := c.cache[key]; ok {
 		// 要缓存的值已存在，将其移动到队首表示最近访问过
 		c.ll.MoveToFront(ele)
 		kv := ele.Value.(*entry) // 取出值
+		old := kv.value
 		// 重新计算新的值所占用的内存
 		c.nbytes += int64(value.Len()) - int64(kv.value.Len())
+		if kv.pinned {
+			c.npinned += int64(value.Len()) - int64(kv.value.Len())
+		}
 		// 更新缓存值
 		kv.value = value
+		c.setExpire(kv, expire)
+		c.touch(kv)
+		c.notify(key, old, Replaced)
 	} else {
 		// 要缓存的值不存在，将其加入到队首
-		ele = c.ll.PushFront(&entry{key, value})
+		kv := &entry{key: key, value: value, index: -1, lfuIndex: -1}
+		c.setExpire(kv, expire)
+		c.touch(kv)
+		if c.policy == LFU {
+			heap.Push(&c.lfu, kv)
+		}
+		ele = c.ll.PushFront(kv)
 		// 加入 cache map 中，使这个 key 与实际存储在链表中的值形成一个映射并能快速访问到
 		c.cache[key] = ele
 		// 累加内存
 		c.nbytes += int64(len(key)) + int64(value.Len())
 	}
-	
-	if c.maxBytes != 0 && c.nbytes > c.maxBytes {
-		c.RemoveOldest()
+
+	// 超出容量或条目数时一直淘汰到限制以内，一次 Add 可能淘汰多个条目；剩下的条目都被固定时无法继续淘汰，缓存会暂时超出限制
+	for c.overLimit() && c.RemoveOldest() {
+	}
+}
+
+// overLimit 判断缓存是否超出了容量或条目数的限制
+func (c *Cache) overLimit() bool {
+	return c.maxBytes != 0 && c.nbytes > c.maxBytes || c.MaxEntries != 0 && c.ll.Len() > c.MaxEntries
+}
+
+// Resize 修改缓存的容量，maxBytes 为 0 时不限制容量，不能为负数
+// 新的容量小于已用字节数时立即淘汰到容量以内；剩下的条目都被固定时无法继续淘汰，缓存会暂时超出容量
+func (c *Cache) Resize(maxBytes int64) {
+	if maxBytes < 0 {
+		panic("lru: negative maxBytes")
+	}
+	c.maxBytes = maxBytes
+	for c.overLimit() && c.RemoveOldest() {
+	}
+}
+
+// TryAddWithExpire 与 AddWithExpire 相同，但淘汰所有未固定的条目之后仍然放不下 value 时不做任何修改，返回 ErrCacheFull，
+// 保证缓存不会因为固定的条目而超出容量；没有限制容量时总是成功
+func (c *Cache) TryAddWithExpire(key string, value Value, expire time.Time) error {
+	if c.maxBytes != 0 {
+		pinned := c.npinned
+		if ele, ok := c.cache[key]; ok {
+			// 替换被固定的旧值时，旧值占用的容量会被释放
+			if kv := ele.Value.(*entry); kv.pinned {
+				pinned -= int64(len(kv.key)) + int64(kv.value.Len())
+			}
+		}
+		if pinned+int64(len(key))+int64(value.Len()) > c.maxBytes {
+			return ErrCacheFull
+		}
 	}
+	c.AddWithExpire(key, value, expire)
+	return nil
 }
 
 // Get 获取缓存值
 func (c *Cache) Get(key string) (value Value, ok bool) {
 	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		// 已经过期的值直接删除，当作未命中处理
+		if kv.expired(c.now()) {
+			c.removeElement(ele, Expired)
+			c.misses++
+			return nil, false
+		}
 		// 缓存中查找到值则将其移动到队首并返回 Value
 		c.ll.MoveToFront(ele)
-		value = ele.Value.(*entry).value
-		return value, true
+		kv.hits++
+		c.touch(kv)
+		c.hits++
+		return kv.value, true
 	}
 
+	c.misses++
 	return
 }
 
-// RemoveOldest 删除即缓存淘汰，从 LRU 链表队首移除最近最少访问的节点
-func (c *Cache) RemoveOldest() {
+// Peek 返回 key 对应的值，不改变访问顺序和命中次数，也不检查是否过期
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		return ele.Value.(*entry).value, true
+	}
+	return nil, false
+}
+
+// Remove 删除 key 对应的条目并返回 key 是否存在，key 不存在时什么也不做，删除的条目同样会触发 OnEvicted 回调
+func (c *Cache) Remove(key string) bool {
+	ele, ok := c.cache[key]
+	if ok {
+		c.removeElement(ele, Removed)
+	}
+	return ok
+}
+
+// Clear 删除所有条目（包括被固定的），容量等配置保持不变
+// 每个条目都会触发 OnEvicted 回调（reason 为 Flushed），顺序从最久未访问的开始，调用方可以据此释放与条目关联的资源
+func (c *Cache) Clear() {
+	for ele := c.ll.Back(); ele != nil; ele = c.ll.Back() {
+		c.removeElement(ele, Flushed)
+	}
+	// 删除元素不会让 map 缩小，换一个新的 map 归还之前占用的桶
+	c.cache = make(map[string]*list.Element)
+}
+
+// RemoveOldest 删除即缓存淘汰，返回是否删除了条目
+// 有已经过期的条目时优先删除其中最早过期的一个（即使它被固定，过期的条目本来就会在下次访问时删除），
+// 这样同时配置了 TTL 和容量时，不会为了保留一个已经没用的过期条目而淘汰新鲜的热点条目；
+// 否则从 LRU 链表队尾移除最近最少访问的节点（配置了 ExpiryCandidates 时在队尾的几个候选中选最早过期的）
+// 被固定的条目会被跳过，缓存为空或者所有条目都被固定时什么也不做并返回 false，说明已经无法通过淘汰释放容量
+func (c *Cache) RemoveOldest() bool {
+	ele, expired := c.victim()
+	if ele == nil {
+		return false
+	}
+	reason := CapacityEvicted
+	if expired {
+		reason = Expired
+	}
+	c.removeElement(ele, reason)
+	c.evictions++
+	return true
+}
+
+// GetOldest 返回下一次 RemoveOldest 会淘汰的条目，不删除它，也不改变访问顺序，没有可以淘汰的条目时 ok 为 false
+// 没有过期的条目、也没有固定的条目且 ExpiryCandidates 不大于 1 时，它就是 LRU 链表队尾最久未访问的条目
+func (c *Cache) GetOldest() (key string, value Value, ok bool) {
+	ele, _ := c.victim()
+	if ele == nil {
+		return "", nil, false
+	}
+	kv := ele.Value.(*entry)
+	return kv.key, kv.value, true
+}
+
+// victim 按 RemoveOldest 的规则选出要淘汰的节点，expired 表示它是已经过期的条目，没有可以淘汰的节点时返回 nil
+func (c *Cache) victim() (victim *list.Element, expired bool) {
+	if len(c.expiring) > 0 && c.expiring[0].expired(c.now()) {
+		return c.cache[c.expiring[0].key], true
+	}
+	if c.policy == LFU {
+		if len(c.lfu) == 0 {
+			return nil, false
+		}
+		return c.cache[c.lfu[0].key], false
+	}
+
 	ele := c.ll.Back() // 取出队尾节点
+	for ele != nil && ele.Value.(*entry).pinned {
+		ele = ele.Prev()
+	}
 	if ele == nil {
-		return
+		return nil, false
 	}
 
-	c.ll.Remove(ele) // 删除队尾节点
+	// 在队尾的候选中选择最早过期的条目，没有过期时间的条目排在最后
+	victim = ele
+	for n := 1; n < c.ExpiryCandidates && ele != nil; ele = ele.Prev() {
+		kv := ele.Value.(*entry)
+		if kv.pinned || ele == victim {
+			continue
+		}
+		n++
+		if v := victim.Value.(*entry); !kv.expire.IsZero() && (v.expire.IsZero() || kv.expire.Before(v.expire)) {
+			victim = ele
+		}
+	}
+	return victim, false
+}
+
+// Pin 固定 key 对应的条目，使它不会因为容量不足被淘汰，key 不存在时返回 false
+// 固定的条目仍然占用容量，过期或者被 Remove 时照常删除
+func (c *Cache) Pin(key string) bool {
+	ele, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	if kv := ele.Value.(*entry); !kv.pinned {
+		kv.pinned = true
+		c.npinned += int64(len(kv.key)) + int64(kv.value.Len())
+		if kv.lfuIndex >= 0 {
+			heap.Remove(&c.lfu, kv.lfuIndex)
+		}
+	}
+	return true
+}
+
+// Unpin 取消固定 key 对应的条目，之后它与其它条目一样参与淘汰
+func (c *Cache) Unpin(key string) {
+	if ele, ok := c.cache[key]; ok {
+		if kv := ele.Value.(*entry); kv.pinned {
+			kv.pinned = false
+			c.npinned -= int64(len(kv.key)) + int64(kv.value.Len())
+			if c.policy == LFU {
+				heap.Push(&c.lfu, kv)
+			}
+		}
+	}
+}
+
+// PinnedBytes 返回被固定的条目占用的容量
+func (c *Cache) PinnedBytes() int64 {
+	return c.npinned
+}
+
+// removeElement 从链表和映射表中删除一个节点并释放它占用的内存，reason 是删除的原因
+func (c *Cache) removeElement(ele *list.Element, reason EvictionReason) {
+	c.ll.Remove(ele) // 删除节点
 	kv := ele.Value.(*entry)
 	delete(c.cache, kv.key)                                // 从映射表中删除
+	c.setExpire(kv, time.Time{})                           // 从过期时间堆中删除
 	c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len()) // 释放内存
+	if kv.lfuIndex >= 0 {
+		heap.Remove(&c.lfu, kv.lfuIndex)
+	}
+	if kv.pinned {
+		c.npinned -= int64(len(kv.key)) + int64(kv.value.Len())
+	}
+
+	c.notify(kv.key, kv.value, reason)
+}
+
+// notify 调用钩子函数：优先调用 OnEvictedReason，否则调用 OnEvicted（值被覆盖时除外）
+func (c *Cache) notify(key string, value Value, reason EvictionReason) {
+	switch {
+	case c.OnEvictedReason != nil:
+		c.OnEvictedReason(key, value, reason)
+	case c.OnEvicted != nil && reason != Replaced:
+		c.OnEvicted(key, value)
+	}
+}
+
+// Keys 返回缓存中所有的 key，顺序与 LRU 链表一致：第一个是最近访问的，最后一个是下一个将被淘汰的
+// 返回顺序是确定的（不依赖 map 的遍历顺序），可以在测试中断言淘汰顺序；调用 Keys 不会改变访问顺序
+func (c *Cache) Keys() []string {
+	keys := make([]string, 0, c.ll.Len())
+	for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+		keys = append(keys, ele.Value.(*entry).key)
+	}
+	return keys
+}
 
-	// 如果传入了钩子函数就调用
-	if c.OnEvicted != nil {
-		c.OnEvicted(kv.key, kv.value)
+// OldestN 返回最久没有被访问的 n 个 key，第一个是下一个将被淘汰的，n 大于条目数时返回全部 key
+// 只从链表尾部向前读取，不会改变访问顺序
+func (c *Cache) OldestN(n int) []string {
+	if n > c.ll.Len() {
+		n = c.ll.Len()
+	}
+	if n <= 0 {
+		return nil
+	}
+	keys := make([]string, 0, n)
+	for ele := c.ll.Back(); ele != nil && len(keys) < n; ele = ele.Prev() {
+		keys = append(keys, ele.Value.(*entry).key)
+	}
+	return keys
+}
+
+// Range 从最近访问的条目开始依次对每个条目调用 f，f 返回 false 时停止遍历
+// 遍历不会改变访问顺序；f 中不能调用会修改缓存的方法（Add、Get、RemoveOldest 等）
+func (c *Cache) Range(f func(key string, value Value) bool) {
+	for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+		kv := ele.Value.(*entry)
+		if !f(kv.key, kv.value) {
+			return
+		}
 	}
 }
 
@@ -90,3 +435,132 @@ func (c *Cache) RemoveOldest() {
 func (c *Cache) Len() int {
 	return c.ll.Len()
 }
+
+// Stats 返回缓存的统计信息，计数从创建缓存开始累计，Clear 不会清零
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Bytes:     c.nbytes,
+		Len:       c.ll.Len(),
+	}
+}
+
+// Bytes 返回缓存当前占用的字节数（key 与 value 长度之和）
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
+
+// entryOverhead 估算每个条目在 key 和值之外占用的字节数：链表节点、entry 结构体，以及 map 中的一个槽位
+// map 的桶平均只装满 6.5/8，槽位的大小按 8/6.5 放大；没有计入内存分配器按规格向上取整的部分
+var entryOverhead = int64(unsafe.Sizeof(list.Element{}) + unsafe.Sizeof(entry{}) +
+	(unsafe.Sizeof("")+unsafe.Sizeof(&list.Element{})+1)*16/13)
+
+// Overhead 估算缓存在 Bytes 之外用于簿记的字节数，按条目数乘以每个条目的固定开销计算，只是近似值
+// 不包括 Value 本身的结构（如值是指针时指向的结构体），这部分由调用方按自己的 Value 类型估算
+func (c *Cache) Overhead() int64 {
+	return int64(c.ll.Len()) * entryOverhead
+}
+
+// AccessHistogram 按 2 的幂划分区间，统计条目命中次数的分布，用来观察访问的倾斜程度
+// 第 0 个桶是从未被命中过的条目数，第 i 个桶（i >= 1）是命中次数在 [2^(i-1), 2^i) 之间的条目数
+//
+// 命中次数跟随条目存在：条目被淘汰后计数随之丢弃，再次加入时从 0 开始；
+// 用 Add 覆盖已有 key 的值不会重置计数；计数不会随时间衰减
+func (c *Cache) AccessHistogram() []int {
+	var hist []int
+	for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+		b := bits.Len64(uint64(ele.Value.(*entry).hits))
+		for len(hist) <= b {
+			hist = append(hist, 0)
+		}
+		hist[b]++
+	}
+	return hist
+}
+
+// setExpire 修改条目的过期时间，同时维护 expiring 堆
+func (c *Cache) setExpire(kv *entry, expire time.Time) {
+	kv.expire = expire
+	switch {
+	case kv.index >= 0 && expire.IsZero():
+		heap.Remove(&c.expiring, kv.index)
+	case kv.index >= 0:
+		heap.Fix(&c.expiring, kv.index)
+	case !expire.IsZero():
+		heap.Push(&c.expiring, kv)
+	}
+}
+
+// touch 记录一次对条目的访问，LFU 策略下同时调整它在 lfu 堆中的位置
+func (c *Cache) touch(kv *entry) {
+	c.tick++
+	kv.freq++
+	kv.used = c.tick
+	if kv.lfuIndex >= 0 {
+		heap.Fix(&c.lfu, kv.lfuIndex)
+	}
+}
+
+// lfuHeap 是按访问次数、次数相同时按最近访问序号排列的最小堆，实现了 heap.Interface
+type lfuHeap []*entry
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].used < h[j].used
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].lfuIndex = i
+	h[j].lfuIndex = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	kv := x.(*entry)
+	kv.lfuIndex = len(*h)
+	*h = append(*h, kv)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	kv := old[len(old)-1]
+	old[len(old)-1] = nil
+	kv.lfuIndex = -1
+	*h = old[:len(old)-1]
+	return kv
+}
+
+// expiryHeap 是按过期时间排列的最小堆，实现了 heap.Interface
+type expiryHeap []*entry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expire.Before(h[j].expire) }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	kv := x.(*entry)
+	kv.index = len(*h)
+	*h = append(*h, kv)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	kv := old[len(old)-1]
+	old[len(old)-1] = nil
+	kv.index = -1
+	*h = old[:len(old)-1]
+	return kv
+}
+
+// expired 判断条目在 now 时刻是否已经过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && !now.Before(e.expire)
+}
@@ -2,7 +2,9 @@ package lru
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -14,7 +16,7 @@ func (s String) Len() int {
 func TestCache(t *testing.T) {
 	lru := NewCache(int64(0), nil)
 	lru.Add("foo", String("bar"))
-	
+
 	fmt.Println(lru.Get("foo"))
 	fmt.Println(lru.Get("foo2"))
 }
@@ -26,14 +28,462 @@ func TestCache_RemoveOldest(t *testing.T) {
 	cb := func(key string, value Value) {
 		fmt.Println("淘汰后调用回调")
 	}
-	
+
 	lru := NewCache(int64(cap), cb)
 	lru.Add(k1, String(v1))
 	lru.Add(k2, String(v2))
 	lru.Add(k3, String(v3))
-	
+
 	// 容量满了时，lru 会先淘汰最早进入的 key1
 	if _, ok := lru.Get("key1"); ok || lru.Len() != 2 {
 		t.Fatal("淘汰 key1 失败")
 	}
 }
+
+func TestCache_AccessHistogram(t *testing.T) {
+	lru := NewCache(int64(0), nil)
+	lru.Add("never", String("v"))
+	lru.Add("once", String("v"))
+	lru.Add("hot", String("v"))
+
+	lru.Get("once")
+	for i := 0; i < 5; i++ {
+		lru.Get("hot")
+	}
+	// 覆盖值不会重置命中次数
+	lru.Add("hot", String("v2"))
+
+	// 0 次：1 个；1 次：1 个；[4, 8) 次：1 个
+	want := []int{1, 1, 0, 1}
+	if got := lru.AccessHistogram(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("AccessHistogram() = %v, want %v", got, want)
+	}
+}
+
+func TestCache_AddWithExpire(t *testing.T) {
+	now := time.Now()
+	lru := NewCache(int64(0), nil)
+	lru.now = func() time.Time { return now }
+
+	lru.AddWithExpire("short", String("v"), now.Add(time.Second))
+	lru.AddWithExpire("long", String("v"), now.Add(time.Hour))
+	lru.Add("forever", String("v"))
+
+	now = now.Add(time.Minute)
+	if _, ok := lru.Get("short"); ok {
+		t.Fatal("short should be expired")
+	}
+	if _, ok := lru.Get("long"); !ok {
+		t.Fatal("long should not be expired")
+	}
+	if _, ok := lru.Get("forever"); !ok {
+		t.Fatal("forever should never expire")
+	}
+	// 过期的条目在访问时被删除，同时释放内存
+	if lru.Len() != 2 || lru.Bytes() != int64(len("long")+len("forever")+2) {
+		t.Fatalf("expired entry should be removed, len = %d, bytes = %d", lru.Len(), lru.Bytes())
+	}
+}
+
+func TestCache_ExpiryEviction(t *testing.T) {
+	now := time.Now()
+	lru := NewCache(int64(9), nil)
+	lru.now = func() time.Time { return now }
+
+	// 每个条目 3 字节，最多放下 3 个
+	lru.Add("f1", String("v"))
+	lru.AddWithExpire("e1", String("v"), now.Add(time.Second))
+	lru.Add("f2", String("v"))
+	lru.Get("e1") // e1 是最近访问的
+	now = now.Add(time.Minute)
+
+	// 容量不足时先淘汰已经过期的 e1，而不是最久未访问的 f1
+	lru.Add("f3", String("v"))
+	if got := lru.Keys(); !reflect.DeepEqual(got, []string{"f3", "f2", "f1"}) {
+		t.Fatalf("keys = %v, want the expired entry evicted first", got)
+	}
+	// 没有过期的条目时按 LRU 淘汰
+	lru.AddWithExpire("e2", String("v"), now.Add(time.Hour))
+	if got := lru.Keys(); !reflect.DeepEqual(got, []string{"e2", "f3", "f2"}) {
+		t.Fatalf("keys = %v, want pure LRU eviction", got)
+	}
+
+	// 在最久未访问的 3 个候选（f2、f3、e2）中淘汰最早过期的 e2，没有过期时间的条目最后考虑
+	lru.ExpiryCandidates = 3
+	lru.AddWithExpire("e3", String("v"), now.Add(time.Second))
+	if got := lru.Keys(); !reflect.DeepEqual(got, []string{"e3", "f3", "f2"}) {
+		t.Fatalf("keys = %v, want the soonest-to-expire candidate evicted", got)
+	}
+}
+
+func TestCache_Keys(t *testing.T) {
+	lru := NewCache(int64(0), nil)
+	lru.Add("a", String("1"))
+	lru.Add("b", String("2"))
+	lru.Add("c", String("3"))
+
+	lru.Get("a")
+	lru.Get("b")
+	lru.Add("c", String("4"))
+
+	// 最近访问的在前
+	want := []string{"c", "b", "a"}
+	if got := lru.Keys(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+	// Keys 和 Peek 不改变访问顺序
+	if v, ok := lru.Peek("a"); !ok || v.(String) != "1" {
+		t.Fatalf("Peek(a) = %v, %v", v, ok)
+	}
+	if got := lru.Keys(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+}
+
+func TestCache_OldestN(t *testing.T) {
+	lru := NewCache(int64(0), nil)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		lru.Add(k, String(k))
+	}
+	lru.Get("a")
+
+	if got, want := lru.OldestN(2), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("OldestN(2) = %v, want %v", got, want)
+	}
+	if got, want := lru.OldestN(10), []string{"b", "c", "d", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("OldestN(10) = %v, want %v", got, want)
+	}
+	if got := lru.OldestN(0); len(got) != 0 {
+		t.Fatalf("OldestN(0) = %v, want none", got)
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	evicted := make([]string, 0)
+	lru := NewCache(int64(0), func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+
+	if !lru.Remove("k1") {
+		t.Fatal("Remove(k1) should report the key as present")
+	}
+	if lru.Remove("missing") {
+		t.Fatal("Remove(missing) should report the key as absent")
+	}
+	if _, ok := lru.Get("k1"); ok || lru.Len() != 1 {
+		t.Fatalf("k1 should be removed, len = %d", lru.Len())
+	}
+	if lru.Bytes() != int64(len("k2")+len("v2")) {
+		t.Fatalf("bytes = %d after Remove", lru.Bytes())
+	}
+	if !reflect.DeepEqual(evicted, []string{"k1"}) {
+		t.Fatalf("evicted = %v, want [k1]", evicted)
+	}
+}
+
+func TestCache_Pin(t *testing.T) {
+	k1, k2, k3 := "key1", "key2", "k3"
+	v1, v2, v3 := "value1", "value2", "v3"
+	cap := len(k1 + k2 + v1 + v2)
+	lru := NewCache(int64(cap), nil)
+	lru.Add(k1, String(v1))
+	lru.Add(k2, String(v2))
+
+	if !lru.Pin(k1) || lru.Pin("missing") {
+		t.Fatal("Pin should report whether the key exists")
+	}
+	if lru.PinnedBytes() != int64(len(k1+v1)) {
+		t.Fatalf("pinned bytes = %d", lru.PinnedBytes())
+	}
+
+	// 最久未访问的 key1 被固定，淘汰的是 key2
+	lru.Add(k3, String(v3))
+	if _, ok := lru.Get(k1); !ok {
+		t.Fatal("pinned key1 should not be evicted")
+	}
+	if _, ok := lru.Get(k2); ok {
+		t.Fatal("key2 should be evicted instead of the pinned key1")
+	}
+
+	// 所有条目都被固定时无法再淘汰，TryAddWithExpire 拒绝写入
+	lru.Pin(k3)
+	if lru.RemoveOldest() {
+		t.Fatal("RemoveOldest should report that nothing can be evicted")
+	}
+	if err := lru.TryAddWithExpire(k2, String(v2), time.Time{}); err != ErrCacheFull {
+		t.Fatalf("TryAddWithExpire err = %v, want %v", err, ErrCacheFull)
+	}
+	if _, ok := lru.Get(k2); ok || lru.Len() != 2 {
+		t.Fatal("rejected value should not be added")
+	}
+	lru.Unpin(k3)
+
+	lru.Unpin(k1)
+	if lru.PinnedBytes() != 0 {
+		t.Fatalf("pinned bytes = %d after Unpin", lru.PinnedBytes())
+	}
+	lru.Get(k3)
+	lru.RemoveOldest()
+	if _, ok := lru.Get(k1); ok {
+		t.Fatal("key1 should be evictable after Unpin")
+	}
+}
+
+func TestCache_EvictionReason(t *testing.T) {
+	var got []string
+	lru := NewCache(int64(len("k1v1k2v2")), nil)
+	lru.OnEvictedReason = func(key string, value Value, reason EvictionReason) {
+		got = append(got, key+"="+string(value.(String))+":"+reason.String())
+	}
+	now := time.Now()
+	lru.now = func() time.Time { return now }
+
+	lru.Add("k1", String("v1"))
+	lru.Add("k1", String("v2"))
+	lru.AddWithExpire("k2", String("v2"), now.Add(time.Second))
+	lru.Add("k3", String("v3"))
+	now = now.Add(time.Minute)
+	lru.Get("k1")
+	lru.Add("k4", String("v4"))
+	lru.Remove("k4")
+	lru.Add("k5", String("v5"))
+	lru.Clear()
+
+	want := []string{
+		"k1=v1:replaced",
+		"k1=v2:capacity",
+		"k2=v2:expired",
+		"k4=v4:removed",
+		"k3=v3:flushed",
+		"k5=v5:flushed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("evictions = %v, want %v", got, want)
+	}
+	if lru.Len() != 0 || lru.Bytes() != 0 {
+		t.Fatalf("len = %d, bytes = %d after Clear", lru.Len(), lru.Bytes())
+	}
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	// 条目数先达到限制
+	lru := NewCache(int64(1<<10), nil)
+	lru.MaxEntries = 2
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"k3", "k2"}) {
+		t.Fatalf("keys = %v, want the two newest", keys)
+	}
+
+	// 字节数先达到限制，一次 Add 淘汰多个条目
+	lru = NewCache(int64(len("k1v1k2v2")), nil)
+	lru.MaxEntries = 10
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("value3"))
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"k3"}) {
+		t.Fatalf("keys = %v, want only k3", keys)
+	}
+
+	// 不限制字节数时只按条目数淘汰
+	lru = NewCache(int64(0), nil)
+	lru.MaxEntries = 1
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	if lru.Len() != 1 {
+		t.Fatalf("len = %d, want 1", lru.Len())
+	}
+}
+
+func TestCache_Peek(t *testing.T) {
+	lru := NewCache(int64(len("k1v1k2v2")), nil)
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+
+	// 反复 Peek 最旧的条目也不会让它免于被淘汰
+	for i := 0; i < 3; i++ {
+		if v, ok := lru.Peek("k1"); !ok || v.(String) != "v1" {
+			t.Fatalf("Peek(k1) = %v, %v", v, ok)
+		}
+	}
+	lru.Add("k3", String("v3"))
+	if _, ok := lru.Peek("k1"); ok {
+		t.Fatal("k1 should be evicted as the oldest entry despite Peek")
+	}
+	if _, ok := lru.Peek("missing"); ok {
+		t.Fatal("Peek(missing) should miss")
+	}
+}
+
+func TestCache_Clear(t *testing.T) {
+	evicted := 0
+	lru := NewCache(int64(0), func(key string, value Value) {
+		evicted++
+	})
+	lru.Add("k1", String("v1"))
+	lru.AddWithExpire("k2", String("v2"), time.Now().Add(time.Hour))
+	lru.Add("k3", String("v3"))
+	lru.Pin("k3")
+
+	lru.Clear()
+	if lru.Len() != 0 || lru.Bytes() != 0 || lru.PinnedBytes() != 0 || len(lru.expiring) != 0 {
+		t.Fatalf("len = %d, bytes = %d, pinned = %d, expiring = %d after Clear", lru.Len(), lru.Bytes(), lru.PinnedBytes(), len(lru.expiring))
+	}
+	if evicted != 3 {
+		t.Fatalf("OnEvicted called %d times, want 3", evicted)
+	}
+
+	// 清空之后照常使用
+	lru.Add("k1", String("v1"))
+	if v, ok := lru.Get("k1"); !ok || v.(String) != "v1" || lru.Bytes() != int64(len("k1v1")) {
+		t.Fatalf("Get(k1) = %v, %v after Clear, bytes = %d", v, ok, lru.Bytes())
+	}
+}
+
+func TestCache_Resize(t *testing.T) {
+	var evicted []string
+	lru := NewCache(int64(0), func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	for _, k := range []string{"k1", "k2", "k3", "k4"} {
+		lru.Add(k, String("v"))
+	}
+
+	// 缩容时从最旧的开始淘汰，直到放得下
+	lru.Resize(int64(len("k3vk4v")))
+	if !reflect.DeepEqual(evicted, []string{"k1", "k2"}) || lru.Bytes() != int64(len("k3vk4v")) {
+		t.Fatalf("evicted = %v, bytes = %d after shrinking", evicted, lru.Bytes())
+	}
+
+	// 扩容不淘汰任何条目，之后可以放下更多的值
+	lru.Resize(int64(1 << 10))
+	lru.Add("k5", String("v"))
+	if len(evicted) != 2 || lru.Len() != 3 {
+		t.Fatalf("evicted = %v, len = %d after growing", evicted, lru.Len())
+	}
+
+	// 比任何条目都小时淘汰到为空
+	lru.Resize(1)
+	if lru.Len() != 0 || len(evicted) != 5 {
+		t.Fatalf("len = %d, evicted = %v, want the cache emptied", lru.Len(), evicted)
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	lru := NewCache(int64(len("k1v1k2v2")), nil)
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Get("k1")
+	lru.Get("k1")
+	lru.Get("missing")
+	lru.Add("k3", String("v3")) // 淘汰 k2
+	lru.Get("k2")
+
+	want := CacheStats{Hits: 2, Misses: 2, Evictions: 1, Bytes: int64(len("k1v1k3v3")), Len: 2}
+	if got := lru.Stats(); got != want {
+		t.Fatalf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestCache_Range(t *testing.T) {
+	lru := NewCache(int64(0), nil)
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+	lru.Get("k1")
+
+	var got []string
+	lru.Range(func(key string, value Value) bool {
+		got = append(got, key+"="+string(value.(String)))
+		return true
+	})
+	if want := []string{"k1=v1", "k3=v3", "k2=v2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Range visited %v, want %v", got, want)
+	}
+
+	// f 返回 false 时停止，遍历不改变访问顺序
+	got = nil
+	lru.Range(func(key string, value Value) bool {
+		got = append(got, key)
+		return len(got) < 2
+	})
+	if !reflect.DeepEqual(got, []string{"k1", "k3"}) {
+		t.Fatalf("Range visited %v, want it to stop after two entries", got)
+	}
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"k1", "k3", "k2"}) {
+		t.Fatalf("keys = %v, Range should not change the order", keys)
+	}
+}
+
+func TestCache_GetOldest(t *testing.T) {
+	lru := NewCache(int64(0), nil)
+	if _, _, ok := lru.GetOldest(); ok {
+		t.Fatal("GetOldest on an empty cache should report false")
+	}
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+
+	// 多次调用结果不变，不改变访问顺序
+	for i := 0; i < 2; i++ {
+		if key, value, ok := lru.GetOldest(); !ok || key != "k1" || value.(String) != "v1" {
+			t.Fatalf("GetOldest = %q, %v, %v, want k1", key, value, ok)
+		}
+	}
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"k3", "k2", "k1"}) {
+		t.Fatalf("keys = %v, GetOldest should not change the order", keys)
+	}
+
+	// 与 RemoveOldest 选择的条目一致：固定的条目被跳过
+	lru.Pin("k1")
+	key, _, _ := lru.GetOldest()
+	lru.RemoveOldest()
+	if _, ok := lru.Peek(key); key != "k2" || ok {
+		t.Fatalf("GetOldest = %q, want k2 to be the next victim", key)
+	}
+}
+
+func TestCache_LFU(t *testing.T) {
+	var evicted []string
+	lru := NewCacheWithPolicy(int64(len("k1v1k2v2k3v3")), LFU, func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+	lru.Get("k1")
+	lru.Get("k1")
+	lru.Get("k2")
+
+	// k3 只访问过一次，即使比 k1、k2 更近也先被淘汰
+	lru.Add("k4", String("v4"))
+	if !reflect.DeepEqual(evicted, []string{"k3"}) {
+		t.Fatalf("evicted = %v, want [k3]", evicted)
+	}
+
+	// 一次性的扫描只在访问次数最少的条目之间互相淘汰，次数相同时淘汰更久未访问的
+	for _, k := range []string{"s1", "s2", "s3"} {
+		lru.Add(k, String("v"+k[1:]))
+	}
+	if !reflect.DeepEqual(evicted, []string{"k3", "k4", "s1", "s2"}) {
+		t.Fatalf("evicted = %v, want the scanned keys to churn", evicted)
+	}
+	for _, k := range []string{"k1", "k2", "s3"} {
+		if _, ok := lru.Peek(k); !ok {
+			t.Fatalf("%s should still be cached", k)
+		}
+	}
+
+	// 固定的条目不参与淘汰，取消固定之后照常参与
+	lru.Pin("s3")
+	if key, _, _ := lru.GetOldest(); key != "k2" {
+		t.Fatalf("GetOldest = %q, want k2 while s3 is pinned", key)
+	}
+	lru.Unpin("s3")
+	if key, _, _ := lru.GetOldest(); key != "s3" {
+		t.Fatalf("GetOldest = %q, want s3 after unpinning", key)
+	}
+}
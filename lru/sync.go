@@ -0,0 +1,120 @@
+package lru
+
+import (
+	"sync"
+	"time"
+)
+
+// SyncCache 是并发安全的 Cache，所有方法都在锁的保护下调用 Cache 上的同名方法，可以在多个 goroutine 中直接使用
+// Get 会修改访问顺序和命中计数，与 Add、Remove 等写操作一样需要独占的写锁；
+// 不改变访问顺序的 Peek、Len、Bytes、Keys 和 Stats 只加读锁，可以并发执行。读多写少、且不需要严格 LRU 顺序的场景
+// 可以用 Peek 代替 Get，让读取之间不再互相阻塞
+// 淘汰回调在持有写锁的情况下执行，回调中不能再调用同一个 SyncCache 的方法，否则会死锁
+type SyncCache struct {
+	mu sync.RWMutex
+	c  *Cache
+}
+
+// NewSyncCache 创建一个并发安全的缓存，参数与 NewCache 相同
+func NewSyncCache(maxBytes int64, onEvicted func(string, Value)) *SyncCache {
+	return &SyncCache{c: NewCache(maxBytes, onEvicted)}
+}
+
+// Add 新增/修改缓存值
+func (s *SyncCache) Add(key string, value Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.c.Add(key, value)
+}
+
+// AddWithExpire 新增/修改缓存值，并设置它的过期时间，见 Cache.AddWithExpire
+func (s *SyncCache) AddWithExpire(key string, value Value, expire time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.c.AddWithExpire(key, value, expire)
+}
+
+// Get 获取缓存值，并把条目移到队首
+func (s *SyncCache) Get(key string) (Value, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.Get(key)
+}
+
+// Peek 返回 key 对应的值，不改变访问顺序，只加读锁
+func (s *SyncCache) Peek(key string) (Value, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.Peek(key)
+}
+
+// Remove 删除 key 对应的条目并返回 key 是否存在
+func (s *SyncCache) Remove(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.Remove(key)
+}
+
+// RemoveOldest 淘汰一个条目，见 Cache.RemoveOldest
+func (s *SyncCache) RemoveOldest() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.RemoveOldest()
+}
+
+// GetOldest 返回下一次 RemoveOldest 会淘汰的条目，不改变访问顺序，只加读锁
+func (s *SyncCache) GetOldest() (key string, value Value, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.GetOldest()
+}
+
+// Clear 删除所有条目
+func (s *SyncCache) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.c.Clear()
+}
+
+// Resize 修改缓存的容量，见 Cache.Resize
+func (s *SyncCache) Resize(maxBytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.c.Resize(maxBytes)
+}
+
+// Keys 返回缓存中所有的 key，顺序与 Cache.Keys 相同
+func (s *SyncCache) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.Keys()
+}
+
+// Range 从最近访问的条目开始依次对每个条目调用 f，f 返回 false 时停止遍历，遍历期间持有读锁
+// f 中不能调用同一个 SyncCache 会加写锁的方法，否则会死锁
+func (s *SyncCache) Range(f func(key string, value Value) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.c.Range(f)
+}
+
+// Len 返回缓存的键值对数量
+func (s *SyncCache) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.Len()
+}
+
+// Bytes 返回缓存当前占用的字节数
+func (s *SyncCache) Bytes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.Bytes()
+}
+
+// Stats 返回缓存的统计信息
+func (s *SyncCache) Stats() CacheStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.Stats()
+}
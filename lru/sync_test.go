@@ -0,0 +1,53 @@
+package lru
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSyncCache_Concurrent(t *testing.T) {
+	const maxBytes = 200
+	evicted := 0
+	c := NewSyncCache(maxBytes, func(key string, value Value) {
+		evicted++
+	})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := "k" + strconv.Itoa((w*7+i)%40)
+				switch i % 5 {
+				case 0, 1:
+					c.Add(key, String("value"))
+				case 2:
+					c.Get(key)
+				case 3:
+					c.Peek(key)
+					c.Len()
+				case 4:
+					c.Remove(key)
+				}
+				if b := c.Bytes(); b > maxBytes {
+					t.Errorf("bytes = %d, over the limit of %d", b, maxBytes)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	s := c.Stats()
+	if s.Len != len(c.Keys()) || s.Bytes > maxBytes {
+		t.Fatalf("inconsistent stats %+v with %d keys", s, len(c.Keys()))
+	}
+	if s.Hits+s.Misses != 8*100 {
+		t.Fatalf("hits + misses = %d, want %d", s.Hits+s.Misses, 8*100)
+	}
+	if int64(evicted) < s.Evictions {
+		t.Fatalf("OnEvicted called %d times, fewer than %d evictions", evicted, s.Evictions)
+	}
+}
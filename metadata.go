@@ -0,0 +1,25 @@
+package mini_groupcache
+
+import "context"
+
+type metadataKey struct{}
+
+// ContextWithMetadata 返回携带请求级元数据的 ctx，元数据会与 ctx 中已有的合并，同名的以 md 为准
+// 元数据随 Group.GetContext 一起传给 GetterContext；请求转发给其它节点时以同名的 HTTP 头发送，
+// 对方节点只会接收通过 WithForwardHeaders 配置过的头
+func ContextWithMetadata(ctx context.Context, md map[string]string) context.Context {
+	merged := make(map[string]string)
+	for k, v := range MetadataFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, metadataKey{}, merged)
+}
+
+// MetadataFromContext 返回 ctx 中的请求级元数据，没有时返回 nil，返回的 map 不能修改
+func MetadataFromContext(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return md
+}
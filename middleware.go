@@ -0,0 +1,25 @@
+package mini_groupcache
+
+import (
+	"context"
+)
+
+// GetFunc 是 Group.GetContext 的函数形式
+type GetFunc func(ctx context.Context, key string) (ByteView, error)
+
+// Middleware 包装 GetFunc，在 Get 的前后加入日志、指标、鉴权、限流等通用逻辑
+// 中间件可以直接返回而不调用 next（如鉴权失败），也可以修改传给 next 的 ctx 和 key
+type Middleware func(next GetFunc) GetFunc
+
+// Use 注册中间件，先注册的在外层：Use(a, b) 之后一次 Get 的调用顺序为 a -> b -> Get 本身
+// 中间件只包装 Get 和 GetContext，其它节点转发来的请求、GetEntry、GetMulti 等不经过中间件；
+// key 在中间件之后才会规范化。与 RegisterPeers 一样需要在分组开始处理请求之前调用
+func (g *Group) Use(mws ...Middleware) {
+	g.middlewares = append(g.middlewares, mws...)
+
+	chain := GetFunc(g.getContext)
+	for i := len(g.middlewares) - 1; i >= 0; i-- {
+		chain = g.middlewares[i](chain)
+	}
+	g.chain = chain
+}
@@ -0,0 +1,54 @@
+package mini_groupcache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroup_Use(t *testing.T) {
+	group := NewGroup("middleware", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value-" + key), nil
+	}))
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next GetFunc) GetFunc {
+			return func(ctx context.Context, key string) (ByteView, error) {
+				calls = append(calls, name)
+				return next(ctx, key)
+			}
+		}
+	}
+	deny := func(next GetFunc) GetFunc {
+		return func(ctx context.Context, key string) (ByteView, error) {
+			if strings.HasPrefix(key, "secret") {
+				return ByteView{}, fmt.Errorf("access to %s denied", key)
+			}
+			return next(ctx, key)
+		}
+	}
+	group.Use(trace("outer"), deny)
+	group.Use(trace("inner"))
+
+	if v, err := group.Get("a"); err != nil || v.String() != "value-a" {
+		t.Fatalf("Get(a) = %q, %v", v, err)
+	}
+	if want := []string{"outer", "inner"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	// 中间件可以直接拦截请求，不会进入加载流程
+	calls = nil
+	if _, err := group.Get("secret-1"); err == nil {
+		t.Fatal("middleware should reject the key")
+	}
+	if want := []string{"outer"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	if s := group.Stats(); s.Gets != 1 {
+		t.Fatalf("rejected request should not reach the group, got %d gets", s.Gets)
+	}
+}
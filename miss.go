@@ -0,0 +1,29 @@
+package mini_groupcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// MissInfo 描述一次缓存未命中之后的实际加载，用于 WithOnMiss
+type MissInfo struct {
+	Key     string
+	Source  Source        // 提供值的一方：远程节点（包括备用集群）或本地 getter
+	Peer    string        // Source 为 SourcePeer 时提供值的节点，否则为空
+	Latency time.Duration // 从开始加载到拿到结果的耗时，GetMulti 批量获取的 key 从批量请求开始计时
+	Err     error         // 加载失败时的错误，此时 Source 和 Peer 是最后尝试的一方
+}
+
+// reportMiss 按抽样比例把 info 提交到后台任务池，交给 WithOnMiss 设置的回调
+func (g *Group) reportMiss(info MissInfo, start time.Time, err error) {
+	if g.onMiss == nil {
+		return
+	}
+	if atomic.AddInt64(&g.missCounter, 1)%g.missSample != 0 {
+		return
+	}
+	info.Latency, info.Err = time.Since(start), err
+	if !g.pool.Submit(func() { g.onMiss(info) }) {
+		g.stats.missNotifyDrops.Add(1)
+	}
+}
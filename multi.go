@@ -0,0 +1,191 @@
+package mini_groupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mini-groupcache/testpb"
+	"sync"
+	"time"
+)
+
+// peerBatch 是属于同一个远程节点、需要一起获取的一组 key
+type peerBatch struct {
+	peer BatchPeerGetter
+	keys []string
+}
+
+// GetMulti 批量获取多个 key 的缓存值，返回成功获取的部分和每个失败 key 的错误，单个 key 失败不影响其它 key
+// 缓存没命中的 key 按所属节点分组，支持批量获取（BatchPeerGetter）的节点每组只发送一个请求，各节点的请求并发进行；
+// 某个节点失败时只影响属于它的 key，这些 key 与单独调用 Get 时一样依次回退到备用集群和本地数据源
+// 两个返回值都以调用方传入的 key 为键，一个 key 只会出现在其中一个里
+func (g *Group) GetMulti(ctx context.Context, keys []string) (map[string]ByteView, map[string]error) {
+	ctx = ensureRequestID(ctx)
+	values := make(map[string]ByteView, len(keys))
+	errs := make(map[string]error)
+
+	// 规范化之后相同的 key 只获取一次，结果分发给所有对应的原始 key
+	pending := make(map[string][]string)
+	for _, key := range keys {
+		k := g.normalize(key)
+		if k == "" {
+			errs[key] = fmt.Errorf("key is required")
+			continue
+		}
+		g.countGet()
+		if v, ok := g.lookupCache(k); ok {
+			g.stats.cacheHits.Add(1)
+			values[key] = v
+			continue
+		}
+		pending[k] = append(pending[k], key)
+	}
+
+	var mu sync.Mutex
+	done := func(k string, v ByteView, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, key := range pending[k] {
+			if err != nil {
+				errs[key] = err
+			} else {
+				values[key] = v
+			}
+		}
+	}
+
+	// 属于本节点或所属节点不支持批量获取的 key 逐个走 load，其余的按节点分组
+	var single []string
+	batches := make(map[string]*peerBatch)
+	for k := range pending {
+		if g.peers != nil {
+			if peer, ok := g.peers.PickPeer(k); ok {
+				if bp, ok := peer.(BatchPeerGetter); ok && g.mayExist(k) {
+					name := peerName(peer)
+					if batches[name] == nil {
+						batches[name] = &peerBatch{peer: bp}
+					}
+					batches[name].keys = append(batches[name].keys, k)
+					continue
+				}
+			}
+		}
+		single = append(single, k)
+	}
+
+	var wg sync.WaitGroup
+	for _, b := range batches {
+		wg.Add(1)
+		go func(b *peerBatch) {
+			defer wg.Done()
+			g.loadBatch(ctx, b, done)
+		}(b)
+	}
+	for _, k := range single {
+		v, err := g.load(ctx, k)
+		done(k, v, err)
+	}
+	wg.Wait()
+
+	return values, errs
+}
+
+// loadBatch 向远程节点批量获取 b.keys，获取失败的 key 经过 singleflight 回退到备用集群和本地数据源
+// 批量请求本身不经过 singleflight，与同时进行的 Get 可能会重复请求所属节点
+func (g *Group) loadBatch(ctx context.Context, b *peerBatch, done func(key string, v ByteView, err error)) {
+	g.stats.loads.Add(int64(len(b.keys)))
+	counters := g.peerCounters(b.peer)
+	res := &testpb.BatchResponse{}
+	start := time.Now()
+	err := b.peer.GetBatch(ctx, &testpb.BatchRequest{Group: g.name, Keys: batchKeys(b.keys)}, res)
+	counters.latency.observe(time.Since(start))
+	if err == nil && len(res.GetResponses()) != len(b.keys) {
+		err = fmt.Errorf("peer returned %d responses for %d keys", len(res.GetResponses()), len(b.keys))
+	}
+	if err != nil {
+		log.Println("[Groupcache] Failed to get batch from peer", err)
+	}
+
+	policy := g.fallbackPolicy(ctx)
+	for i, key := range b.keys {
+		kerr := err
+		if kerr == nil {
+			kerr = g.checkResponse(key, res.Responses[i])
+		}
+		if kerr == nil {
+			g.stats.peerLoads.Add(1)
+			g.reportMiss(MissInfo{Key: key, Source: SourcePeer, Peer: peerName(b.peer)}, start, nil)
+			done(key, g.storePeerResponse(key, res.Responses[i]), nil)
+			continue
+		}
+		// 所属节点确定 key 不存在，与 fetch 一样直接返回，不再回退到数据源重复查询
+		if errors.Is(kerr, ErrNotFound) {
+			g.reportMiss(MissInfo{Key: key, Source: SourcePeer, Peer: peerName(b.peer)}, start, kerr)
+			done(key, ByteView{}, kerr)
+			continue
+		}
+		g.stats.peerErrors.Add(1)
+		counters.errors.Add(1)
+		// 请求在节点之间循环转发或者不允许回退时，与 load 一样直接返回错误
+		if errors.Is(kerr, ErrTooManyHops) || policy == FallbackStrict {
+			g.reportMiss(MissInfo{Key: key, Source: SourcePeer, Peer: peerName(b.peer)}, start, kerr)
+			done(key, ByteView{}, kerr)
+			continue
+		}
+
+		view, lerr := g.loader.DoContext(ctx, key, func() (any, error) {
+			info := MissInfo{Key: key}
+			v, err := g.loadFallback(detachedContext{ctx}, key, counters, &info)
+			g.reportMiss(info, start, err)
+			return v, err
+		})
+		if lerr != nil {
+			done(key, ByteView{}, lerr)
+			continue
+		}
+		done(key, view.(ByteView), nil)
+	}
+}
+
+// batchKeys 把 key 转换成 BatchRequest 中 bytes 类型的 keys，每个 key 单独编码，任意字节都能原样传到对方节点
+func batchKeys(keys []string) [][]byte {
+	b := make([][]byte, len(keys))
+	for i, key := range keys {
+		b[i] = []byte(key)
+	}
+	return b
+}
+
+// checkResponse 检查批量响应中单个 key 的结果，返回节点报告的错误或者值没有通过校验的错误
+func (g *Group) checkResponse(key string, res *testpb.Response) error {
+	if err := responseError(res); err != nil {
+		return err
+	}
+	return g.validate(key, res.Value)
+}
+
+// responseError 返回批量响应中单个 key 的节点报告的错误，节点报告 key 不存在时返回 notFoundError
+func responseError(res *testpb.Response) error {
+	switch {
+	case res.GetNotFound():
+		return &notFoundError{msg: res.GetError()}
+	case res.GetError() != "":
+		return errors.New(res.GetError())
+	}
+	return nil
+}
+
+// notFoundError 是所属节点报告 key 不存在时的错误，保留节点给出的错误信息，errors.Is 判断为 ErrNotFound
+type notFoundError struct {
+	msg string
+}
+
+func (e *notFoundError) Error() string {
+	if e.msg == "" {
+		return ErrNotFound.Error()
+	}
+	return e.msg
+}
+
+func (e *notFoundError) Unwrap() error { return ErrNotFound }
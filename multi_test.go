@@ -0,0 +1,83 @@
+package mini_groupcache
+
+import (
+	"context"
+	"fmt"
+	"mini-groupcache/testpb"
+	"strings"
+	"testing"
+)
+
+// stubBatchPeer 模拟一个支持批量获取的远程节点，返回 "<name>-<key>"
+type stubBatchPeer struct {
+	name    string
+	err     error
+	batches int
+}
+
+func (p *stubBatchPeer) Get(ctx context.Context, in *testpb.Request, out *testpb.Response) error {
+	return fmt.Errorf("GetMulti should use GetBatch")
+}
+
+func (p *stubBatchPeer) GetBatch(ctx context.Context, in *testpb.BatchRequest, out *testpb.BatchResponse) error {
+	p.batches++
+	if p.err != nil {
+		return p.err
+	}
+	for _, key := range in.Keys {
+		out.Responses = append(out.Responses, &testpb.Response{Value: []byte(p.name + "-" + string(key))})
+	}
+	return nil
+}
+
+func (p *stubBatchPeer) String() string { return p.name }
+
+// prefixPicker 按 key 的前缀（"<prefix>:"）选择节点，没有对应节点的 key 属于本节点
+type prefixPicker map[string]PeerGetter
+
+func (p prefixPicker) PickPeer(key string) (PeerGetter, bool) {
+	peer, ok := p[strings.SplitN(key, ":", 2)[0]]
+	return peer, ok
+}
+
+func TestGroup_GetMulti(t *testing.T) {
+	healthy := &stubBatchPeer{name: "healthy"}
+	down := &stubBatchPeer{name: "down", err: fmt.Errorf("peer is down")}
+	group := NewGroup("get-multi", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if strings.HasSuffix(key, "missing") {
+			return nil, fmt.Errorf("%s not found", key)
+		}
+		return []byte("local-" + key), nil
+	}))
+	group.RegisterPeers(prefixPicker{"h": healthy, "d": down})
+
+	keys := []string{"a", "b", "missing", "h:1", "h:2", "d:1", "d:missing"}
+	values, errs := group.GetMulti(context.Background(), keys)
+
+	want := map[string]string{
+		"a":   "local-a",
+		"b":   "local-b",
+		"h:1": "healthy-h:1",
+		"h:2": "healthy-h:2",
+		// 所属节点失败的 key 与 Get 一样回退到本地数据源
+		"d:1": "local-d:1",
+	}
+	if len(values) != len(want) {
+		t.Fatalf("got %d values, want %d: %v", len(values), len(want), values)
+	}
+	for k, v := range want {
+		if values[k].String() != v {
+			t.Fatalf("values[%q] = %q, want %q", k, values[k].String(), v)
+		}
+	}
+	if len(errs) != 2 || errs["missing"] == nil || errs["d:missing"] == nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	// 每个节点只收到一个批量请求
+	if healthy.batches != 1 || down.batches != 1 {
+		t.Fatalf("batches = %d, %d, want 1 per peer", healthy.batches, down.batches)
+	}
+	if ps := group.PeerStats()["down"]; ps.Errors != 2 || ps.Fallbacks != 2 {
+		t.Fatalf("down peer stats = %+v", ps)
+	}
+}
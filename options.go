@@ -0,0 +1,298 @@
+package mini_groupcache
+
+import (
+	"mini-groupcache/bloom"
+	"mini-groupcache/lru"
+	"mini-groupcache/workerpool"
+	"time"
+)
+
+// GroupOption 用于在创建分组时配置可选的行为，所有选项默认关闭
+type GroupOption func(*Group)
+
+// WithOnStore 设置值写入缓存前的转换钩子，如加密、压缩、附加校验和等
+// 缓存中保存的是转换后的值，缓存容量也按转换后的大小计算
+func WithOnStore(fn func(value []byte) []byte) GroupOption {
+	return func(g *Group) {
+		g.onStore = fn
+	}
+}
+
+// WithOnLoad 设置值从缓存读出后的转换钩子，通常与 WithOnStore 成对使用（如解密、解压）
+func WithOnLoad(fn func(value []byte) []byte) GroupOption {
+	return func(g *Group) {
+		g.onLoad = fn
+	}
+}
+
+// WithWorkerPool 设置分组后台任务池的 worker 数量和队列长度，后台加载等任务都在这个池子里执行
+func WithWorkerPool(workers, queueSize int) GroupOption {
+	return func(g *Group) {
+		g.pool = workerpool.New(workers, queueSize)
+	}
+}
+
+// WithBackgroundDropPolicy 设置后台任务池的队列已满时丢弃哪个任务，默认为 workerpool.DropNewest
+// 所有后台任务（GetOrQueue 的异步加载、EvictionAsync 的淘汰通知、WithOnMiss 的回调等）共用同一个任务池，受同一个上限约束；
+// 使用 DropOldest 时被挤掉的旧任务只计入 BackgroundStats 的 Dropped，不会计入 Stats 中各类任务自己的丢弃数
+func WithBackgroundDropPolicy(policy workerpool.DropPolicy) GroupOption {
+	return func(g *Group) {
+		g.dropPolicy = policy
+	}
+}
+
+// WithTTL 设置缓存值的存活时间，过期后再次访问会重新加载
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ttl = ttl
+	}
+}
+
+// WithTTLFunc 按条目计算缓存值的存活时间，代替 WithTTL 设置的统一时间，返回 0 表示永不过期
+// fn 在 getter 加载出值、存入缓存之前调用，value 是 getter 返回的原始值（执行 onStore 之前），
+// 可以据此让大的值更快过期以便回收内存，让小的热点值保留更久
+func WithTTLFunc(fn func(key string, value []byte) time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ttlFunc = fn
+	}
+}
+
+// WithBloomFilter 设置已知 key 集合的布隆过滤器，过滤器判断一定不存在的 key 会直接返回 ErrNotFound，不会调用 getter
+// 过滤器需要包含数据源中所有的 key，之后新写入数据源的 key 要及时调用 f.Add 加入，否则会被误判为不存在
+func WithBloomFilter(f *bloom.Filter) GroupOption {
+	return func(g *Group) {
+		g.bloom = f
+	}
+}
+
+// WithRateLimit 限制调用 getter 的速率为每秒 rate 次，最多允许 burst 次的突发，用于保护有严格配额的数据源
+// 与并发数限制不同，它限制的是单位时间内的调用次数；令牌耗尽时的行为由 mode 决定
+func WithRateLimit(rate float64, burst int, mode RateLimitMode) GroupOption {
+	return func(g *Group) {
+		g.limiter = newTokenBucket(rate, burst, mode)
+	}
+}
+
+// WithWriteThrough 设置 Group.Put 写入数据源的函数，fn 返回错误时 Put 不会更新缓存
+func WithWriteThrough(fn func(key string, value []byte) error) GroupOption {
+	return func(g *Group) {
+		g.writeThrough = fn
+	}
+}
+
+// WithValidator 设置加载结果的校验函数（如检查是否为合法的 JSON、是否为空、校验和是否正确），防止有问题的数据源或节点污染缓存
+// fn 在值存入缓存之前调用：getLocally 中校验 getter 返回的值（包括 NoCache 的值），getFromPeer 和 GetMulti 中校验远程节点返回的值
+// 校验失败的值不会被缓存也不会返回给调用方；远程节点的值校验失败时与请求失败一样回退到备用集群和本地数据源，
+// getter 的值校验失败时 Get 返回包装了 fn 的错误的 error。Put 写入的值和 Restore 导入的值不经过校验
+func WithValidator(fn func(key string, value []byte) error) GroupOption {
+	return func(g *Group) {
+		g.validator = fn
+	}
+}
+
+// WithKeyNormalizer 设置 key 的规范化函数（如去掉首尾空白、转成小写），让语义相同的 key 命中同一个缓存条目
+// 分组的所有入口都会先规范化 key，之后的缓存查找、哈希环路由、getter 收到的都是规范化之后的 key，
+// 因此同一个分组在所有节点上必须配置相同的规范化函数，且 fn 对已经规范化的 key 再次调用时结果不变
+func WithKeyNormalizer(fn func(key string) string) GroupOption {
+	return func(g *Group) {
+		g.keyFunc = fn
+	}
+}
+
+// WithPinOverflow 设置固定的条目占满缓存容量时的处理方式，默认为 PinOverflowReject
+func WithPinOverflow(policy PinOverflowPolicy) GroupOption {
+	return func(g *Group) {
+		g.mainCache.pinOverflow = policy
+		g.hotCache.pinOverflow = policy
+	}
+}
+
+// WithSmallValueArena 让长度小于 threshold 的值在写入缓存时复制到 slabSize 字节的大块内存（slab）中，
+// 适合缓存了海量小值的场景：值的个数不再决定堆对象的个数，可以明显减轻 GC 的负担
+// slab 中的空间不会单独回收，一个 slab 上的值全部离开缓存后整块交给 GC，只要还有一个值在缓存中，整个 slab 就不会释放
+// slab 占用的内存不计入缓存容量：最坏情况下每个还在缓存中的小值各自占住一个 slab，占用可达小值个数 × slabSize，
+// 即最多约为容量的 slabSize / (最短的 key 加值的长度) 倍；频繁淘汰、新旧值混杂时要用 Stats.ArenaBytes 观察实际占用，
+// 并选择不太大的 slabSize
+func WithSmallValueArena(threshold, slabSize int) GroupOption {
+	return func(g *Group) {
+		g.mainCache.arena = newArena(threshold, slabSize)
+		g.hotCache.arena = newArena(threshold, slabSize)
+	}
+}
+
+// WithOnEvicted 设置 mainCache 中的条目离开缓存（容量不足被淘汰、过期、被删除）时的回调，value 是缓存中保存的值（执行 onStore 之后的，开启 WithCompression 时可能是压缩后的）
+// hotCache 中远程节点值的副本离开缓存时不会通知；用新值覆盖已有的条目也不会通知
+// 需要区分离开缓存的原因或者需要知道值被覆盖时，使用 WithOnEvictedReason
+// delivery 为 EvictionSync 时回调在持有缓存锁的情况下逐个同步执行，顺序与淘汰顺序一致，回调返回之前淘汰不会继续；
+// 为 EvictionAsync 时回调在后台任务池中执行，使用异步通知需要注意：
+//   - 多个回调可能并发执行，到达顺序与淘汰顺序不一定一致
+//   - 回调执行时 key 可能已经被重新写入缓存，不能据此认为 key 此刻不在缓存中
+//   - 任务池的队列满时通知会被丢弃（计入 Stats.EvictionNotifyDrops），不适合需要可靠送达的场景
+//   - 回调与后台加载共用任务池，慢的回调会挤占后台加载
+func WithOnEvicted(fn func(key string, value ByteView), delivery EvictionDelivery) GroupOption {
+	return func(g *Group) {
+		WithOnEvictedReason(func(key string, value ByteView, reason lru.EvictionReason) {
+			fn(key, value)
+		}, delivery)(g)
+		// 在提交到任务池之前过滤掉覆盖通知，不占用任务池
+		notify := g.mainCache.onEvict
+		g.mainCache.onEvict = func(key string, value ByteView, reason lru.EvictionReason) {
+			if reason != lru.Replaced {
+				notify(key, value, reason)
+			}
+		}
+	}
+}
+
+// WithOnEvictedReason 与 WithOnEvicted 相同，但回调额外收到条目离开缓存的原因：
+// lru.CapacityEvicted（容量不足）、lru.Expired（过期）、lru.Removed（被 Remove 等删除）或者 lru.Replaced（被新值覆盖，value 是旧值）
+// 异步送达时被覆盖的通知与其它通知一样可能被丢弃
+func WithOnEvictedReason(fn func(key string, value ByteView, reason lru.EvictionReason), delivery EvictionDelivery) GroupOption {
+	return func(g *Group) {
+		if delivery == EvictionSync {
+			g.mainCache.onEvict = fn
+			return
+		}
+		g.mainCache.onEvict = func(key string, value ByteView, reason lru.EvictionReason) {
+			if !g.pool.Submit(func() { fn(key, value, reason) }) {
+				g.stats.evictNotifyDrops.Add(1)
+			}
+		}
+	}
+}
+
+// WithCompression 让长度不小于 threshold 的值用 DEFLATE 压缩之后再存入缓存，读取时自动解压，用 CPU 换内存
+// 压缩在 onStore 钩子之后进行，缓存容量按压缩后的大小计算；压缩之后没有变小的值按原样存入
+// 开启后 Stats.Compression 给出压缩的效果，可以据此判断 CPU 开销是否值得、threshold 是否合适
+// 节点之间传输的始终是解压之后的原始值；threshold 为负数时 panic
+func WithCompression(threshold int) GroupOption {
+	return func(g *Group) {
+		g.compressor = newCompressor(threshold)
+	}
+}
+
+// WithFallbackPolicy 设置分组默认的 FallbackPolicy，单个请求可以用 ContextWithFallbackPolicy 覆盖
+func WithFallbackPolicy(policy FallbackPolicy) GroupOption {
+	return func(g *Group) {
+		g.fallback = policy
+	}
+}
+
+// WithOnMiss 设置缓存未命中时的回调，用于把未命中的 key、提供值的一方和加载耗时送到分析系统（如离线分析热点 key）
+// 回调针对实际发生的加载：被 singleflight 合并的多个请求只对应一次回调，命中缓存的请求不会触发
+// 每 sampleEvery 次加载调用一次（sampleEvery <= 1 时每次都调用），在后台任务池中执行，不会增加请求的延迟；
+// 任务池的队列满时丢弃这次回调，计入 Stats.MissNotifyDrops
+func WithOnMiss(fn func(info MissInfo), sampleEvery int) GroupOption {
+	return func(g *Group) {
+		if sampleEvery < 1 {
+			sampleEvery = 1
+		}
+		g.onMiss = fn
+		g.missSample = int64(sampleEvery)
+	}
+}
+
+// WithMicroCache 让成功的加载结果在 singleflight 中再保留 window（如 5ms），这段时间内缓存没命中的同一个 key 直接拿到这个结果，
+// 时间上没有重叠的加载也会被合并，用于应对极热的 key 的瞬时突发（如缓存关闭、值刚被淘汰或 NoCache 的值）；它是缓存之外的补充，不代替缓存
+// 拿到的值最多比数据源旧 window；最多保留 maxKeys 个 key，已满时新的结果不再保留。Remove 和 Put 会丢弃 key 上保留的结果
+// 直接拿到保留结果的请求数见 Stats.MicroCacheHits
+func WithMicroCache(window time.Duration, maxKeys int) GroupOption {
+	return func(g *Group) {
+		g.loader.Retain(window, maxKeys)
+	}
+}
+
+// WithWarmCriteria 设置 IsWarm 判断预热完成的条件：mainCache 填到容量的 fillRatio，或者距离第一个请求已经过去 after，满足任意一个即可
+// fillRatio <= 0 或 after <= 0 表示不使用对应的条件，两个都不使用时分组一开始就是预热完成的；
+// 缓存关闭或不限制容量时填充比例的条件永远不会满足。fillRatio 大于 1 时 panic
+func WithWarmCriteria(fillRatio float64, after time.Duration) GroupOption {
+	return func(g *Group) {
+		if fillRatio > 1 {
+			panic("warm fill ratio must not exceed 1")
+		}
+		g.warmFill = fillRatio
+		g.warmAfter = after
+	}
+}
+
+// WithHedging 开启对冲读：向 key 的所属节点请求超过 delay 还没有返回时，再向哈希环上的下一个节点（见 consistenthash.Map.GetTwo）请求，
+// 先成功的结果胜出，另一个请求随即被取消。收到对冲请求的节点直接在本地加载（缓存没命中时调用它自己的 getter），不再转发给所属节点
+// 对冲以额外的请求（和第二选择节点上的额外加载）换取更低的尾延迟，delay 一般取所属节点延迟的 P95 左右；
+// 需要注册的 PeerPicker 实现 HedgePeerPicker（如 HTTPPool），否则不生效。发起和胜出的对冲数见 Stats.Hedges 和 Stats.HedgeWins
+// delay 必须为正数，否则 panic
+func WithHedging(delay time.Duration) GroupOption {
+	return func(g *Group) {
+		if delay <= 0 {
+			panic("hedge delay must be positive")
+		}
+		g.hedgeDelay = delay
+	}
+}
+
+// WithHealthWeights 设置 Group.Health 中各项指标的权重，默认为 DefaultHealthWeights
+// 权重不能为负数，也不能全为 0，否则 panic
+func WithHealthWeights(w HealthWeights) GroupOption {
+	return func(g *Group) {
+		if w.HitRate < 0 || w.Churn < 0 || w.GetterError < 0 || w.HitRate+w.Churn+w.GetterError == 0 {
+			panic("health weights must be non-negative and not all zero")
+		}
+		g.healthWeights = w
+	}
+}
+
+// WithExpiryAwareEviction 让容量不足时的淘汰参考过期时间：在最久未访问的 candidates 个条目中淘汰最早过期的那个，
+// 没有过期时间的条目最后考虑，见 lru.Cache.ExpiryCandidates。无论是否设置，已经过期的条目总是先于其它条目被淘汰
+func WithExpiryAwareEviction(candidates int) GroupOption {
+	return func(g *Group) {
+		g.mainCache.expiryCandidates = candidates
+		g.hotCache.expiryCandidates = candidates
+	}
+}
+
+// WithEvictionPolicy 设置 mainCache 和 hotCache 容量不足时的淘汰策略，默认为 lru.LRU
+// lru.LFU 按访问次数淘汰，适合少数热点 key 与大量一次性扫描混在一起、LRU 会被扫描冲刷的访问模式，见 lru.LFU
+func WithEvictionPolicy(policy lru.EvictionPolicy) GroupOption {
+	return func(g *Group) {
+		g.mainCache.policy = policy
+		g.hotCache.policy = policy
+	}
+}
+
+// WithStore 用 fn 创建 mainCache 和 hotCache 的底层存储，代替默认的 lru.Cache，用于接入其它淘汰策略的实现
+// 存储不支持固定条目（没有实现 Pin 等方法）时 Group.Pin 返回错误；设置了 WithStore 时 WithEvictionPolicy 和 WithExpiryAwareEviction 不起作用
+func WithStore(fn StoreFunc) GroupOption {
+	return func(g *Group) {
+		g.mainCache.newStore = fn
+		g.hotCache.newStore = fn
+	}
+}
+
+// WithCacheShards 把 mainCache 和 hotCache 各分成 n 个独立加锁的分片，减少高并发下的锁竞争，n 必须是 2 的正整数次幂
+// 默认按 GOMAXPROCS 选择，但有容量限制时每个分片至少 1MB；容量按分片平均分配，n 太大时单个分片可能放不下较大的值
+func WithCacheShards(n int) GroupOption {
+	return func(g *Group) {
+		if n <= 0 || n&(n-1) != 0 {
+			panic("cache shard count must be a positive power of two")
+		}
+		g.cacheShards = n
+	}
+}
+
+// WithReadOnlyReplica 把分组配置为只读副本：只从缓存和远程节点（包括备用集群）获取值，从不调用 getter，
+// 适合部署在不能访问数据源的节点上。缓存没命中、远程节点也没能提供值时返回 ErrNoBackingStore，Put 同样返回 ErrNoBackingStore
+// 副本不应该出现在其它节点的哈希环上，否则属于它的 key 在整个集群中都无法加载；NewGroup 仍然需要传入一个 getter，它不会被调用
+func WithReadOnlyReplica() GroupOption {
+	return func(g *Group) {
+		g.readOnly = true
+	}
+}
+
+// WithStaleOnBackpressure 让过期的值在缓存中继续保留 grace，这段时间内如果加载被背压拒绝（如 ErrRateLimited），
+// 返回这个旧值而不是错误；优先级为：未过期的值 > 背压时的旧值 > 错误。旧值不会在其它情况下返回
+// 保留的旧值仍然占用缓存容量，需要与 WithTTL 一起使用
+func WithStaleOnBackpressure(grace time.Duration) GroupOption {
+	return func(g *Group) {
+		g.mainCache.staleGrace = grace
+		g.hotCache.staleGrace = grace
+	}
+}
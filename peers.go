@@ -1,18 +1,83 @@
 package mini_groupcache
 
-import "mini-groupcache/testpb"
+import (
+	"context"
+	"mini-groupcache/testpb"
+)
 
 type PeerGetter interface {
 	// Get 从 group 中查找缓存值
 	// Get(group string, key string) ([]byte, error)
-	
-	// 使用 protobuf 节点之间通信
-	Get(in *testpb.Request, out *testpb.Response) error
+
+	// 使用 protobuf 节点之间通信，ctx 用于取消请求并携带请求已经转发的跳数
+	// 所属节点报告 key 不存在时返回的错误需要能用 errors.Is 判断为 ErrNotFound，分组会直接把它返回给调用方，不再回退
+	Get(ctx context.Context, in *testpb.Request, out *testpb.Response) error
+}
+
+// BatchPeerGetter 是支持一次获取多个 key 的 PeerGetter，Group.GetMulti 会优先使用它减少请求次数
+type BatchPeerGetter interface {
+	PeerGetter
+
+	// GetBatch 获取 in.Keys 对应的缓存值，out.Responses 与 in.Keys 一一对应，
+	// 单个 key 获取失败时对应 Response 的 error 字段不为空，不影响其它 key；key 不存在时同时设置 not_found
+	GetBatch(ctx context.Context, in *testpb.BatchRequest, out *testpb.BatchResponse) error
 }
 
-type PeerPicker interface { 
+// PeerRemover 是支持删除所属节点上缓存值的 PeerGetter，Group.RemoveAndVerify 使用它同步删除
+type PeerRemover interface {
+	PeerGetter
+
+	// Remove 删除节点上 in.Group 分组中 in.Key 的缓存值，返回 nil 表示节点已经删除
+	Remove(ctx context.Context, in *testpb.DeleteRequest) error
+}
+
+// PeerPrefixRemover 是支持按前缀删除缓存值的 PeerGetter，Group.RemovePrefixEverywhere 使用它向其它节点广播
+type PeerPrefixRemover interface {
+	PeerGetter
+
+	// RemovePrefix 删除节点上 in.Group 分组中所有以 in.Key 开头的 key，只在该节点本地删除，不会继续广播
+	RemovePrefix(ctx context.Context, in *testpb.DeleteRequest) error
+}
+
+// PeerIncrementer 是支持在所属节点上原子地增加计数器的 PeerGetter，Group.Increment 使用它
+type PeerIncrementer interface {
+	PeerGetter
+
+	// Increment 给节点上 in.Group 分组中 in.Key 的计数器加上 in.Delta，out.Value 为相加之后的十进制值
+	Increment(ctx context.Context, in *testpb.IncrementRequest, out *testpb.Response) error
+}
+
+type PeerPicker interface {
 	// PickPeer 根据给定的 key 选择对应的节点
 	PickPeer(key string) (peer PeerGetter, ok bool)
 }
 
+// HedgePeerPicker 是能给出 key 的第二选择节点的 PeerPicker，开启 WithHedging 的分组在所属节点响应慢时向它发起对冲请求
+type HedgePeerPicker interface {
+	PeerPicker
+
+	// PickSecondary 返回 key 的所属节点之后的下一个节点，没有这样的节点或者它是本节点时 ok 为 false
+	// 收到对冲请求的节点应当直接在本地加载，而不是再转发给所属节点
+	PickSecondary(key string) (peer PeerGetter, ok bool)
+}
+
+// ownedKeysPicker 是能按同一个哈希环一次筛选出当前节点负责的 key 的 PeerPicker，如 HTTPPool
+type ownedKeysPicker interface {
+	PeerPicker
+	OwnedKeys(keys []string) []string
+}
+
+// peerLister 是能列出除本节点之外所有节点的 PeerPicker（如 HTTPPool），用于向整个集群广播
+type peerLister interface {
+	PeerPicker
+	Peers() []PeerGetter
+}
 
+// NoopPeerPicker 是只有本节点的 PeerPicker，PickPeer 总是返回 false，所有 key 都由本节点加载
+// 单节点部署和测试中注册它，可以与多节点部署走同样的代码路径，而不需要区分是否调用过 RegisterPeers
+type NoopPeerPicker struct{}
+
+// PickPeer 实现 PeerPicker 接口
+func (NoopPeerPicker) PickPeer(key string) (PeerGetter, bool) {
+	return nil, false
+}
@@ -0,0 +1,79 @@
+package mini_groupcache
+
+import (
+	"context"
+	"fmt"
+	"mini-groupcache/testpb"
+)
+
+// KeysWithPrefix 返回当前节点缓存（包括 mainCache 和 hotCache）中所有以 prefix 开头的 key，prefix 为空时返回所有 key
+// 缓存没有按前缀建立索引，每次调用都要遍历整个缓存并在遍历期间持有缓存的锁，复杂度为 O(n)，
+// 只适合管理工具偶尔调用，不要在请求路径上使用
+// 设置了 WithKeyNormalizer 时 prefix 先经过同样的规范化再与缓存中规范化之后的 key 比较，
+// 这要求规范化保持前缀关系（如统一大小写、去掉固定的命名空间），哈希之类的规范化无法按前缀匹配
+func (g *Group) KeysWithPrefix(prefix string) []string {
+	prefix = g.normalize(prefix)
+	keys := g.mainCache.keysWithPrefix(prefix)
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		seen[key] = true
+	}
+	for _, key := range g.hotCache.keysWithPrefix(prefix) {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// RemovePrefix 从当前节点的缓存中删除所有以 prefix 开头的 key，返回删除的 key 数，prefix 的规范化与 KeysWithPrefix 相同
+// prefix 为空（或规范化之后为空）时什么也不做，避免误操作清空整个缓存，需要清空时使用 Clear
+// 与 KeysWithPrefix 一样是 O(n) 的遍历，只适合管理操作；只影响当前节点，需要删除整个集群上的值时使用 RemovePrefixEverywhere
+func (g *Group) RemovePrefix(prefix string) int {
+	prefix = g.normalize(prefix)
+	if prefix == "" {
+		return 0
+	}
+	removed := make(map[string]bool)
+	for _, c := range []*shardedCache{&g.mainCache, &g.hotCache} {
+		for _, key := range c.removePrefix(prefix) {
+			removed[key] = true
+		}
+	}
+	for key := range removed {
+		g.forgetLoads(key)
+	}
+	return len(removed)
+}
+
+// RemovePrefixEverywhere 在当前节点上执行 RemovePrefix，再通知其它所有节点各自删除，返回当前节点删除的 key 数
+// 需要注册的 PeerPicker 能列出所有节点（如 HTTPPool），且节点支持按前缀删除（实现了 PeerPrefixRemover），否则返回错误；
+// 某个节点失败时继续通知其余节点，返回第一个错误；prefix 为空时返回错误，不会清空任何节点
+func (g *Group) RemovePrefixEverywhere(ctx context.Context, prefix string) (int, error) {
+	if g.normalize(prefix) == "" {
+		return 0, fmt.Errorf("prefix is required")
+	}
+	n := g.RemovePrefix(prefix)
+	if g.peers == nil {
+		return n, nil
+	}
+	lister, ok := g.peers.(peerLister)
+	if !ok {
+		return n, fmt.Errorf("peer picker cannot list peers to broadcast to")
+	}
+
+	var first error
+	for _, peer := range lister.Peers() {
+		remover, ok := peer.(PeerPrefixRemover)
+		var err error
+		if !ok {
+			err = fmt.Errorf("peer %s does not support removing by prefix", peerName(peer))
+		} else if err = remover.RemovePrefix(ctx, &testpb.DeleteRequest{Group: g.name, Key: prefix}); err != nil {
+			err = fmt.Errorf("removing prefix %q on peer %s: %w", prefix, peerName(peer), err)
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return n, first
+}
@@ -0,0 +1,141 @@
+package mini_groupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBackpressure 表示分组为了保护数据源拒绝了这次加载，具体的原因（如 ErrRateLimited）会包装它
+	ErrBackpressure = errors.New("backpressure")
+	// ErrRateLimited 表示调用 getter 的速率超过了分组的限制
+	ErrRateLimited = fmt.Errorf("getter rate limited: %w", ErrBackpressure)
+)
+
+// RateLimitMode 决定令牌耗尽时的行为
+type RateLimitMode int
+
+const (
+	// RateLimitFailFast 令牌耗尽时立即返回 ErrRateLimited
+	RateLimitFailFast RateLimitMode = iota
+	// RateLimitWait 令牌耗尽时等待下一个令牌，最多等到 ctx 的截止时间，等不到时返回 ErrRateLimited
+	RateLimitWait
+)
+
+// tokenBucket 是一个令牌桶，以固定速率生成令牌，最多积攒 burst 个
+// 令牌数允许暂时为负，表示已经被等待中的调用方预定了未来的令牌
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒生成的令牌数
+	burst  float64 // 桶的容量
+	tokens float64
+	last   time.Time // 上一次补充令牌的时间
+	mode   RateLimitMode
+
+	rejects AtomicInt // 因令牌耗尽被拒绝的次数
+}
+
+func newTokenBucket(rate float64, burst int, mode RateLimitMode) *tokenBucket {
+	if rate <= 0 {
+		panic("rate limit must be positive")
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		mode:   mode,
+	}
+}
+
+// refill 按距离上次补充经过的时间补充令牌，调用方需要持有锁
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// reserve 预定一个令牌，返回需要等待多久才能使用它
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel 归还一个预定了但没有使用的令牌
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+}
+
+// allow 尝试取走一个令牌，令牌不足时不做任何修改并返回 false
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// available 返回当前可用的令牌数，有调用方在排队等待时为负数
+func (b *tokenBucket) available() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	return b.tokens
+}
+
+// wait 按 mode 获取一个令牌，获取不到时返回 ErrRateLimited
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b.mode == RateLimitFailFast {
+		if !b.allow(time.Now()) {
+			b.rejects.Add(1)
+			return ErrRateLimited
+		}
+		return nil
+	}
+
+	d := b.reserve(time.Now())
+	if d == 0 {
+		return nil
+	}
+	// 截止时间之前等不到令牌就不必再等
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		b.cancel()
+		b.rejects.Add(1)
+		return ErrRateLimited
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		b.rejects.Add(1)
+		return ErrRateLimited
+	}
+}
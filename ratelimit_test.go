@@ -0,0 +1,80 @@
+package mini_groupcache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGroup_RateLimit(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+
+	// 突发额度用完后立即失败
+	group := NewGroup("rate-fail-fast", 2<<10, getter, WithRateLimit(1, 2, RateLimitFailFast))
+	for i := 0; i < 2; i++ {
+		if _, err := group.Get(strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := group.Get("2"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want %v", err, ErrRateLimited)
+	}
+	if s := group.Stats(); s.RateLimitRejects != 1 || s.RateLimitTokens >= 1 {
+		t.Fatalf("rejects = %d, tokens = %f", s.RateLimitRejects, s.RateLimitTokens)
+	}
+
+	// 等待模式下在截止时间内能等到令牌就等待，等不到就立即失败
+	group = NewGroup("rate-wait", 2<<10, getter, WithRateLimit(50, 1, RateLimitWait))
+	if _, err := group.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if _, err := group.GetContext(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 10*time.Millisecond {
+		t.Fatalf("waited %v, want about 20ms for the next token", waited)
+	}
+	// 等待令牌的时间计入排队耗时，而不是 getter 耗时
+	if s := group.Stats(); s.QueueWaitMax < 10*time.Millisecond || s.QueueWaitAvg == 0 || s.GetterMax >= 10*time.Millisecond {
+		t.Fatalf("queue wait avg/max = %v/%v, getter max = %v", s.QueueWaitAvg, s.QueueWaitMax, s.GetterMax)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := group.GetContext(ctx, "c"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want %v", err, ErrRateLimited)
+	}
+}
+
+func TestGroup_StaleOnBackpressure(t *testing.T) {
+	version := 0
+	group := NewGroup("stale-on-backpressure", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		version++
+		return []byte(strconv.Itoa(version)), nil
+	}), WithTTL(10*time.Millisecond), WithStaleOnBackpressure(time.Minute), WithRateLimit(0.1, 1, RateLimitFailFast))
+
+	if v, err := group.Get("a"); err != nil || v.String() != "1" {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// 值已经过期，而令牌已经用完，返回旧值
+	if v, err := group.Get("a"); err != nil || v.String() != "1" {
+		t.Fatalf("Get = %q, %v, want the stale value", v, err)
+	}
+	if group.Stats().StaleHits != 1 {
+		t.Fatalf("stale hits = %d, want 1", group.Stats().StaleHits)
+	}
+
+	// 没有旧值时返回错误
+	if _, err := group.Get("b"); !errors.Is(err, ErrBackpressure) {
+		t.Fatalf("err = %v, want %v", err, ErrBackpressure)
+	}
+}
@@ -0,0 +1,66 @@
+package mini_groupcache
+
+import "sync/atomic"
+
+// readBufferSize 是 readBuffer 的槽位数，每记录这么多次读取尝试排空一次
+const readBufferSize = 64
+
+// readBuffer 记录只持有读锁的 cache.get 命中的条目，等到持有写锁时再统一交给 Store.Get 提升访问顺序（见 cache.getStale）
+// 记录是有损的：缓冲区满了之后、排空之前的读取不再记录，这些命中不会提升条目，LRU 的顺序因此只是近似的
+// record 在读锁之外执行，可能与 drain 同时进行，所以槽位的读写都用 Swap：每个被取出或者被覆盖的条目都会清除 pending，
+// 不会有条目一直停留在已记录的状态、之后再也不被记录
+type readBuffer struct {
+	n    uint32                       // 已经领取的槽位数，可能超过 readBufferSize，排空时清零
+	refs [readBufferSize]atomic.Value // 记录的条目（*readRef），空槽位为 (*readRef)(nil)
+}
+
+// readRef 是条目在 readBuffer 中的记录，写入缓存时为每个条目分配一个，记录时只保存指针，读取不需要分配内存
+type readRef struct {
+	key     string
+	pending uint32 // 为 1 表示在某个槽位中等待排空，同一个条目的后续读取不再重复记录
+}
+
+// record 记录一次读取，每领取 readBufferSize 个槽位返回一次 true，提示调用方尝试排空
+func (b *readBuffer) record(ref *readRef) bool {
+	// 先读再 CAS，热点条目在排空之前的读取只有一次原子读
+	if atomic.LoadUint32(&ref.pending) == 1 || !atomic.CompareAndSwapUint32(&ref.pending, 0, 1) {
+		return false
+	}
+	i := atomic.AddUint32(&b.n, 1) - 1
+	if i < readBufferSize {
+		// 槽位中可能还留着与排空同时写入、没有被取走的条目，它的这次命中丢弃，之后的读取可以重新记录
+		if old, _ := b.refs[i].Swap(ref).(*readRef); old != nil {
+			atomic.StoreUint32(&old.pending, 0)
+		}
+	} else {
+		// 缓冲区已满，丢弃这次记录，之后的读取可以再次尝试
+		atomic.StoreUint32(&ref.pending, 0)
+	}
+	return i%readBufferSize == readBufferSize-1
+}
+
+// drain 取出记录的条目依次对 key 调用 f 并清空缓冲区，调用方必须持有 cache 的写锁
+// 扫描期间有新的记录时重新扫描，把它们一起取出；n 每次重试都会增加，达到 readBufferSize 之后直接清零，所以最多重试 readBufferSize 次
+// 清零时还没写入槽位的记录留在槽位中，之后由下一次排空取出或者被新的记录覆盖，两种情况都会清除 pending
+func (b *readBuffer) drain(f func(key string)) {
+	for {
+		n := atomic.LoadUint32(&b.n)
+		if n == 0 {
+			return
+		}
+		// 扫描所有槽位，而不只是前 n 个，清零时留下的记录也会在下一次排空时取出
+		for i := range b.refs {
+			if ref, _ := b.refs[i].Swap((*readRef)(nil)).(*readRef); ref != nil {
+				atomic.StoreUint32(&ref.pending, 0)
+				f(ref.key)
+			}
+		}
+		if n >= readBufferSize {
+			atomic.StoreUint32(&b.n, 0)
+			return
+		}
+		if atomic.CompareAndSwapUint32(&b.n, n, 0) {
+			return
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package mini_groupcache
+
+import (
+	"mini-groupcache/lru"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCache_ReadBuffer(t *testing.T) {
+	c := &cache{cacheBytes: 2 * (1 + 1)}
+	c.add("a", cacheEntry{view: ByteView{b: []byte("a")}})
+	c.add("b", cacheEntry{view: ByteView{b: []byte("b")}})
+
+	// 只持有读锁的命中先记录下来，下一次写入之前提升 a，淘汰的是 b
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("cache miss a")
+	}
+	if n := atomic.LoadUint32(&c.reads.n); n != 1 {
+		t.Fatalf("recorded %d reads, want 1", n)
+	}
+	c.add("c", cacheEntry{view: ByteView{b: []byte("c")}})
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("a should have been promoted before the eviction")
+	}
+	if _, ok := c.get("b"); ok {
+		t.Fatal("b should have been evicted")
+	}
+
+	// 排空之前同一个条目只记录一次
+	c.get("c")
+	before := atomic.LoadUint32(&c.reads.n)
+	c.get("c")
+	if n := atomic.LoadUint32(&c.reads.n); n != before {
+		t.Fatalf("a second read of the same key was recorded again (%d -> %d)", before, n)
+	}
+
+	// 每记录 readBufferSize 次读取主动排空一次
+	c = &cache{cacheBytes: UnlimitedCacheBytes}
+	for i := 0; i < readBufferSize; i++ {
+		c.add(strconv.Itoa(i), cacheEntry{view: ByteView{b: []byte("v")}})
+	}
+	for i := 0; i < readBufferSize; i++ {
+		c.get(strconv.Itoa(i))
+	}
+	if n := atomic.LoadUint32(&c.reads.n); n != 0 {
+		t.Fatalf("%d reads left in a full buffer, want it drained", n)
+	}
+
+	// LFU 在写锁内查询，每次命中都计入访问次数
+	c = &cache{cacheBytes: UnlimitedCacheBytes, policy: lru.LFU}
+	c.add("a", cacheEntry{view: ByteView{b: []byte("a")}})
+	c.get("a")
+	if n := atomic.LoadUint32(&c.reads.n); n != 0 {
+		t.Fatalf("LFU cache recorded %d reads, want the exclusive path", n)
+	}
+}
+
+// exclusiveStore 隐藏 lru.Cache 的 Peek，cache 只能在写锁内查询，用来对比改用读写锁之前的行为
+type exclusiveStore struct {
+	Store
+}
+
+// BenchmarkCache_ParallelGet 对比查询持有写锁和只持有读锁时并发查询的吞吐，用 -cpu 指定并发度
+func BenchmarkCache_ParallelGet(b *testing.B) {
+	const nkeys = 1 << 12
+	keys := make([]string, nkeys)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		newStore StoreFunc
+	}{
+		{"exclusive", func(maxBytes int64, onEvicted func(string, lru.Value, lru.EvictionReason)) Store {
+			s := lru.NewCache(maxBytes, nil)
+			s.OnEvictedReason = onEvicted
+			return exclusiveStore{s}
+		}},
+		{"rwmutex", nil},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			c := &cache{cacheBytes: UnlimitedCacheBytes, newStore: tc.newStore}
+			for _, key := range keys {
+				c.add(key, cacheEntry{view: ByteView{b: make([]byte, 64)}})
+			}
+			var seq uint32
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(atomic.AddUint32(&seq, 1)) * 7919
+				for pb.Next() {
+					c.get(keys[i%nkeys])
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestReadBuffer_RecordDuringDrain(t *testing.T) {
+	var b readBuffer
+	drained := make(map[string]int)
+	collect := func(key string) { drained[key]++ }
+
+	// 排空的过程中（读取 n 之后、清零之前）记录 hot，模拟读锁之外的记录与持有写锁的排空同时进行
+	a, hot := &readRef{key: "a"}, &readRef{key: "hot"}
+	b.record(a)
+	b.drain(func(key string) {
+		collect(key)
+		if key == "a" {
+			b.record(hot)
+		}
+	})
+	if drained["hot"] != 1 || atomic.LoadUint32(&hot.pending) != 0 {
+		t.Fatalf("hot drained %d times, pending = %d", drained["hot"], hot.pending)
+	}
+
+	// 领取了槽位、但在清零之后才写入的记录留在槽位中，下一次排空仍然会取出它
+	atomic.StoreUint32(&hot.pending, 1)
+	b.refs[5].Store(hot)
+	for round := 0; round < 3; round++ {
+		b.record(&readRef{key: strconv.Itoa(round)})
+		b.drain(collect)
+		if atomic.LoadUint32(&hot.pending) == 0 {
+			break
+		}
+	}
+	if drained["hot"] != 2 {
+		t.Fatal("a record left in its slot after the reset was never drained")
+	}
+	if !b.record(hot) && atomic.LoadUint32(&hot.pending) != 1 {
+		t.Fatal("hot can no longer be recorded")
+	}
+}
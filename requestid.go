@@ -0,0 +1,36 @@
+package mini_groupcache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type requestIDKey struct{}
+
+// ContextWithRequestID 返回携带请求 ID 的 ctx，API 层可以用它传入自己的请求 ID（如从入口的 HTTP 头中读到的）
+// 请求转发给其它节点时以 X-Groupcache-Request-Id 头发送，每个经过的节点都在访问日志中打印它，用来关联多个节点上的日志
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext 返回 ctx 中的请求 ID，没有时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ensureRequestID 在 ctx 没有请求 ID 时生成一个，用于请求的入口（GetContext、收到没有带 ID 的节点请求等）
+func ensureRequestID(ctx context.Context) context.Context {
+	if RequestIDFromContext(ctx) != "" {
+		return ctx
+	}
+	return ContextWithRequestID(ctx, newRequestID())
+}
+
+// newRequestID 生成一个 16 个十六进制字符的随机请求 ID
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
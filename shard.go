@@ -0,0 +1,242 @@
+package mini_groupcache
+
+import "runtime"
+
+// minShardBytes 是默认分片数下每个分片至少分到的容量，小缓存不再细分，避免单个分片小到放不下一个值
+const minShardBytes = 1 << 20
+
+// shardedCache 把 key 按哈希分散到多个各自加锁的 cache 上，并发的读写只在落到同一个分片时才会互相等待
+// 嵌入的 cache 是第一个分片，选项直接设置在它上面；NewGroup 应用完所有选项之后调用 split，按它的配置创建其它分片
+// 只有一个分片时与单个 cache 完全相同。容量、固定条目的上限都按分片平均分配，淘汰在每个分片内独立进行，
+// 因此多个分片时淘汰顺序只在分片内准确；WithOnEvicted 的同步回调可能被不同的分片并发调用
+type shardedCache struct {
+	cache
+	shards []*cache
+	mask   uint32
+}
+
+// defaultShards 返回容量为 cacheBytes 时默认的分片数：GOMAXPROCS 向上取到 2 的幂，
+// 但有容量限制时每个分片不少于 minShardBytes，关闭缓存时只有一个分片
+func defaultShards(cacheBytes int64) int {
+	if cacheBytes == 0 {
+		return 1
+	}
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	for n > 1 && cacheBytes > 0 && cacheBytes/int64(n) < minShardBytes {
+		n >>= 1
+	}
+	return n
+}
+
+// split 把缓存分成 n 个分片，n 为 0 时使用 defaultShards，只能在第一次使用缓存之前调用一次
+func (s *shardedCache) split(n int) {
+	if n == 0 {
+		n = defaultShards(s.cacheBytes)
+	}
+	total := s.cacheBytes
+	s.shards = make([]*cache, n)
+	s.shards[0] = &s.cache
+	for i := 1; i < n; i++ {
+		c := &cache{
+			staleGrace:       s.staleGrace,
+			pinOverflow:      s.pinOverflow,
+			onEvict:          s.onEvict,
+			expiryCandidates: s.expiryCandidates,
+			policy:           s.policy,
+			newStore:         s.newStore,
+		}
+		if s.arena != nil {
+			// arena 不是并发安全的，每个分片使用自己的
+			c.arena = newArena(s.arena.threshold, s.arena.slabSize)
+		}
+		s.shards[i] = c
+	}
+	for i, c := range s.shards {
+		c.cacheBytes = shardBytes(total, n, i)
+	}
+	s.mask = uint32(n - 1)
+}
+
+// shardBytes 返回总容量 total 分成 n 份后第 i 个分片的容量，余数分给前面的分片，关闭和不限制时与 total 相同
+func shardBytes(total int64, n, i int) int64 {
+	if total <= 0 {
+		return total
+	}
+	b := total / int64(n)
+	if int64(i) < total%int64(n) {
+		b++
+	}
+	return b
+}
+
+// shard 返回 key 所在的分片
+// 使用 FNV-1a 而不是哈希环的 crc32，同一个节点上的 key 在哈希环上是聚集的，用同一个哈希会让它们挤在少数几个分片里
+func (s *shardedCache) shard(key string) *cache {
+	if s.mask == 0 {
+		return &s.cache
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return s.shards[h&s.mask]
+}
+
+func (s *shardedCache) add(key string, e cacheEntry) error {
+	return s.shard(key).add(key, e)
+}
+
+func (s *shardedCache) get(key string) (ByteView, bool) {
+	return s.shard(key).get(key)
+}
+
+func (s *shardedCache) getEntry(key string) (cacheEntry, bool) {
+	return s.shard(key).getEntry(key)
+}
+
+func (s *shardedCache) getStale(key string) (cacheEntry, bool) {
+	return s.shard(key).getStale(key)
+}
+
+func (s *shardedCache) remove(key string) bool {
+	return s.shard(key).remove(key)
+}
+
+func (s *shardedCache) pin(key string) (bool, error) {
+	return s.shard(key).pin(key)
+}
+
+func (s *shardedCache) unpin(key string) {
+	s.shard(key).unpin(key)
+}
+
+// budget 返回所有分片的总容量，取值与 cacheBytes 相同
+func (s *shardedCache) budget() int64 {
+	var total int64
+	for _, c := range s.shards {
+		b := c.budget()
+		if b <= 0 {
+			return b
+		}
+		total += b
+	}
+	return total
+}
+
+// resize 把总容量改为 cacheBytes 并平均分配到每个分片，分片依次各自加锁调整，分片数不变
+func (s *shardedCache) resize(cacheBytes int64) {
+	for i, c := range s.shards {
+		c.resize(shardBytes(cacheBytes, len(s.shards), i))
+	}
+}
+
+func (s *shardedCache) clear() {
+	for _, c := range s.shards {
+		c.clear()
+	}
+}
+
+// removeOldest 从当前占用最多的分片中淘汰一个条目
+func (s *shardedCache) removeOldest() {
+	var max *cache
+	var maxBytes int64 = -1
+	for _, c := range s.shards {
+		if b := c.stats().bytes; b > maxBytes {
+			max, maxBytes = c, b
+		}
+	}
+	max.removeOldest()
+}
+
+func (s *shardedCache) resetStats() {
+	for _, c := range s.shards {
+		c.resetStats()
+	}
+}
+
+// stats 汇总所有分片的统计信息，分片依次各自加锁，不是同一时刻的快照
+func (s *shardedCache) stats() cacheStats {
+	var st cacheStats
+	for _, c := range s.shards {
+		cs := c.stats()
+		st.bytes += cs.bytes
+		st.items += cs.items
+		st.overhead += cs.overhead
+		st.evictions += cs.evictions
+		st.newKeyRate += cs.newKeyRate
+		st.evictionRate += cs.evictionRate
+		st.arenaBytes += cs.arenaBytes
+	}
+	return st
+}
+
+// accessHistogram 按桶累加所有分片的命中次数分布
+func (s *shardedCache) accessHistogram() []int {
+	var hist []int
+	for _, c := range s.shards {
+		for b, n := range c.accessHistogram() {
+			for len(hist) <= b {
+				hist = append(hist, 0)
+			}
+			hist[b] += n
+		}
+	}
+	return hist
+}
+
+// oldestN 从每个分片取出最接近被淘汰的 n 个 key，再按分片轮流合并成 n 个
+// 不同分片之间没有统一的访问顺序，多个分片时结果只是近似的淘汰顺序
+func (s *shardedCache) oldestN(n int) []string {
+	if len(s.shards) == 1 {
+		return s.cache.oldestN(n)
+	}
+	lists := make([][]string, len(s.shards))
+	for i, c := range s.shards {
+		lists[i] = c.oldestN(n)
+	}
+	var keys []string
+	for i := 0; len(keys) < n; i++ {
+		added := false
+		for _, l := range lists {
+			if i < len(l) && len(keys) < n {
+				keys = append(keys, l[i])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return keys
+}
+
+// keysWithPrefix 返回所有分片中以 prefix 开头的 key，每个分片内从最近访问的开始
+func (s *shardedCache) keysWithPrefix(prefix string) []string {
+	var keys []string
+	for _, c := range s.shards {
+		keys = append(keys, c.keysWithPrefix(prefix)...)
+	}
+	return keys
+}
+
+// removePrefix 删除所有分片中以 prefix 开头的 key，每个分片在一次加锁内删除，返回删除的 key
+func (s *shardedCache) removePrefix(prefix string) []string {
+	var keys []string
+	for _, c := range s.shards {
+		keys = append(keys, c.removePrefix(prefix)...)
+	}
+	return keys
+}
+
+// entries 返回所有分片中条目的快照，每个分片内从最近访问的开始
+func (s *shardedCache) entries() []keyedEntry {
+	var entries []keyedEntry
+	for _, c := range s.shards {
+		entries = append(entries, c.entries()...)
+	}
+	return entries
+}
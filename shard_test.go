@@ -0,0 +1,96 @@
+package mini_groupcache
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGroup_CacheShards(t *testing.T) {
+	group := NewGroup("shards", 4<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithCacheShards(4))
+	if n := len(group.mainCache.shards); n != 4 {
+		t.Fatalf("got %d shards, want 4", n)
+	}
+	if b := group.mainCache.budget(); b != 4<<10 {
+		t.Fatalf("budget = %d, want %d", b, 4<<10)
+	}
+
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		if v, err := group.Get(key); err != nil || v.String() != key {
+			t.Fatalf("Get(%s) = %q, %v", key, v, err)
+		}
+	}
+	// key 分散到了每个分片上，统计信息是所有分片之和
+	var items int64
+	for _, c := range group.mainCache.shards {
+		n := c.stats().items
+		if n == 0 {
+			t.Fatal("a shard received no keys")
+		}
+		items += n
+	}
+	if s := group.mainCache.stats(); s.items != items || s.items != 100 {
+		t.Fatalf("stats.items = %d, shards hold %d, want 100", s.items, items)
+	}
+	if n := len(group.mainCache.entries()); n != 100 {
+		t.Fatalf("got %d entries, want 100", n)
+	}
+
+	group.SetCacheBytes(400)
+	for _, c := range group.mainCache.shards {
+		if b := c.stats().bytes; b > 100 {
+			t.Fatalf("shard holds %d bytes after resize, want at most 100", b)
+		}
+	}
+}
+
+func TestDefaultShards(t *testing.T) {
+	if n := defaultShards(0); n != 1 {
+		t.Fatalf("defaultShards(0) = %d, want 1", n)
+	}
+	// 小缓存不分片
+	if n := defaultShards(minShardBytes); n != 1 {
+		t.Fatalf("defaultShards(%d) = %d, want 1", minShardBytes, n)
+	}
+	if n := defaultShards(UnlimitedCacheBytes); n&(n-1) != 0 {
+		t.Fatalf("defaultShards = %d, want a power of two", n)
+	}
+}
+
+// BenchmarkCache_Parallel 对比单个锁和分片的缓存在并发读写下的吞吐，用 -cpu 指定并发度
+func BenchmarkCache_Parallel(b *testing.B) {
+	const nkeys = 1 << 12
+	keys := make([]string, nkeys)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	value := ByteView{b: make([]byte, 64)}
+
+	for _, n := range []int{1, 16} {
+		b.Run("shards="+strconv.Itoa(n), func(b *testing.B) {
+			c := &shardedCache{cache: cache{cacheBytes: UnlimitedCacheBytes}}
+			c.split(n)
+			for _, key := range keys {
+				c.add(key, cacheEntry{view: value})
+			}
+			var seq uint32
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(atomic.AddUint32(&seq, 1)) * 7919
+				for pb.Next() {
+					key := keys[i%nkeys]
+					// 每 8 次操作有一次写入
+					if i%8 == 0 {
+						c.add(key, cacheEntry{view: value})
+					} else {
+						c.get(key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
@@ -1,6 +1,14 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 /**
 缓存雪崩：缓存在同一时刻全部失效，造成瞬时DB请求量大、压力骤增，引起雪崩。缓存雪崩通常因为缓存服务器宕机、缓存的 key 设置了相同的过期时间等引起。
@@ -20,53 +28,246 @@ type call struct {
 
 // Group 防穿透的主要结构，每个分组对应一个，这样就只限制了这个分组的请求
 type Group struct {
-	mu sync.Mutex
-	m  map[string]*call // 存储对每个 key 的请求
+	mu    sync.Mutex
+	m     map[string]*call    // 存储对每个 key 的请求
+	locks map[string]*keyLock // 存储每个 key 上的互斥锁，供 WithLock 使用
+
+	// 请求成功后结果在 recent 中保留 window，期间到达的调用直接拿到这个结果，见 Retain
+	window       time.Duration
+	maxRetained  int
+	recent       map[string]retained
+	retainedHits int64
+}
+
+// retained 是请求完成之后保留的结果
+type retained struct {
+	val    any
+	expire time.Time
+}
+
+// keyLock 某个 key 上的互斥锁，refs 记录正在持有或等待这把锁的调用数，归零时从 map 中删除
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// ErrWaitTimeout 表示调用方在请求完成之前放弃了等待
+var ErrWaitTimeout = errors.New("singleflight: gave up waiting for in-flight call")
+
+// PanicError 是实际请求 panic 时等待的调用方收到的错误，Value 是 panic 的值，Stack 是 panic 时的调用栈
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("singleflight: fn panicked: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Result 是 DoChan 返回的请求结果
+type Result struct {
+	Val any
+	Err error
 }
 
 // Do 请求进入
+// fn panic 时，等待的调用方收到 *PanicError，key 随之被清理，之后的请求会重新执行 fn；
+// 执行 fn 的调用方在唤醒其它调用方之后继续以原来的值 panic
 func (g *Group) Do(key string, fn func() (any, error)) (any, error) {
+	c, leader := g.join(key)
+	if !leader {
+		// 等待这个执行单元的 waitGroup 计数器变成 0 即请求完成信号
+		// 执行单元请求完成后会将计数器减 1，并已经准备好了请求的返回结果
+		c.wg.Wait()
+		// 直接将结果返回即可
+		return c.val, c.err
+	}
+
+	if pe := g.doCall(c, key, fn); pe != nil {
+		panic(pe.Value)
+	}
+	// 最后将实际请求的值返回
+	return c.val, c.err
+}
+
+// DoChan 与 Do 相同，但不阻塞调用方，请求完成后从返回的 channel 中读出结果
+// 实际请求在新的 goroutine 中执行，fn panic 时不会再次 panic（那样会使整个进程退出），所有调用方都收到 *PanicError
+func (g *Group) DoChan(key string, fn func() (any, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	c, leader := g.join(key)
+	go func() {
+		if leader {
+			g.doCall(c, key, fn)
+		} else {
+			c.wg.Wait()
+		}
+		ch <- Result{Val: c.val, Err: c.err}
+	}()
+	return ch
+}
+
+// DoContext 与 Do 相同，但调用方最多等到 ctx 结束，之后放弃等待并返回 ErrWaitTimeout
+// 放弃等待只影响当前调用方：实际的请求会在后台继续执行，其它仍在等待的调用方照常拿到结果，
+// 即使放弃等待的正是最先到达、发起实际请求的那个调用方
+func (g *Group) DoContext(ctx context.Context, key string, fn func() (any, error)) (any, error) {
+	// ctx 永远不会结束时，不必为等待额外启动 goroutine
+	if ctx.Done() == nil {
+		return g.Do(key, fn)
+	}
+
+	select {
+	case res := <-g.DoChan(key, fn):
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ErrWaitTimeout
+	}
+}
+
+// Retain 让成功的请求结果在完成之后再保留 window，这段时间内同一个 key 上的 Do、DoChan、DoContext 直接拿到这个结果而不执行 fn，
+// 这样时间上没有重叠、但间隔极短的请求也会被合并，适合加载很便宜但每毫秒被请求成百上千次的热点 key
+// 返回的结果最多比实际的数据旧 window；失败的请求不会保留。最多保留 maxKeys 个 key 的结果，已满时新的结果不再保留
+// window <= 0 时关闭保留并丢弃已保留的结果。Retain 应在使用 Group 之前调用
+func (g *Group) Retain(window time.Duration, maxKeys int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if window <= 0 || maxKeys <= 0 {
+		g.window, g.maxRetained, g.recent = 0, 0, nil
+		return
+	}
+	g.window, g.maxRetained = window, maxKeys
+	g.recent = make(map[string]retained)
+}
+
+// Forget 丢弃 key 上保留的结果，之后的调用会重新执行 fn；正在执行的请求不受影响
+func (g *Group) Forget(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.recent, key)
+}
+
+// ForgetAll 丢弃所有 key 上保留的结果
+func (g *Group) ForgetAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.recent != nil {
+		g.recent = make(map[string]retained)
+	}
+}
+
+// RetainedHits 返回直接拿到保留结果、没有执行也没有等待 fn 的调用次数
+func (g *Group) RetainedHits() int64 {
+	return atomic.LoadInt64(&g.retainedHits)
+}
+
+// join 查找 key 上正在执行的请求，没有时创建一个新的执行单元，leader 为 true 表示由调用方负责执行实际请求
+func (g *Group) join(key string) (c *call, leader bool) {
 	g.mu.Lock()
 	/* ----- 临界区 ----- */
+	defer g.mu.Unlock()
+
+	// 有未过期的保留结果时，返回一个已经完成的执行单元
+	if r, ok := g.recent[key]; ok {
+		if time.Now().Before(r.expire) {
+			atomic.AddInt64(&g.retainedHits, 1)
+			return &call{val: r.val}, false
+		}
+		delete(g.recent, key)
+	}
 
 	// 第一个到达的请求初始化 map
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
 
-	// 第一个到达的请求直接跳过
+	// 非第一个到达的请求，可以从该 group 的 map 中直接取出第一个到达过的执行单元
 	if c, ok := g.m[key]; ok {
-		// 非第一个到达的请求，可以从该 group 的 map 中直接取出第一个到达过的执行单元，并释放互斥锁
-		g.mu.Unlock()
-		// 等待这个执行单元的 waitGroup 计数器变成 0 即请求完成信号
-		// 执行单元请求完成后会将计数器减 1，并已经准备好了请求的返回结果
-		c.wg.Wait()
-		// 直接将结果返回即可
-		return c.val, c.err
+		return c, false
 	}
 
 	// 实例化一个真正的执行单位，为其分配内存以保存请求的返回值
-	c := new(call)
+	c = new(call)
 	// 执行单元的 waitGroup 计数器加 1，并存储该 key 和执行单元，表示有一个 goroutine 拿到了实际的请求权
 	c.wg.Add(1)
 	g.m[key] = c
-
 	/* ----- 临界区 ----- */
-	g.mu.Unlock()
+	return c, true
+}
+
+// doCall 执行实际请求，完成后唤醒所有等待的调用方
+// fn panic 时 recover 并返回 *PanicError（同时作为 c.err 交给等待的调用方），由调用方决定是否继续 panic
+// 唤醒和清理放在 defer 中，无论 fn 如何结束都不会让等待的调用方永远阻塞、让 key 残留在 map 中
+func (g *Group) doCall(c *call, key string, fn func() (any, error)) (pe *PanicError) {
+	defer func() {
+		if r := recover(); r != nil {
+			pe = &PanicError{Value: r, Stack: debug.Stack()}
+			c.val, c.err = nil, pe
+		}
+		// 请求完成后，waitGroup 计数器减 1，表示已经完成了对一个 key 的请求
+		c.wg.Done()
+
+		// 请求完成后，删除 map 中的 key 表示对这个 key 的一次请求完成了
+		g.mu.Lock()
+		delete(g.m, key)
+		if pe == nil && c.err == nil {
+			g.retain(key, c.val)
+		}
+		g.mu.Unlock()
+		// 如果在出了 delete 的临界区之后返回值之前，再有请求进来，那么又会进入上面的流程中
+		// 但不会影响这个请求最终的返回结果，因为执行单元 c 属于这个 goroutine 的局部变量
+	}()
 
 	// 开始执行实际请求
 	// 请求的结果保存在这个 key 的实际执行单元中
 	c.val, c.err = fn()
-	// 请求完成后，waitGroup 计数器减 1，表示已经完成了对一个 key 的请求
-	c.wg.Done()
+	return nil
+}
+
+// retain 保留 key 的结果，调用方持有 g.mu；已满时先清理过期的结果，仍然没有空位就不保留
+func (g *Group) retain(key string, val any) {
+	if g.window <= 0 {
+		return
+	}
+	now := time.Now()
+	if _, ok := g.recent[key]; !ok && len(g.recent) >= g.maxRetained {
+		for k, r := range g.recent {
+			if !now.Before(r.expire) {
+				delete(g.recent, k)
+			}
+		}
+		if len(g.recent) >= g.maxRetained {
+			return
+		}
+	}
+	g.recent[key] = retained{val: val, expire: now.Add(g.window)}
+}
 
-	// 请求完成后，删除 map 中的 key 表示对这个 key 的一次请求完成了
+// WithLock 在 key 的互斥锁保护下执行 fn
+// 与 Do 不同，同一个 key 上并发的多次调用不会被合并，而是逐个执行，适合“读-改-写”这类需要临界区的操作
+func (g *Group) WithLock(key string, fn func() error) error {
 	g.mu.Lock()
-	delete(g.m, key)
+	if g.locks == nil {
+		g.locks = make(map[string]*keyLock)
+	}
+	l, ok := g.locks[key]
+	if !ok {
+		l = new(keyLock)
+		g.locks[key] = l
+	}
+	l.refs++
 	g.mu.Unlock()
-	// 如果在出了 delete 的临界区之后返回值之前，再有请求进来，那么又会进入上面的流程中
-	// 但不会影响这个请求最终的返回结果，因为执行单元 c 属于这个 goroutine 的局部变量
-	
-	// 最后将实际请求的值返回
-	return c.val, c.err
+
+	l.mu.Lock()
+	defer func() {
+		l.mu.Unlock()
+
+		// 最后一个使用者负责回收这把锁，避免 map 随着 key 的数量无限增长
+		g.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(g.locks, key)
+		}
+		g.mu.Unlock()
+	}()
+
+	return fn()
 }
@@ -0,0 +1,146 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroup_WithLock(t *testing.T) {
+	var g Group
+	var wg sync.WaitGroup
+	running, calls := 0, 0
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.WithLock("key", func() error {
+				running++
+				if running != 1 {
+					t.Errorf("%d calls are running at the same time", running)
+				}
+				time.Sleep(time.Millisecond)
+				calls++
+				running--
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	// 与 Do 不同，每一次调用都应该被执行
+	if calls != 10 {
+		t.Fatalf("fn was called %d times, want 10", calls)
+	}
+	if len(g.locks) != 0 {
+		t.Fatalf("locks should be released, got %d left", len(g.locks))
+	}
+}
+
+func TestGroup_DoContext(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (any, error) {
+		<-release
+		return "value", nil
+	}
+
+	// 发起实际请求的调用方放弃等待
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.DoContext(ctx, "key", fn); err != ErrWaitTimeout {
+		t.Fatalf("err = %v, want %v", err, ErrWaitTimeout)
+	}
+
+	// 实际请求没有被取消，后来的调用方仍然合并到这次请求上并拿到结果
+	ch := g.DoChan("key", func() (any, error) {
+		t.Error("the in-flight call should be shared")
+		return nil, nil
+	})
+	close(release)
+	if res := <-ch; res.Err != nil || res.Val != "value" {
+		t.Fatalf("DoChan = %v, %v", res.Val, res.Err)
+	}
+}
+
+func TestGroup_DoPanic(t *testing.T) {
+	var g Group
+	entered := make(chan struct{})
+	fn := func() (any, error) {
+		close(entered)
+		time.Sleep(10 * time.Millisecond)
+		panic("boom")
+	}
+
+	// 执行 fn 的调用方继续以原来的值 panic
+	leader := make(chan any)
+	go func() {
+		defer func() { leader <- recover() }()
+		g.Do("key", fn)
+	}()
+
+	// 等待的调用方被唤醒并收到 *PanicError
+	<-entered
+	_, err := g.Do("key", func() (any, error) {
+		t.Error("the in-flight call should be shared")
+		return nil, nil
+	})
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("waiter err = %v, want a PanicError with value boom", err)
+	}
+	if r := <-leader; r != "boom" {
+		t.Fatalf("leader recovered %v, want boom", r)
+	}
+
+	// key 已经被清理，之后的请求重新执行 fn
+	if len(g.m) != 0 {
+		t.Fatalf("%d calls left in the map", len(g.m))
+	}
+	if v, err := g.Do("key", func() (any, error) { return "value", nil }); err != nil || v != "value" {
+		t.Fatalf("Do = %v, %v", v, err)
+	}
+
+	// DoChan 不会再次 panic，结果中带着 *PanicError
+	res := <-g.DoChan("key", func() (any, error) { panic("boom") })
+	if !errors.As(res.Err, &pe) {
+		t.Fatalf("DoChan err = %v, want a PanicError", res.Err)
+	}
+}
+
+func TestGroup_Retain(t *testing.T) {
+	var g Group
+	g.Retain(time.Hour, 1)
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return calls, nil
+	}
+
+	// 时间上没有重叠的调用拿到保留的结果
+	g.Do("a", fn)
+	if v, _ := g.Do("a", fn); v != 1 || calls != 1 || g.RetainedHits() != 1 {
+		t.Fatalf("v = %v, calls = %d, hits = %d", v, calls, g.RetainedHits())
+	}
+	// 已满时不再保留其它 key 的结果
+	g.Do("b", fn)
+	if v, _ := g.Do("b", fn); v != 3 {
+		t.Fatalf("b should not be retained, v = %v", v)
+	}
+	// 失败的结果不保留
+	g.Forget("a")
+	g.Do("a", func() (any, error) { return nil, errors.New("failed") })
+	if v, err := g.Do("a", fn); err != nil || v != 4 {
+		t.Fatalf("v = %v, err = %v", v, err)
+	}
+
+	g.Retain(time.Millisecond, 1)
+	g.Do("c", fn)
+	time.Sleep(2 * time.Millisecond)
+	if v, _ := g.Do("c", fn); v != 6 {
+		t.Fatalf("expired result should not be used, v = %v", v)
+	}
+}
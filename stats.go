@@ -0,0 +1,397 @@
+package mini_groupcache
+
+import (
+	"fmt"
+	"math"
+	"mini-groupcache/workerpool"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// AtomicInt 并发安全的 int64 计数器
+type AtomicInt int64
+
+// Add 原子地累加 n
+func (i *AtomicInt) Add(n int64) {
+	atomic.AddInt64((*int64)(i), n)
+}
+
+// Get 原子地读取当前值
+func (i *AtomicInt) Get() int64 {
+	return atomic.LoadInt64((*int64)(i))
+}
+
+func (i *AtomicInt) String() string {
+	return strconv.FormatInt(i.Get(), 10)
+}
+
+// reset 原子地将计数器清零
+func (i *AtomicInt) reset() {
+	i.store(0)
+}
+
+// store 原子地将计数器设置为 n
+func (i *AtomicInt) store(n int64) {
+	atomic.StoreInt64((*int64)(i), n)
+}
+
+// durationStats 累计某类耗时的次数、总和与最大值
+type durationStats struct {
+	count AtomicInt
+	total AtomicInt // 纳秒
+	max   AtomicInt // 纳秒
+}
+
+// observe 记录一次耗时
+func (d *durationStats) observe(v time.Duration) {
+	d.count.Add(1)
+	d.total.Add(int64(v))
+	for {
+		old := d.max.Get()
+		if int64(v) <= old || atomic.CompareAndSwapInt64((*int64)(&d.max), old, int64(v)) {
+			return
+		}
+	}
+}
+
+// avg 返回平均耗时，还没有记录时返回 0
+func (d *durationStats) avg() time.Duration {
+	n := d.count.Get()
+	if n == 0 {
+		return 0
+	}
+	return time.Duration(d.total.Get() / n)
+}
+
+// reset 清空已经记录的耗时
+func (d *durationStats) reset() {
+	d.count.reset()
+	d.total.reset()
+	d.max.reset()
+}
+
+// groupStats 分组内部使用的累计计数器
+type groupStats struct {
+	gets          AtomicInt // Get 请求总数
+	cacheHits     AtomicInt // 命中本地缓存的次数
+	loads         AtomicInt // 未命中缓存、进入 load 流程的次数
+	peerLoads     AtomicInt // 从远程节点获取成功的次数
+	peerErrors    AtomicInt // 从远程节点获取失败的次数
+	localLoads    AtomicInt // 调用 getter 成功的次数
+	localLoadErrs AtomicInt // 调用 getter 失败的次数
+	bloomRejects  AtomicInt // 被布隆过滤器判断为一定不存在的次数
+	fallbackLoads AtomicInt // 从备用集群获取成功的次数
+	staleHits     AtomicInt // 背压时返回旧值的次数
+
+	pendingRemoves AtomicInt // 正在等待所属节点确认的删除数
+
+	cacheFullRejects AtomicInt // 因为固定的条目占满容量而没有写入缓存的次数
+
+	invalidValues AtomicInt // 没有通过 WithValidator 校验的加载结果数
+
+	evictNotifyDrops AtomicInt // 任务池已满而丢弃的异步淘汰通知数
+	missNotifyDrops  AtomicInt // 任务池已满而丢弃的未命中回调数
+
+	mainCacheHits AtomicInt // 命中 mainCache 的次数
+	hotCacheHits  AtomicInt // 命中 hotCache 的次数
+	hotCacheFills AtomicInt // 从远程节点获取值之后写入 hotCache 的次数
+
+	hedges    AtomicInt // 发起的对冲请求数
+	hedgeWins AtomicInt // 对冲请求先于所属节点成功返回的次数
+
+	microCacheBase int64 // ResetStats 时 singleflight 中保留结果的命中数，MicroCacheHits 从这里开始计
+
+	queueWait   durationStats // 调用 getter 之前等待限速的耗时
+	getterTimes durationStats // getter 本身的耗时
+}
+
+// Stats 是分组统计信息的快照
+type Stats struct {
+	Gets          int64
+	CacheHits     int64
+	Loads         int64
+	PeerLoads     int64
+	PeerErrors    int64
+	LocalLoads    int64
+	LocalLoadErrs int64
+	BloomRejects  int64
+	FallbackLoads int64
+	StaleHits     int64
+
+	PendingRemoves int64 // 正在等待所属节点确认的 RemoveAndVerify 调用数，持续不为 0 说明删除传播受阻
+
+	CacheFullRejects int64 // 放不下而没有写入缓存的值的个数（PinOverflowReject 策略下），持续上涨说明固定的条目太多
+
+	InvalidValues int64 // 没有通过 WithValidator 校验、被丢弃的加载结果数（包括远程节点和 getter 返回的）
+
+	EvictionNotifyDrops int64 // 后台任务池已满而丢弃的淘汰通知数（EvictionAsync 时），不为 0 说明回调跟不上淘汰的速度
+	MissNotifyDrops     int64 // 后台任务池已满而丢弃的 WithOnMiss 回调数
+
+	// CacheHits 按命中的缓存拆分，用于判断 hotCache 的容量是否合适：HotCacheHits 与 HotCacheFills 相比很少时，
+	// hotCache 中的副本几乎没有被再次读到，占用的内存是浪费的
+	MainCacheHits int64 // 命中 mainCache（本节点负责的值）的次数
+	HotCacheHits  int64 // 命中 hotCache（其它节点的值的副本）的次数
+	HotCacheFills int64 // 从远程节点获取值之后写入 hotCache 的次数（值放不下时仍然计入）
+
+	Hedges    int64 // 向第二选择节点发起的对冲请求数（见 WithHedging）
+	HedgeWins int64 // 对冲请求先于所属节点成功返回的次数，与 Hedges 之比很低时说明 delay 设得太小
+
+	MicroCacheHits int64 // 直接拿到 singleflight 中保留的加载结果（见 WithMicroCache）的请求数
+
+	// 区分冷启动和稳定运行：Warm 为 false 时命中率天然偏低，命中率告警应当排除这段时间，预热条件见 WithWarmCriteria
+	Warm              bool
+	SinceFirstRequest time.Duration // 距离分组收到第一个请求的时间，还没有请求时为 0
+	FillRatio         float64       // mainCache 已用字节数与容量之比，缓存关闭或不限制容量时为 0
+
+	Compression *CompressionStats // 值压缩的效果，没有开启 WithCompression 时为 nil
+
+	RateLimitTokens  float64 // 当前可用的令牌数，没有配置限速时为 0
+	RateLimitRejects int64   // 因限速被拒绝的 getter 调用次数
+
+	// 区分“数据源慢”和“自己限速导致的排队”：前者 GetterAvg 高，后者 QueueWaitAvg 高
+	QueueWaitAvg time.Duration // 调用 getter 之前等待限速的平均耗时，没有配置限速时为 0
+	QueueWaitMax time.Duration // 等待限速的最大耗时
+	GetterAvg    time.Duration // getter 本身的平均耗时，不包括排队时间
+	GetterMax    time.Duration // getter 本身的最大耗时
+
+	Bytes     int64 // 缓存当前占用的字节数
+	Items     int64 // 缓存当前的条目数
+	Evictions int64 // 累计淘汰的条目数
+
+	// Overhead 估算缓存在 Bytes 之外用于簿记的字节数（map 槽位、链表节点、条目结构体），按 Items 乘以每个条目的固定开销计算
+	// Bytes 只计入 key 和值的长度，规划节点内存时应按 Bytes + Overhead 估算；它是近似值，没有计入内存分配器的取整和 GC 的额外开销
+	Overhead int64
+
+	// ArenaBytes 是 WithSmallValueArena 的 slab 在 mainCache 和 hotCache 中实际占用的字节数，没有开启时为 0
+	// slab 只有在其中的值全部离开缓存后才整块释放，这部分内存不受 cacheBytes 限制，Bytes 中的小值只是其中还在使用的部分
+	ArenaBytes int64
+
+	// 以下指标用于提前发现 key 基数爆炸（如调用方 bug 生成了无穷多的不同 key）：
+	// 新 key 的写入速率持续走高、且几乎每次写入都会挤掉一个旧值时，命中率很快就会崩溃
+	UniqueKeyRate float64 // 每秒写入缓存的新 key 数（滚动估计）
+	EvictionRate  float64 // 每秒淘汰的条目数（滚动估计）
+	EvictionChurn float64 // EvictionRate / UniqueKeyRate，接近 1 说明缓存正在被新 key 冲刷
+}
+
+// Stats 返回分组当前的统计信息
+func (g *Group) Stats() Stats {
+	cs := g.mainCache.stats()
+	s := Stats{
+		Gets:          g.stats.gets.Get(),
+		CacheHits:     g.stats.cacheHits.Get(),
+		Loads:         g.stats.loads.Get(),
+		PeerLoads:     g.stats.peerLoads.Get(),
+		PeerErrors:    g.stats.peerErrors.Get(),
+		LocalLoads:    g.stats.localLoads.Get(),
+		LocalLoadErrs: g.stats.localLoadErrs.Get(),
+		BloomRejects:  g.stats.bloomRejects.Get(),
+		FallbackLoads: g.stats.fallbackLoads.Get(),
+		StaleHits:     g.stats.staleHits.Get(),
+		QueueWaitAvg:  g.stats.queueWait.avg(),
+		QueueWaitMax:  time.Duration(g.stats.queueWait.max.Get()),
+		GetterAvg:     g.stats.getterTimes.avg(),
+		GetterMax:     time.Duration(g.stats.getterTimes.max.Get()),
+		Bytes:         cs.bytes,
+		Items:         cs.items,
+		Evictions:     cs.evictions,
+		UniqueKeyRate: cs.newKeyRate,
+		EvictionRate:  cs.evictionRate,
+	}
+	s.PendingRemoves = g.stats.pendingRemoves.Get()
+	s.CacheFullRejects = g.stats.cacheFullRejects.Get()
+	s.Overhead = cs.overhead
+	s.ArenaBytes = cs.arenaBytes + g.hotCache.stats().arenaBytes
+	s.InvalidValues = g.stats.invalidValues.Get()
+	s.EvictionNotifyDrops = g.stats.evictNotifyDrops.Get()
+	s.MissNotifyDrops = g.stats.missNotifyDrops.Get()
+	s.MainCacheHits = g.stats.mainCacheHits.Get()
+	s.HotCacheHits = g.stats.hotCacheHits.Get()
+	s.HotCacheFills = g.stats.hotCacheFills.Get()
+	s.Hedges = g.stats.hedges.Get()
+	s.HedgeWins = g.stats.hedgeWins.Get()
+	s.MicroCacheHits = g.loader.RetainedHits() - atomic.LoadInt64(&g.stats.microCacheBase)
+	s.Warm = g.checkWarm(cs.bytes)
+	s.SinceFirstRequest = g.sinceFirstGet()
+	s.FillRatio = g.fillRatio(cs.bytes)
+	if g.compressor != nil {
+		s.Compression = g.compressor.stats()
+	}
+	if g.limiter != nil {
+		s.RateLimitTokens = g.limiter.available()
+		s.RateLimitRejects = g.limiter.rejects.Get()
+	}
+	if s.UniqueKeyRate > 0 {
+		s.EvictionChurn = s.EvictionRate / s.UniqueKeyRate
+	}
+	return s
+}
+
+// ResetStats 将分组的累计计数器（请求、命中、加载、错误、淘汰、耗时以及每个远程节点的计数）清零，不影响缓存内容
+// 适合在配置变更之后重新观察分组的表现；PendingRemoves、InFlight 这类反映当前状态的值不会被清零
+// 每个计数器单独清零，与并发的累加之间是良性竞争：清零的那一刻正在进行的请求可能只被部分计入，计数会有轻微偏差
+func (g *Group) ResetStats() {
+	s := &g.stats
+	for _, c := range []*AtomicInt{
+		&s.gets, &s.cacheHits, &s.loads, &s.peerLoads, &s.peerErrors, &s.localLoads,
+		&s.localLoadErrs, &s.bloomRejects, &s.fallbackLoads, &s.staleHits, &s.cacheFullRejects,
+		&s.invalidValues, &s.evictNotifyDrops, &s.missNotifyDrops, &s.hedges, &s.hedgeWins,
+		&s.mainCacheHits, &s.hotCacheHits, &s.hotCacheFills,
+	} {
+		c.reset()
+	}
+	s.queueWait.reset()
+	s.getterTimes.reset()
+	atomic.StoreInt64(&s.microCacheBase, g.loader.RetainedHits())
+	if g.limiter != nil {
+		g.limiter.rejects.reset()
+	}
+	g.mainCache.resetStats()
+	if g.compressor != nil {
+		g.compressor.resetStats()
+	}
+
+	g.peerStatsMu.Lock()
+	defer g.peerStatsMu.Unlock()
+	for _, pc := range g.peerStats {
+		pc.errors.reset()
+		pc.fallbacks.reset()
+		pc.latency.reset()
+	}
+}
+
+// BackgroundStats 返回分组后台任务池的状态：正在执行和排队的任务数、累计丢弃的任务数
+// 所有后台任务都在这个任务池中执行，后台占用的 goroutine 数不会超过 Workers，上限和丢弃策略见 WithWorkerPool、WithBackgroundDropPolicy
+// 请求路径上的并发（如 GetMulti 按节点并发的批量请求、WithHedging 的对冲请求）随请求结束，不在此列
+func (g *Group) BackgroundStats() workerpool.Stats {
+	return g.pool.Stats()
+}
+
+// AccessHistogram 返回缓存条目命中次数的分布，分桶规则与计数的重置策略见 lru.Cache.AccessHistogram
+func (g *Group) AccessHistogram() []int {
+	return g.mainCache.accessHistogram()
+}
+
+// PeerStats 是某个远程节点的统计信息
+type PeerStats struct {
+	Errors    int64 // 从该节点获取失败的次数
+	Fallbacks int64 // 从该节点获取失败后回退到本地 getter 的次数，持续上涨说明该节点可能不健康
+	InFlight  int64 // 当前正在发往该节点的请求数，节点不支持统计时为 0
+
+	LatencyAvg time.Duration // 请求该节点的平均耗时（包括失败的请求），批量请求按一次计
+	LatencyMax time.Duration // 请求该节点的最大耗时
+}
+
+// peerCounters 是某个远程节点的累计计数器
+type peerCounters struct {
+	errors    AtomicInt
+	fallbacks AtomicInt
+	latency   durationStats
+
+	peer PeerGetter // 最近一次使用的节点，用于读取它的实时状态
+}
+
+// inFlightCounter 由能够统计正在进行的请求数的 PeerGetter（如 httpGetter）实现
+type inFlightCounter interface {
+	inFlightRequests() int64
+}
+
+// peerCounters 返回远程节点的计数器，第一次使用时创建
+func (g *Group) peerCounters(peer PeerGetter) *peerCounters {
+	name := peerName(peer)
+
+	g.peerStatsMu.Lock()
+	defer g.peerStatsMu.Unlock()
+
+	if g.peerStats == nil {
+		g.peerStats = make(map[string]*peerCounters)
+	}
+	pc, ok := g.peerStats[name]
+	if !ok {
+		pc = new(peerCounters)
+		g.peerStats[name] = pc
+	}
+	// 哈希环更新后同一个地址会对应新的 PeerGetter
+	pc.peer = peer
+	return pc
+}
+
+// PeerStats 返回每个请求过的远程节点的统计信息，以节点名称为 key
+// 节点实现了 fmt.Stringer 时使用 String() 作为名称（httpGetter 为节点的请求地址），否则使用它的类型和地址
+func (g *Group) PeerStats() map[string]PeerStats {
+	g.peerStatsMu.Lock()
+	defer g.peerStatsMu.Unlock()
+
+	stats := make(map[string]PeerStats, len(g.peerStats))
+	for name, pc := range g.peerStats {
+		ps := PeerStats{
+			Errors:     pc.errors.Get(),
+			Fallbacks:  pc.fallbacks.Get(),
+			LatencyAvg: pc.latency.avg(),
+			LatencyMax: time.Duration(pc.latency.max.Get()),
+		}
+		if c, ok := pc.peer.(inFlightCounter); ok {
+			ps.InFlight = c.inFlightRequests()
+		}
+		stats[name] = ps
+	}
+	return stats
+}
+
+func peerName(peer PeerGetter) string {
+	if s, ok := peer.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T(%p)", peer, peer)
+}
+
+// OldestN 返回 mainCache 中最接近被淘汰的 n 个 key，第一个是下一个将被淘汰的
+// 可以用来观察淘汰边界，或者提前把即将被淘汰的热点数据迁移到别处
+func (g *Group) OldestN(n int) []string {
+	return g.mainCache.oldestN(n)
+}
+
+const (
+	rateInterval = time.Second // 速率的统计周期
+	rateAlpha    = 0.3         // 平滑系数，越大越偏向最近一个周期
+)
+
+// rateMeter 用指数加权移动平均（EWMA）估算事件的每秒速率
+// 它只在事件发生或读取时更新，不需要后台 goroutine；非并发安全，由调用方加锁
+type rateMeter struct {
+	rate    float64   // 当前估计的每秒速率
+	pending int64     // 当前周期内还没有计入速率的事件数
+	last    time.Time // 上一次计入速率的时间
+}
+
+// mark 记录 n 次事件
+func (m *rateMeter) mark(now time.Time, n int64) {
+	m.tick(now)
+	m.pending += n
+}
+
+// value 返回当前的速率估计
+func (m *rateMeter) value(now time.Time) float64 {
+	m.tick(now)
+	return m.rate
+}
+
+// tick 距离上一次计入超过一个周期时，把这段时间内的平均速率合并进估计值
+// 中间空闲的周期按速率为 0 参与衰减，所以流量停止后估计值会逐渐回落
+func (m *rateMeter) tick(now time.Time) {
+	if m.last.IsZero() {
+		m.last = now
+		return
+	}
+	elapsed := now.Sub(m.last)
+	if elapsed < rateInterval {
+		return
+	}
+
+	periods := float64(elapsed / rateInterval)
+	weight := 1 - math.Pow(1-rateAlpha, periods)
+	instant := float64(m.pending) / elapsed.Seconds()
+	m.rate = weight*instant + (1-weight)*m.rate
+	m.pending = 0
+	m.last = now
+}
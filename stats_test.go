@@ -0,0 +1,201 @@
+package mini_groupcache
+
+import (
+	"fmt"
+	"math"
+	"mini-groupcache/testpb"
+	"mini-groupcache/workerpool"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateMeter(t *testing.T) {
+	var m rateMeter
+	start := time.Now()
+
+	// 连续 10 秒、每秒 100 次事件，估计值应该收敛到 100 附近
+	for i := 0; i <= 10; i++ {
+		m.mark(start.Add(time.Duration(i)*time.Second), 100)
+	}
+	if r := m.value(start.Add(10 * time.Second)); r < 90 || r > 100 {
+		t.Fatalf("rate = %.2f, want about 100", r)
+	}
+
+	// 流量停止一段时间后估计值应该衰减
+	if r := m.value(start.Add(30 * time.Second)); r > 10 {
+		t.Fatalf("rate = %.2f after idle, want it to decay", r)
+	}
+}
+
+func TestGroup_Stats(t *testing.T) {
+	// 每个 key 加上值占 2 个字节，容量只能放下 5 个 key
+	group := NewGroup("stats", 10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}))
+
+	// 模拟不断生成新 key 的调用方
+	for i := 0; i < 10; i++ {
+		if _, err := group.Get(strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := group.Get("9"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := group.Stats()
+	if s.Gets != 11 || s.CacheHits != 1 || s.Loads != 10 || s.LocalLoads != 10 {
+		t.Fatalf("unexpected counters: %+v", s)
+	}
+	if s.Items != 5 || s.Bytes != 10 || s.Evictions != 5 {
+		t.Fatalf("unexpected cache stats: %+v", s)
+	}
+	// 簿记开销按条目数估算，每个条目远大于 key 和值本身的 2 个字节
+	if s.Overhead <= 0 || s.Overhead%s.Items != 0 || s.Overhead/s.Items < 100 {
+		t.Fatalf("unexpected overhead: %d for %d items", s.Overhead, s.Items)
+	}
+
+	// 清零计数器不影响缓存内容
+	group.ResetStats()
+	s = group.Stats()
+	if s.Gets != 0 || s.CacheHits != 0 || s.Loads != 0 || s.LocalLoads != 0 || s.Evictions != 0 || s.GetterMax != 0 {
+		t.Fatalf("counters should be zero after reset: %+v", s)
+	}
+	if s.Items != 5 || s.Bytes != 10 {
+		t.Fatalf("reset should keep the cache contents: %+v", s)
+	}
+	group.Get("9")
+	if s = group.Stats(); s.Gets != 1 || s.CacheHits != 1 {
+		t.Fatalf("counters should start over after reset: %+v", s)
+	}
+}
+
+func TestGroup_PeerStats(t *testing.T) {
+	bad := &stubPeer{err: fmt.Errorf("peer is down")}
+	group := NewGroup("peer-stats", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}))
+	group.RegisterPeers(stubPicker{bad})
+
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := group.Get(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := group.PeerStats()
+	if got := stats[peerName(bad)]; got.Errors != 3 || got.Fallbacks != 3 || got.LatencyMax == 0 {
+		t.Fatalf("PeerStats = %+v, want 3 errors and 3 fallbacks", stats)
+	}
+	if name := peerName(&httpGetter{baseURL: "http://a:1/_groupcache/"}); name != "http://a:1/_groupcache/" {
+		t.Fatalf("peerName = %q, want the base URL", name)
+	}
+}
+
+func TestGroup_IsWarm(t *testing.T) {
+	group := NewGroup("is-warm", 100, GetterFunc(func(key string) ([]byte, error) {
+		return make([]byte, 20), nil
+	}), WithWarmCriteria(0.4, time.Hour))
+
+	if group.IsWarm() || group.Stats().SinceFirstRequest != 0 {
+		t.Fatal("group should be cold before the first request")
+	}
+	group.Get("a")
+	if s := group.Stats(); s.Warm || s.FillRatio != 0.21 || s.SinceFirstRequest <= 0 {
+		t.Fatalf("stats = %+v", s)
+	}
+	group.Get("b")
+	if !group.IsWarm() {
+		t.Fatalf("group should be warm at fill ratio %v", group.Stats().FillRatio)
+	}
+	// 预热完成之后保持不变
+	group.Remove("a")
+	group.Remove("b")
+	if !group.IsWarm() {
+		t.Fatal("group should stay warm after the cache is emptied")
+	}
+
+	timed := NewGroup("is-warm-timed", 0, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithWarmCriteria(0, time.Millisecond))
+	timed.Get("a")
+	time.Sleep(2 * time.Millisecond)
+	if !timed.IsWarm() {
+		t.Fatal("group should be warm once the duration has elapsed")
+	}
+}
+
+func TestGroup_Health(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		if key == "bad" {
+			return nil, fmt.Errorf("source is down")
+		}
+		return []byte(key), nil
+	})
+	group := NewGroup("health", 2<<10, getter)
+	if h := group.Health(); h.Score != 1 {
+		t.Fatalf("idle group score = %v, want 1", h.Score)
+	}
+
+	// 命中率 2/4，getter 错误率 1/2，没有淘汰
+	for _, key := range []string{"a", "a", "bad", "a"} {
+		group.Get(key)
+	}
+	h := group.Health()
+	if h.HitRate != 0.5 || h.GetterErrorRate != 0.5 || h.EvictionChurn != 0 {
+		t.Fatalf("health = %+v", h)
+	}
+	if math.Abs(h.Score-0.65) > 1e-9 {
+		t.Fatalf("score = %v, want 0.4*0.5 + 0.3*1 + 0.3*0.5", h.Score)
+	}
+
+	weighted := NewGroup("health-weighted", 2<<10, getter, WithHealthWeights(HealthWeights{HitRate: 1}))
+	for _, key := range []string{"a", "a", "bad", "a"} {
+		weighted.Get(key)
+	}
+	if h := weighted.Health(); h.Score != 0.5 {
+		t.Fatalf("score = %v, want the hit rate only", h.Score)
+	}
+}
+
+func TestGroup_CacheHitBreakdown(t *testing.T) {
+	peer := &stubPeer{res: &testpb.Response{Value: []byte("remote")}}
+	group := NewGroup("hit-breakdown", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	group.RegisterPeers(prefixPicker{"r": peer})
+
+	for _, key := range []string{"a", "a", "r:1", "r:1", "r:1"} {
+		group.Get(key)
+	}
+	s := group.Stats()
+	if s.MainCacheHits != 1 || s.HotCacheHits != 2 || s.HotCacheFills != 1 || s.CacheHits != 3 {
+		t.Fatalf("main = %d, hot = %d, fills = %d, total = %d", s.MainCacheHits, s.HotCacheHits, s.HotCacheFills, s.CacheHits)
+	}
+	// GetEntry 加载之后再次查找缓存不算一次命中
+	group.GetEntry("b")
+	if s := group.Stats(); s.MainCacheHits != 1 {
+		t.Fatalf("main hits = %d after a miss", s.MainCacheHits)
+	}
+}
+
+func TestGroup_BackgroundStats(t *testing.T) {
+	block := make(chan struct{})
+	group := NewGroup("background-stats", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		<-block
+		return []byte(key), nil
+	}), WithBackgroundDropPolicy(workerpool.DropOldest), WithWorkerPool(1, 1))
+
+	// 唯一的 worker 被第一个加载占住，队列中只保留最新的一个
+	group.GetOrQueue("a", nil)
+	deadline := time.Now().Add(time.Second)
+	for group.BackgroundStats().Active != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	group.GetOrQueue("b", nil)
+	group.GetOrQueue("c", nil)
+	if s := group.BackgroundStats(); s.Active != 1 || s.Queued != 1 || s.Dropped != 1 {
+		t.Fatalf("background stats = %+v", s)
+	}
+	close(block)
+}
@@ -0,0 +1,72 @@
+package mini_groupcache
+
+import (
+	"mini-groupcache/lru"
+	"time"
+)
+
+// Store 是 cache 底层保存条目的存储，默认使用 lru.Cache，可以用 WithStore 换成其它淘汰策略的实现（或者什么都不保存的存储）
+// 存储中的值是缓存内部的条目（实现了 lru.Value，Len 为缓存值的字节数），实现者只需原样保存、返回，不需要了解它的内容
+// cache 在调用 Store 的方法时总是持有自己的写锁（可选的 Peek 除外），实现不需要是并发安全的，也不能在淘汰回调中再调用 Store 的方法
+type Store interface {
+	// AddWithExpire 新增或覆盖 key 的值，expire 为零值时永不过期；超出容量时由存储自己淘汰条目
+	AddWithExpire(key string, value lru.Value, expire time.Time)
+	// Get 返回 key 的值，已经过期的条目视为不存在
+	Get(key string) (lru.Value, bool)
+	// Remove 删除 key 并返回它是否存在
+	Remove(key string) bool
+	// RemoveOldest 按存储的策略淘汰一个条目，没有可以淘汰的条目时返回 false
+	RemoveOldest() bool
+	// Range 依次对每个条目调用 f，f 返回 false 时停止，遍历期间不能修改存储
+	Range(f func(key string, value lru.Value) bool)
+	// Clear 删除所有条目
+	Clear()
+	// Resize 修改容量并淘汰到新的容量以内，maxBytes 为 0 时不限制容量
+	Resize(maxBytes int64)
+	// Len 返回条目数
+	Len() int
+	// Bytes 返回所有 key 和值的字节数之和
+	Bytes() int64
+}
+
+// StoreFunc 创建一个最多占用 maxBytes 字节（0 表示不限制）的 Store
+// 条目离开存储（被淘汰、过期、删除、清空）或者值被覆盖时，存储必须调用 onEvicted，缓存据此统计淘汰数、通知 WithOnEvicted 的回调
+type StoreFunc func(maxBytes int64, onEvicted func(key string, value lru.Value, reason lru.EvictionReason)) Store
+
+// pinningStore 是支持固定条目的 Store，Group.Pin 和 WithPinOverflow 需要它，不支持时 Pin 的 key 视为不在缓存中
+type pinningStore interface {
+	Store
+	Pin(key string) bool
+	Unpin(key string)
+	PinnedBytes() int64
+	TryAddWithExpire(key string, value lru.Value, expire time.Time) error
+}
+
+// peekingStore 是支持只读查询的 Store，cache 只持有读锁调用 Peek，多个 Peek 可能并发执行
+// Peek 不能修改存储（不提升访问顺序、不删除过期条目），返回的条目可能已经过期，由 cache 自己判断
+type peekingStore interface {
+	Store
+	Peek(key string) (lru.Value, bool)
+}
+
+// inspectableStore 是能提供诊断信息的 Store，不支持时对应的统计信息为零值
+type inspectableStore interface {
+	Store
+	Overhead() int64
+	AccessHistogram() []int
+	OldestN(n int) []string
+}
+
+var (
+	_ pinningStore     = (*lru.Cache)(nil)
+	_ inspectableStore = (*lru.Cache)(nil)
+	_ peekingStore     = (*lru.Cache)(nil)
+)
+
+// newLRUStore 是默认的 StoreFunc，按 cache 上的淘汰策略和候选数创建 lru.Cache
+func (c *cache) newLRUStore(maxBytes int64, onEvicted func(key string, value lru.Value, reason lru.EvictionReason)) Store {
+	s := lru.NewCacheWithPolicy(maxBytes, c.policy, nil)
+	s.OnEvictedReason = onEvicted
+	s.ExpiryCandidates = c.expiryCandidates
+	return s
+}
@@ -0,0 +1,50 @@
+// Package storeadapter 把读取外部存储（Redis、SQL 等）的函数包装成分组的 getter，
+// 统一处理 ctx 和“key 不存在”错误的转换，核心包不依赖任何外部存储的客户端
+package storeadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	mini_groupcache "mini-groupcache"
+)
+
+// FetchFunc 从外部存储读取 key 对应的值
+type FetchFunc func(ctx context.Context, key string) ([]byte, error)
+
+// NotFound 返回一个判断函数，错误链中包含 targets 之一（如 redis.Nil、sql.ErrNoRows）时表示 key 不存在
+func NotFound(targets ...error) func(err error) bool {
+	return func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Getter 将 fetch 包装成 GetterContext
+// isNotFound 判断为 key 不存在的错误会被转换成 mini_groupcache.ErrNotFound（保留原始错误信息），
+// 调用方和负缓存可以统一用 errors.Is(err, mini_groupcache.ErrNotFound) 判断；isNotFound 为 nil 时不做转换
+// ctx 已经结束时直接返回 ctx.Err()，不再访问外部存储
+func Getter(fetch FetchFunc, isNotFound func(err error) bool) mini_groupcache.GetterContext {
+	if fetch == nil {
+		panic("nil fetch")
+	}
+	return mini_groupcache.GetterContextFunc(func(ctx context.Context, key string) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		value, err := fetch(ctx, key)
+		if err != nil && isNotFound != nil && isNotFound(err) {
+			return nil, fmt.Errorf("%w: %s: %v", mini_groupcache.ErrNotFound, key, err)
+		}
+		return value, err
+	})
+}
+
+// NewGroup 创建一个从外部存储加载数据的分组，参数含义见 Getter 和 mini_groupcache.NewGroupContext
+func NewGroup(name string, cacheBytes int64, fetch FetchFunc, isNotFound func(err error) bool, opts ...mini_groupcache.GroupOption) *mini_groupcache.Group {
+	return mini_groupcache.NewGroupContext(name, cacheBytes, Getter(fetch, isNotFound), opts...)
+}
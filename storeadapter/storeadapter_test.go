@@ -0,0 +1,46 @@
+package storeadapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	mini_groupcache "mini-groupcache"
+	"testing"
+)
+
+func TestNewGroup(t *testing.T) {
+	rows := map[string]string{"a": "1"}
+	down := errors.New("connection refused")
+	group := NewGroup("storeadapter", 2<<10, func(ctx context.Context, key string) ([]byte, error) {
+		if key == "down" {
+			return nil, down
+		}
+		v, ok := rows[key]
+		if !ok {
+			return nil, fmt.Errorf("query %s: %w", key, sql.ErrNoRows)
+		}
+		return []byte(v), nil
+	}, NotFound(sql.ErrNoRows))
+
+	if v, err := group.Get("a"); err != nil || v.String() != "1" {
+		t.Fatalf("Get(a) = %q, %v", v, err)
+	}
+	// 不存在的 key 统一转换成 ErrNotFound
+	if _, err := group.Get("b"); !errors.Is(err, mini_groupcache.ErrNotFound) {
+		t.Fatalf("Get(b) err = %v, want %v", err, mini_groupcache.ErrNotFound)
+	}
+	// 其它错误原样返回
+	if _, err := group.Get("down"); !errors.Is(err, down) || errors.Is(err, mini_groupcache.ErrNotFound) {
+		t.Fatalf("Get(down) err = %v, want %v", err, down)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Getter(func(ctx context.Context, key string) ([]byte, error) {
+		t.Fatal("fetch should not be called after ctx is done")
+		return nil, nil
+	}, nil).Get(ctx, "a"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+}
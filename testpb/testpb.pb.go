@@ -69,6 +69,11 @@ func (m *Request) GetKey() string {
 
 type Response struct {
 	Value                []byte   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	TtlMs                int64    `protobuf:"varint,2,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"`
+	Version              int64    `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	Error                string   `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	NoStore              bool     `protobuf:"varint,5,opt,name=no_store,json=noStore,proto3" json:"no_store,omitempty"`
+	NotFound             bool     `protobuf:"varint,6,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -106,23 +111,263 @@ func (m *Response) GetValue() []byte {
 	return nil
 }
 
+func (m *Response) GetTtlMs() int64 {
+	if m != nil {
+		return m.TtlMs
+	}
+	return 0
+}
+
+func (m *Response) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Response) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *Response) GetNoStore() bool {
+	if m != nil {
+		return m.NoStore
+	}
+	return false
+}
+
+func (m *Response) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+type BatchRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Keys                 [][]byte `protobuf:"bytes,2,rep,name=keys,proto3" json:"keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BatchRequest) Reset()         { *m = BatchRequest{} }
+func (m *BatchRequest) String() string { return proto.CompactTextString(m) }
+func (*BatchRequest) ProtoMessage()    {}
+func (*BatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b98c0ed33edeb52, []int{2}
+}
+
+func (m *BatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchRequest.Unmarshal(m, b)
+}
+func (m *BatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchRequest.Marshal(b, m, deterministic)
+}
+func (m *BatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchRequest.Merge(m, src)
+}
+func (m *BatchRequest) XXX_Size() int {
+	return xxx_messageInfo_BatchRequest.Size(m)
+}
+func (m *BatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchRequest proto.InternalMessageInfo
+
+func (m *BatchRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *BatchRequest) GetKeys() [][]byte {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type BatchResponse struct {
+	Responses            []*Response `protobuf:"bytes,1,rep,name=responses,proto3" json:"responses,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *BatchResponse) Reset()         { *m = BatchResponse{} }
+func (m *BatchResponse) String() string { return proto.CompactTextString(m) }
+func (*BatchResponse) ProtoMessage()    {}
+func (*BatchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b98c0ed33edeb52, []int{3}
+}
+
+func (m *BatchResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchResponse.Unmarshal(m, b)
+}
+func (m *BatchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchResponse.Marshal(b, m, deterministic)
+}
+func (m *BatchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchResponse.Merge(m, src)
+}
+func (m *BatchResponse) XXX_Size() int {
+	return xxx_messageInfo_BatchResponse.Size(m)
+}
+func (m *BatchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchResponse proto.InternalMessageInfo
+
+func (m *BatchResponse) GetResponses() []*Response {
+	if m != nil {
+		return m.Responses
+	}
+	return nil
+}
+
+type DeleteRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteRequest) Reset()         { *m = DeleteRequest{} }
+func (m *DeleteRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteRequest) ProtoMessage()    {}
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b98c0ed33edeb52, []int{4}
+}
+
+func (m *DeleteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteRequest.Unmarshal(m, b)
+}
+func (m *DeleteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteRequest.Merge(m, src)
+}
+func (m *DeleteRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteRequest.Size(m)
+}
+func (m *DeleteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteRequest proto.InternalMessageInfo
+
+func (m *DeleteRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *DeleteRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+type IncrementRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Delta                int64    `protobuf:"varint,3,opt,name=delta,proto3" json:"delta,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *IncrementRequest) Reset()         { *m = IncrementRequest{} }
+func (m *IncrementRequest) String() string { return proto.CompactTextString(m) }
+func (*IncrementRequest) ProtoMessage()    {}
+func (*IncrementRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b98c0ed33edeb52, []int{5}
+}
+
+func (m *IncrementRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_IncrementRequest.Unmarshal(m, b)
+}
+func (m *IncrementRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_IncrementRequest.Marshal(b, m, deterministic)
+}
+func (m *IncrementRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IncrementRequest.Merge(m, src)
+}
+func (m *IncrementRequest) XXX_Size() int {
+	return xxx_messageInfo_IncrementRequest.Size(m)
+}
+func (m *IncrementRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_IncrementRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IncrementRequest proto.InternalMessageInfo
+
+func (m *IncrementRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *IncrementRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *IncrementRequest) GetDelta() int64 {
+	if m != nil {
+		return m.Delta
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Request)(nil), "testpb.Request")
 	proto.RegisterType((*Response)(nil), "testpb.Response")
+	proto.RegisterType((*BatchRequest)(nil), "testpb.BatchRequest")
+	proto.RegisterType((*BatchResponse)(nil), "testpb.BatchResponse")
+	proto.RegisterType((*DeleteRequest)(nil), "testpb.DeleteRequest")
+	proto.RegisterType((*IncrementRequest)(nil), "testpb.IncrementRequest")
 }
 
 func init() { proto.RegisterFile("testpb.proto", fileDescriptor_1b98c0ed33edeb52) }
 
 var fileDescriptor_1b98c0ed33edeb52 = []byte{
-	// 146 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x29, 0x49, 0x2d, 0x2e,
-	0x29, 0x48, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x83, 0xf0, 0x94, 0x0c, 0xb9, 0xd8,
-	0x83, 0x52, 0x0b, 0x4b, 0x53, 0x8b, 0x4b, 0x84, 0x44, 0xb8, 0x58, 0xd3, 0x8b, 0xf2, 0x4b, 0x0b,
-	0x24, 0x18, 0x15, 0x18, 0x35, 0x38, 0x83, 0x20, 0x1c, 0x21, 0x01, 0x2e, 0xe6, 0xec, 0xd4, 0x4a,
-	0x09, 0x26, 0xb0, 0x18, 0x88, 0xa9, 0xa4, 0xc0, 0xc5, 0x11, 0x94, 0x5a, 0x5c, 0x90, 0x9f, 0x57,
-	0x9c, 0x0a, 0xd2, 0x53, 0x96, 0x98, 0x53, 0x9a, 0x0a, 0xd6, 0xc3, 0x13, 0x04, 0xe1, 0x18, 0x99,
-	0x71, 0x71, 0xb9, 0x83, 0x34, 0x3b, 0x27, 0x26, 0x67, 0xa4, 0x0a, 0x69, 0x70, 0x31, 0xbb, 0xa7,
-	0x96, 0x08, 0xf1, 0xeb, 0x41, 0x1d, 0x00, 0xb5, 0x4f, 0x4a, 0x00, 0x21, 0x00, 0x31, 0x2d, 0x89,
-	0x0d, 0xec, 0x36, 0x63, 0x40, 0x00, 0x00, 0x00, 0xff, 0xff, 0xc0, 0x83, 0xf6, 0xac, 0xab, 0x00,
-	0x00, 0x00,
+	// 356 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x92, 0xc1, 0x6e, 0xda, 0x40,
+	0x10, 0x86, 0xe5, 0x1a, 0x8c, 0x3d, 0x35, 0x2a, 0x5a, 0x81, 0xb4, 0xa5, 0x17, 0xe4, 0x93, 0x4f,
+	0x48, 0xd0, 0x03, 0xbd, 0x55, 0x6a, 0xab, 0xa2, 0x1e, 0x2a, 0x45, 0x9b, 0x07, 0x40, 0x06, 0x26,
+	0x21, 0xc2, 0xec, 0x3a, 0xbb, 0x63, 0x24, 0x9e, 0x26, 0x0f, 0x96, 0x97, 0x89, 0x76, 0x6d, 0x43,
+	0x02, 0x51, 0x24, 0x6e, 0xf3, 0xcf, 0xcc, 0xe7, 0xd9, 0xff, 0x97, 0x21, 0x26, 0x34, 0x54, 0x2c,
+	0xc7, 0x85, 0x56, 0xa4, 0x58, 0x50, 0xa9, 0x64, 0x02, 0x1d, 0x81, 0x8f, 0x25, 0x1a, 0x62, 0x7d,
+	0x68, 0xdf, 0x6b, 0x55, 0x16, 0xdc, 0x1b, 0x79, 0x69, 0x24, 0x2a, 0xc1, 0x7a, 0xe0, 0x6f, 0xf1,
+	0xc0, 0x3f, 0xb9, 0x9e, 0x2d, 0x93, 0x27, 0x0f, 0x42, 0x81, 0xa6, 0x50, 0xd2, 0xa0, 0x85, 0xf6,
+	0x59, 0x5e, 0xa2, 0x83, 0x62, 0x51, 0x09, 0x36, 0x80, 0x80, 0x28, 0x5f, 0xec, 0x8c, 0xe3, 0x7c,
+	0xd1, 0x26, 0xca, 0xff, 0x1b, 0xc6, 0xa1, 0xb3, 0x47, 0x6d, 0x1e, 0x94, 0xe4, 0xbe, 0xeb, 0x37,
+	0xd2, 0x7e, 0x06, 0xb5, 0x56, 0x9a, 0xb7, 0xaa, 0xdb, 0x4e, 0xb0, 0xaf, 0x10, 0x4a, 0xb5, 0x30,
+	0xa4, 0x34, 0xf2, 0xf6, 0xc8, 0x4b, 0x43, 0xd1, 0x91, 0xea, 0xd6, 0x4a, 0xf6, 0x0d, 0x22, 0xa9,
+	0x68, 0x71, 0xa7, 0x4a, 0xb9, 0xe6, 0x81, 0x9b, 0x85, 0x52, 0xd1, 0x5f, 0xab, 0x93, 0x1f, 0x10,
+	0xff, 0xca, 0x68, 0xb5, 0xf9, 0xd8, 0x19, 0x83, 0xd6, 0x16, 0x0f, 0xf6, 0x89, 0x7e, 0x1a, 0x0b,
+	0x57, 0x27, 0x3f, 0xa1, 0x5b, 0x93, 0xb5, 0xbf, 0x31, 0x44, 0xba, 0xae, 0x0d, 0xf7, 0x46, 0x7e,
+	0xfa, 0x79, 0xda, 0x1b, 0xd7, 0x49, 0x36, 0x4b, 0xe2, 0xb4, 0x92, 0xcc, 0xa0, 0xfb, 0x07, 0x73,
+	0x24, 0xbc, 0x36, 0xd5, 0x1b, 0xe8, 0xfd, 0x93, 0x2b, 0x8d, 0x3b, 0x94, 0x74, 0x25, 0x6b, 0xf7,
+	0xd6, 0x98, 0x53, 0x56, 0xa7, 0x5a, 0x89, 0xe9, 0xb3, 0x07, 0x30, 0xb7, 0xc4, 0xef, 0x6c, 0xb5,
+	0x41, 0x96, 0x82, 0x3f, 0x47, 0x62, 0x5f, 0x4e, 0xaf, 0x77, 0x47, 0x86, 0x17, 0x76, 0xd8, 0x0c,
+	0xc2, 0x39, 0x92, 0xcb, 0x81, 0xf5, 0x9b, 0xe9, 0xeb, 0x40, 0x87, 0x83, 0xb3, 0x6e, 0x0d, 0x4e,
+	0x20, 0xa8, 0xcc, 0xb3, 0xe3, 0xc2, 0x9b, 0x30, 0xde, 0xbd, 0x15, 0x1d, 0x6d, 0x33, 0xde, 0x8c,
+	0xcf, 0x93, 0xb8, 0x04, 0x97, 0x81, 0xfb, 0x8f, 0xbf, 0xbf, 0x0c, 0x00, 0x48, 0x14, 0x75, 0x7c,
+	0xd7, 0x02, 0x00, 0x00,
 }
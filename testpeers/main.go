@@ -20,7 +20,8 @@ func createGroup() *mini_groupcache.Group {
 		if v, ok := db[key]; ok {
 			return []byte(v), nil
 		}
-		return nil, fmt.Errorf("%s not exist", key)
+		// 包装 ErrNotFound，API 层才能把它和其它错误区分开
+		return nil, fmt.Errorf("%s not exist: %w", key, mini_groupcache.ErrNotFound)
 	}))
 }
 
@@ -31,7 +32,8 @@ func startAPIServer(apiAddr string, group *mini_groupcache.Group) {
 		key := r.URL.Query().Get("key")
 		view, err := group.Get(key)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			// key 不存在时返回 404，其它错误按 HTTPStatus 映射
+			http.Error(w, err.Error(), mini_groupcache.HTTPStatus(err))
 			return
 		}
 
@@ -51,8 +53,8 @@ func startCacheServer(addr string, addrs []string, group *mini_groupcache.Group)
 	group.RegisterPeers(peers)
 	log.Println("groupcache is running at", addr)
 	// 当前节点开启 http 服务，当前节点实现了 ServeHTTP 方法，发来的请求会被接管
-	// 这个 http 服务用于接收节点与节点直接的请求
-	log.Fatal(http.ListenAndServe(addr[7:], peers))
+	// 这个 http 服务用于接收节点与节点直接的请求，使用 peers.Server 创建带超时配置的服务
+	log.Fatal(peers.Server(addr[7:]).ListenAndServe())
 }
 
 /**
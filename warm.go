@@ -0,0 +1,73 @@
+package mini_groupcache
+
+import (
+	"context"
+	"sync"
+)
+
+// WarmResult 是一次 Warm 的结果统计
+type WarmResult struct {
+	Loaded  int // 加载成功（或本来就在缓存中）的 key 数
+	Failed  int // 加载失败的 key 数
+	Skipped int // ctx 结束时还没有开始加载的 key 数
+}
+
+// Warm 按优先级预热缓存：batches 按优先级从高到低排列，最多同时加载 concurrency 个 key
+// 只有前面所有批次的 key 都已经开始加载之后，后面批次的 key 才会开始加载，空闲的并发额度会立即交给下一个 key，
+// 因此高优先级的 key 总是先于低优先级的 key 占用并发额度，同时额度不会因为等待慢的 key 而闲置
+// 每个 key 与调用 GetContext 一样加载（属于其它节点的 key 会存入 hotCache），单个 key 失败只计入 Failed
+// ctx 贯穿所有批次：ctx 结束后不再开始新的加载，剩下的 key 计入 Skipped 并返回 ctx 的错误；
+// 已经开始的加载收到同一个 ctx，Warm 等它们都结束之后才返回
+// concurrency 必须为正数，否则 panic
+func (g *Group) Warm(ctx context.Context, concurrency int, batches ...[]string) (WarmResult, error) {
+	if concurrency <= 0 {
+		panic("warm concurrency must be positive")
+	}
+
+	var (
+		mu  sync.Mutex
+		res WarmResult
+		wg  sync.WaitGroup
+	)
+	keys := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keys {
+				_, err := g.GetContext(ctx, key)
+				mu.Lock()
+				if err != nil {
+					res.Failed++
+				} else {
+					res.Loaded++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// keys 不带缓冲，只有有空闲的 worker 时才会交出下一个 key，保证按优先级顺序开始加载
+	total, sent := 0, 0
+	for _, batch := range batches {
+		total += len(batch)
+	}
+	var err error
+dispatch:
+	for _, batch := range batches {
+		for _, key := range batch {
+			select {
+			case keys <- key:
+				sent++
+			case <-ctx.Done():
+				err = ctx.Err()
+				break dispatch
+			}
+		}
+	}
+	close(keys)
+	wg.Wait()
+
+	res.Skipped = total - sent
+	return res, err
+}
@@ -0,0 +1,58 @@
+package mini_groupcache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroup_Warm(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	group := NewGroup("warm", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		order = append(order, key)
+		mu.Unlock()
+		if key == "bad" {
+			return nil, fmt.Errorf("%s not found", key)
+		}
+		return []byte(key), nil
+	}))
+
+	// 并发为 1 时严格按优先级顺序加载
+	res, err := group.Warm(context.Background(), 1, []string{"critical", "bad"}, []string{"normal"}, []string{"low"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (WarmResult{Loaded: 3, Failed: 1}); res != want {
+		t.Fatalf("Warm = %+v, want %+v", res, want)
+	}
+	if want := []string{"critical", "bad", "normal", "low"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("load order = %v, want %v", order, want)
+	}
+	if _, ok := group.lookupCache("low"); !ok {
+		t.Fatal("warmed key should be cached")
+	}
+}
+
+func TestGroup_WarmDeadline(t *testing.T) {
+	group := NewGroup("warm-deadline", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		time.Sleep(20 * time.Millisecond)
+		return []byte(key), nil
+	}))
+
+	// deadline 在第一个批次加载的过程中到期，后面批次的 key 都不会开始加载
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	res, err := group.Warm(ctx, 2, []string{"a", "b"}, []string{"c", "d"}, []string{"e"})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Warm error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// 已经开始的两个 key 在 ctx 结束时放弃等待，计入 Failed
+	if res.Loaded+res.Failed != 2 || res.Skipped != 3 {
+		t.Fatalf("Warm = %+v, want 2 started and 3 skipped", res)
+	}
+}
@@ -0,0 +1,60 @@
+package mini_groupcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 默认的预热完成条件：mainCache 填到容量的 80%，或者距离第一个请求已经过去 5 分钟
+const (
+	defaultWarmFill  = 0.8
+	defaultWarmAfter = 5 * time.Minute
+)
+
+// countGet 统计一次请求，第一个请求的时间作为冷启动的起点
+func (g *Group) countGet() {
+	g.stats.gets.Add(1)
+	if atomic.LoadInt64(&g.firstGet) == 0 {
+		atomic.CompareAndSwapInt64(&g.firstGet, 0, time.Now().UnixNano())
+	}
+}
+
+// sinceFirstGet 返回距离分组收到第一个请求的时间，还没有收到请求时为 0
+func (g *Group) sinceFirstGet() time.Duration {
+	first := atomic.LoadInt64(&g.firstGet)
+	if first == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, first))
+}
+
+// fillRatio 返回 mainCache 已用字节数与容量之比，缓存关闭或不限制容量时为 0
+func (g *Group) fillRatio(bytes int64) float64 {
+	max := g.mainCache.budget()
+	if max <= 0 {
+		return 0
+	}
+	return float64(bytes) / float64(max)
+}
+
+// IsWarm 报告分组是否已经度过冷启动，用于在命中率告警中排除刚部署之后命中率天然偏低的阶段
+// 满足 WithWarmCriteria 设置的任意一个条件即为预热完成（默认为缓存填到容量的 80% 或距离第一个请求 5 分钟），
+// 之后一直保持为 true，即使缓存随后被清空
+func (g *Group) IsWarm() bool {
+	return g.checkWarm(g.mainCache.stats().bytes)
+}
+
+// checkWarm 按已用字节数 bytes 判断是否预热完成，并记住结果
+func (g *Group) checkWarm(bytes int64) bool {
+	if atomic.LoadInt32(&g.warm) == 1 {
+		return true
+	}
+	since := g.sinceFirstGet()
+	warm := (g.warmFill > 0 && g.fillRatio(bytes) >= g.warmFill) ||
+		(g.warmAfter > 0 && since >= g.warmAfter) ||
+		(g.warmFill <= 0 && g.warmAfter <= 0)
+	if warm {
+		atomic.StoreInt32(&g.warm, 1)
+	}
+	return warm
+}
@@ -0,0 +1,105 @@
+package workerpool
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DropPolicy 决定队列已满时丢弃哪个任务
+type DropPolicy int
+
+const (
+	// DropNewest 丢弃新提交的任务，Submit 返回 false，已经排队的任务不受影响（默认）
+	DropNewest DropPolicy = iota
+	// DropOldest 丢弃队列中等得最久的任务，为新任务腾出位置，Submit 返回 true；
+	// 适合新任务比旧任务更有价值的场景（如刷新同一批 key 时，越晚提交的越接近当前的访问情况）
+	DropOldest
+)
+
+// Pool 是一个有界的后台任务池
+// 固定数量的 worker 从有界队列中取出任务执行，队列满时按 DropPolicy 丢弃任务，
+// 这样后台任务（异步加载、异步通知等）无论提交得多快，占用的 goroutine 和内存都是有上限的
+type Pool struct {
+	workers int
+	tasks   chan func()
+	once    sync.Once // worker 在第一次提交任务时才启动
+	policy  DropPolicy
+
+	active  int64 // 正在执行的任务数
+	dropped int64 // 累计丢弃的任务数
+}
+
+// Stats 是任务池的状态快照
+type Stats struct {
+	Workers int   // worker 的数量，也是同时执行的任务数的上限
+	Active  int   // 正在执行的任务数
+	Queued  int   // 在队列中等待执行的任务数
+	Dropped int64 // 因为队列已满而丢弃的任务数（包括 DropOldest 丢弃的旧任务）
+}
+
+// New 创建一个拥有 workers 个 worker、队列长度为 queueSize 的任务池
+func New(workers, queueSize int) *Pool {
+	if workers <= 0 {
+		panic("workerpool: workers must be positive")
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	return &Pool{
+		workers: workers,
+		tasks:   make(chan func(), queueSize),
+	}
+}
+
+// SetDropPolicy 设置队列已满时的丢弃策略，需要在提交任务之前调用
+func (p *Pool) SetDropPolicy(policy DropPolicy) {
+	p.policy = policy
+}
+
+// Submit 提交一个任务，不会阻塞调用方
+// 队列已满时，DropNewest 策略下放弃该任务并返回 false；DropOldest 策略下丢弃等得最久的任务，把该任务放入队列并返回 true
+// （队列长度为 0 时没有可以丢弃的旧任务，与 DropNewest 相同）
+func (p *Pool) Submit(task func()) bool {
+	p.once.Do(p.start)
+
+	for {
+		select {
+		case p.tasks <- task:
+			return true
+		default:
+		}
+		atomic.AddInt64(&p.dropped, 1)
+		// 没有队列时也就没有可以丢弃的旧任务
+		if p.policy != DropOldest || cap(p.tasks) == 0 {
+			return false
+		}
+		// 腾出一个位置再重试；worker 可能刚好取走了任务，这时没有可丢弃的，撤销计数后直接重试
+		select {
+		case <-p.tasks:
+		default:
+			atomic.AddInt64(&p.dropped, -1)
+		}
+	}
+}
+
+// Stats 返回任务池当前的状态
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Workers: p.workers,
+		Active:  int(atomic.LoadInt64(&p.active)),
+		Queued:  len(p.tasks),
+		Dropped: atomic.LoadInt64(&p.dropped),
+	}
+}
+
+func (p *Pool) start() {
+	for i := 0; i < p.workers; i++ {
+		go func() {
+			for task := range p.tasks {
+				atomic.AddInt64(&p.active, 1)
+				task()
+				atomic.AddInt64(&p.active, -1)
+			}
+		}()
+	}
+}
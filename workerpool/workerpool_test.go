@@ -0,0 +1,91 @@
+package workerpool
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestPool_Submit(t *testing.T) {
+	p := New(2, 10)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sum := 0
+	for i := 1; i <= 10; i++ {
+		i := i
+		wg.Add(1)
+		if !p.Submit(func() {
+			defer wg.Done()
+			mu.Lock()
+			sum += i
+			mu.Unlock()
+		}) {
+			wg.Done()
+		}
+	}
+	wg.Wait()
+
+	if sum == 0 {
+		t.Fatal("no task was executed")
+	}
+}
+
+func TestPool_SubmitFull(t *testing.T) {
+	p := New(1, 1)
+	block := make(chan struct{})
+	defer close(block)
+
+	started := make(chan struct{})
+	p.Submit(func() {
+		close(started)
+		<-block
+	})
+	<-started
+
+	// 唯一的 worker 正在忙，队列只能再放下一个任务
+	if !p.Submit(func() {}) {
+		t.Fatal("the queue should accept one task")
+	}
+	if p.Submit(func() {}) {
+		t.Fatal("task should be dropped when the queue is full")
+	}
+}
+
+func TestPool_DropOldest(t *testing.T) {
+	p := New(1, 2)
+	p.SetDropPolicy(DropOldest)
+	block := make(chan struct{})
+
+	started := make(chan struct{})
+	p.Submit(func() {
+		close(started)
+		<-block
+	})
+	<-started
+
+	var mu sync.Mutex
+	var ran []int
+	var wg sync.WaitGroup
+	for i := 1; i <= 4; i++ {
+		i := i
+		wg.Add(1)
+		if !p.Submit(func() {
+			mu.Lock()
+			ran = append(ran, i)
+			mu.Unlock()
+			wg.Done()
+		}) {
+			t.Fatal("DropOldest should always accept the new task")
+		}
+	}
+	// 队列只能放下 2 个任务，最早的 1、2 被丢弃
+	if s := p.Stats(); s.Active != 1 || s.Queued != 2 || s.Dropped != 2 {
+		t.Fatalf("stats = %+v", s)
+	}
+	wg.Add(-2)
+	close(block)
+	wg.Wait()
+	if len(ran) != 2 || ran[0] != 3 || ran[1] != 4 {
+		t.Fatalf("ran = %v, want the newest tasks", ran)
+	}
+}